@@ -0,0 +1,295 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	cachev1beta1 "github.com/example/minecraft-operator/api/v1beta1"
+)
+
+// ConvertTo converts this Minecraft to the Hub version (v1beta1).
+func (src *Minecraft) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*cachev1beta1.Minecraft)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 Minecraft but got %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+
+	spec := src.Spec
+	replicas := spec.Size
+	dst.Spec = cachev1beta1.MinecraftSpec{
+		Replicas: &replicas,
+		Server: cachev1beta1.ServerSpec{
+			Edition:        cachev1beta1.Edition(spec.Edition),
+			Type:           cachev1beta1.ServerType(spec.Type),
+			Version:        spec.Version,
+			Options:        cachev1beta1.ServerTypeOptions(spec.TypeOptions),
+			Image:          spec.Image,
+			AllowDowngrade: spec.AllowDowngrade,
+		},
+		Properties: cachev1beta1.ServerProperties{
+			EULA:         spec.Config.EULA,
+			Difficulty:   cachev1beta1.Difficulty(spec.Config.Difficulty),
+			GameMode:     cachev1beta1.GameMode(spec.Config.GameMode),
+			MOTD:         spec.Config.MOTD,
+			MaxPlayers:   spec.Config.MaxPlayers,
+			ViewDistance: spec.Config.ViewDistance,
+			LevelName:    spec.Config.LevelName,
+			LevelSeed:    spec.Config.LevelSeed,
+			OnlineMode:   spec.Config.OnlineMode,
+			PVP:          spec.Config.PVP,
+			Hardcore:     spec.Config.Hardcore,
+			AllowFlight:  spec.Config.AllowFlight,
+		},
+		Service: cachev1beta1.ServiceSpec{
+			Type:                  spec.Service.Type,
+			Port:                  spec.Service.Port,
+			NodePort:              spec.Service.NodePort,
+			LoadBalancerIP:        spec.Service.LoadBalancerIP,
+			ExternalTrafficPolicy: spec.Service.ExternalTrafficPolicy,
+			Annotations:           spec.Service.Annotations,
+		},
+		Storage: cachev1beta1.StorageSpec{
+			Size:             spec.Storage.Size,
+			StorageClassName: spec.Storage.StorageClassName,
+			AccessMode:       spec.Storage.AccessMode,
+			RetainPolicy:     cachev1beta1.StorageRetainPolicy(spec.Storage.RetainPolicy),
+		},
+		Probes: cachev1beta1.ProbesSpec{
+			Type:                  cachev1beta1.ProbeType(spec.Probes.Type),
+			StartupTimeoutSeconds: spec.Probes.StartupTimeoutSeconds,
+			PeriodSeconds:         spec.Probes.PeriodSeconds,
+			FailureThreshold:      spec.Probes.FailureThreshold,
+			DisableLiveness:       spec.Probes.DisableLiveness,
+		},
+		Resources:      spec.Resources,
+		DeletionPolicy: cachev1beta1.DeletionPolicy(spec.DeletionPolicy),
+		Shutdown:       (*cachev1beta1.ShutdownSpec)(spec.Shutdown),
+		AutoPause:      (*cachev1beta1.AutoPauseSpec)(spec.AutoPause),
+	}
+	for _, p := range spec.Service.ExtraPorts {
+		dst.Spec.Service.ExtraPorts = append(dst.Spec.Service.ExtraPorts, cachev1beta1.ServicePort(p))
+	}
+	if jvm := spec.JVM; jvm != nil {
+		dst.Spec.JVM = &cachev1beta1.JVMSpec{
+			MinHeap:        jvm.MinHeap,
+			MaxHeap:        jvm.MaxHeap,
+			HeapPercentage: jvm.HeapPercentage,
+			Flags:          cachev1beta1.JVMFlags(jvm.Flags),
+			GC:             cachev1beta1.GarbageCollector(jvm.GC),
+			ExtraArgs:      jvm.ExtraArgs,
+		}
+	}
+	if maintenance := spec.Maintenance; maintenance != nil {
+		dst.Spec.Maintenance = &cachev1beta1.MaintenanceSpec{
+			Policy: cachev1beta1.MaintenancePolicy(maintenance.Policy),
+		}
+		for _, w := range maintenance.Windows {
+			dst.Spec.Maintenance.Windows = append(dst.Spec.Maintenance.Windows, cachev1beta1.MaintenanceWindow(w))
+		}
+	}
+	if backup := spec.Backup; backup != nil {
+		dst.Spec.Backup = &cachev1beta1.BackupSchedule{
+			Schedule: backup.Schedule,
+			Target: cachev1beta1.BackupTarget{
+				PersistentVolumeClaim: (*cachev1beta1.PersistentVolumeClaimBackupTarget)(
+					backup.Target.PersistentVolumeClaim),
+				S3: (*cachev1beta1.S3BackupTarget)(backup.Target.S3),
+			},
+			HistoryLimit: backup.HistoryLimit,
+		}
+	}
+	if players := spec.Players; players != nil {
+		dst.Spec.Players = &cachev1beta1.PlayersSpec{Whitelist: players.Whitelist}
+		for _, op := range players.Ops {
+			dst.Spec.Players.Ops = append(dst.Spec.Players.Ops, cachev1beta1.OpSpec(op))
+		}
+		for _, ban := range players.Bans {
+			dst.Spec.Players.Bans = append(dst.Spec.Players.Bans, cachev1beta1.BanSpec(ban))
+		}
+	}
+	for _, a := range spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, cachev1beta1.Artifact(a))
+	}
+	for _, a := range spec.Mods {
+		dst.Spec.Mods = append(dst.Spec.Mods, cachev1beta1.Artifact(a))
+	}
+
+	status := src.Status
+	dst.Status = cachev1beta1.MinecraftStatus{
+		Conditions:         status.Conditions,
+		Phase:              cachev1beta1.MinecraftPhase(status.Phase),
+		ObservedGeneration: status.ObservedGeneration,
+		ReadyReplicas:      status.ReadyReplicas,
+		Address:            status.Address,
+		LastBackupTime:     status.LastBackupTime,
+		IdleSince:          status.IdleSince,
+		LastScheduleTime:   status.LastScheduleTime,
+		CurrentVersion:     status.CurrentVersion,
+		ServerVersion:      status.ServerVersion,
+		MOTD:               status.MOTD,
+		OnlinePlayers:      status.OnlinePlayers,
+		MaxPlayers:         status.MaxPlayers,
+	}
+	for _, server := range status.Artifacts {
+		artifacts := cachev1beta1.ServerArtifacts{Server: server.Server, Truncated: server.Truncated}
+		for _, a := range server.Artifacts {
+			artifacts.Artifacts = append(artifacts.Artifacts, cachev1beta1.InstalledArtifact(a))
+		}
+		dst.Status.Artifacts = append(dst.Status.Artifacts, artifacts)
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *Minecraft) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*cachev1beta1.Minecraft)
+	if !ok {
+		return fmt.Errorf("expected a v1beta1 Minecraft but got %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+
+	spec := src.Spec
+	// Replicas is defaulted by the API server, it is only unset on objects
+	// built in Go
+	size := int32(1)
+	if spec.Replicas != nil {
+		size = *spec.Replicas
+	}
+	dst.Spec = MinecraftSpec{
+		Size:           size,
+		Edition:        Edition(spec.Server.Edition),
+		Version:        spec.Server.Version,
+		Image:          spec.Server.Image,
+		AllowDowngrade: spec.Server.AllowDowngrade,
+		Type:           ServerType(spec.Server.Type),
+		TypeOptions:    ServerTypeOptions(spec.Server.Options),
+		Config: ServerConfig{
+			EULA:         spec.Properties.EULA,
+			Difficulty:   Difficulty(spec.Properties.Difficulty),
+			GameMode:     GameMode(spec.Properties.GameMode),
+			MOTD:         spec.Properties.MOTD,
+			MaxPlayers:   spec.Properties.MaxPlayers,
+			ViewDistance: spec.Properties.ViewDistance,
+			LevelName:    spec.Properties.LevelName,
+			LevelSeed:    spec.Properties.LevelSeed,
+			OnlineMode:   spec.Properties.OnlineMode,
+			PVP:          spec.Properties.PVP,
+			Hardcore:     spec.Properties.Hardcore,
+			AllowFlight:  spec.Properties.AllowFlight,
+		},
+		Service: ServiceSpec{
+			Type:                  spec.Service.Type,
+			Port:                  spec.Service.Port,
+			NodePort:              spec.Service.NodePort,
+			LoadBalancerIP:        spec.Service.LoadBalancerIP,
+			ExternalTrafficPolicy: spec.Service.ExternalTrafficPolicy,
+			Annotations:           spec.Service.Annotations,
+		},
+		Storage: StorageSpec{
+			Size:             spec.Storage.Size,
+			StorageClassName: spec.Storage.StorageClassName,
+			AccessMode:       spec.Storage.AccessMode,
+			RetainPolicy:     StorageRetainPolicy(spec.Storage.RetainPolicy),
+		},
+		Probes: ProbesSpec{
+			Type:                  ProbeType(spec.Probes.Type),
+			StartupTimeoutSeconds: spec.Probes.StartupTimeoutSeconds,
+			PeriodSeconds:         spec.Probes.PeriodSeconds,
+			FailureThreshold:      spec.Probes.FailureThreshold,
+			DisableLiveness:       spec.Probes.DisableLiveness,
+		},
+		Resources:      spec.Resources,
+		DeletionPolicy: DeletionPolicy(spec.DeletionPolicy),
+		Shutdown:       (*ShutdownSpec)(spec.Shutdown),
+		AutoPause:      (*AutoPauseSpec)(spec.AutoPause),
+	}
+	for _, p := range spec.Service.ExtraPorts {
+		dst.Spec.Service.ExtraPorts = append(dst.Spec.Service.ExtraPorts, ServicePort(p))
+	}
+	if jvm := spec.JVM; jvm != nil {
+		dst.Spec.JVM = &JVMSpec{
+			MinHeap:        jvm.MinHeap,
+			MaxHeap:        jvm.MaxHeap,
+			HeapPercentage: jvm.HeapPercentage,
+			Flags:          JVMFlags(jvm.Flags),
+			GC:             GarbageCollector(jvm.GC),
+			ExtraArgs:      jvm.ExtraArgs,
+		}
+	}
+	if maintenance := spec.Maintenance; maintenance != nil {
+		dst.Spec.Maintenance = &MaintenanceSpec{
+			Policy: MaintenancePolicy(maintenance.Policy),
+		}
+		for _, w := range maintenance.Windows {
+			dst.Spec.Maintenance.Windows = append(dst.Spec.Maintenance.Windows, MaintenanceWindow(w))
+		}
+	}
+	if backup := spec.Backup; backup != nil {
+		dst.Spec.Backup = &BackupSchedule{
+			Schedule: backup.Schedule,
+			Target: BackupTarget{
+				PersistentVolumeClaim: (*PersistentVolumeClaimBackupTarget)(backup.Target.PersistentVolumeClaim),
+				S3:                    (*S3BackupTarget)(backup.Target.S3),
+			},
+			HistoryLimit: backup.HistoryLimit,
+		}
+	}
+	if players := spec.Players; players != nil {
+		dst.Spec.Players = &PlayersSpec{Whitelist: players.Whitelist}
+		for _, op := range players.Ops {
+			dst.Spec.Players.Ops = append(dst.Spec.Players.Ops, OpSpec(op))
+		}
+		for _, ban := range players.Bans {
+			dst.Spec.Players.Bans = append(dst.Spec.Players.Bans, BanSpec(ban))
+		}
+	}
+	for _, a := range spec.Plugins {
+		dst.Spec.Plugins = append(dst.Spec.Plugins, Artifact(a))
+	}
+	for _, a := range spec.Mods {
+		dst.Spec.Mods = append(dst.Spec.Mods, Artifact(a))
+	}
+
+	status := src.Status
+	dst.Status = MinecraftStatus{
+		Conditions:         status.Conditions,
+		Phase:              MinecraftPhase(status.Phase),
+		ObservedGeneration: status.ObservedGeneration,
+		ReadyReplicas:      status.ReadyReplicas,
+		Address:            status.Address,
+		LastBackupTime:     status.LastBackupTime,
+		IdleSince:          status.IdleSince,
+		LastScheduleTime:   status.LastScheduleTime,
+		CurrentVersion:     status.CurrentVersion,
+		ServerVersion:      status.ServerVersion,
+		MOTD:               status.MOTD,
+		OnlinePlayers:      status.OnlinePlayers,
+		MaxPlayers:         status.MaxPlayers,
+	}
+	for _, server := range status.Artifacts {
+		artifacts := ServerArtifacts{Server: server.Server, Truncated: server.Truncated}
+		for _, a := range server.Artifacts {
+			artifacts.Artifacts = append(artifacts.Artifacts, InstalledArtifact(a))
+		}
+		dst.Status.Artifacts = append(dst.Status.Artifacts, artifacts)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MinecraftBackupSpec defines the desired state of MinecraftBackup
+type MinecraftBackupSpec struct {
+	// MinecraftRef is the Minecraft instance in the namespace of the backup
+	// whose worlds are archived.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="minecraftRef is immutable"
+	MinecraftRef corev1.LocalObjectReference `json:"minecraftRef"`
+
+	// Target is where the archives are stored. The world volumes themselves
+	// are used when it is not set.
+	// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="target is immutable"
+	// +optional
+	Target BackupTarget `json:"target,omitempty"`
+}
+
+// BackupTarget defines where backup archives are stored. At most one of its
+// fields may be set, when none is set the archives are stored in the backups
+// directory of the world volume they were taken from, which protects against
+// mistakes but not against the loss of the volume.
+// +kubebuilder:validation:XValidation:rule="[has(self.persistentVolumeClaim), has(self.s3)].filter(x, x).size() <= 1",message="at most one backup target may be set"
+type BackupTarget struct {
+	// PersistentVolumeClaim stores the archives on a volume shared by the backups.
+	// +optional
+	PersistentVolumeClaim *PersistentVolumeClaimBackupTarget `json:"persistentVolumeClaim,omitempty"`
+
+	// S3 uploads the archives to a bucket of an S3-compatible object store.
+	// +optional
+	S3 *S3BackupTarget `json:"s3,omitempty"`
+}
+
+// PersistentVolumeClaimBackupTarget stores archives on a PersistentVolumeClaim
+type PersistentVolumeClaimBackupTarget struct {
+	// ClaimName is the name of the PersistentVolumeClaim in the namespace of the
+	// backup. It must be mountable next to the world volume, e.g. ReadWriteMany.
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path is the directory of the volume the archives are stored in.
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// S3BackupTarget uploads archives to an S3-compatible object store
+type S3BackupTarget struct {
+	// Endpoint is the URL of the object store, AWS S3 is used when it is not set.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region is the region of the bucket.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Bucket is the name of the bucket the archives are uploaded to.
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Prefix is prepended to the keys of the archives, e.g. "minecraft/".
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// CredentialsSecret is the Secret holding the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY used to upload the archives.
+	CredentialsSecret corev1.LocalObjectReference `json:"credentialsSecret"`
+}
+
+// BackupPhase is where a backup is in its lifecycle
+// +kubebuilder:validation:Enum=Pending;Running;Completed;Failed
+type BackupPhase string
+
+const (
+	// BackupPhasePending means the backup has not started yet
+	BackupPhasePending BackupPhase = "Pending"
+	// BackupPhaseRunning means the saving of the servers is turned off and the
+	// worlds are archived
+	BackupPhaseRunning BackupPhase = "Running"
+	// BackupPhaseCompleted means the worlds were archived
+	BackupPhaseCompleted BackupPhase = "Completed"
+	// BackupPhaseFailed means a world could not be archived
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+// BackupArchive is the archive of the world of a Minecraft instance
+type BackupArchive struct {
+	// ClaimName is the name of the world volume which was archived.
+	ClaimName string `json:"claimName"`
+
+	// Location is where the archive is stored, e.g. s3://bucket/key.
+	// +optional
+	Location string `json:"location,omitempty"`
+
+	// SizeBytes is the size of the archive.
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// WorldSizeBytes is the size on disk of the world which was archived,
+	// without the archives of earlier backups stored next to it.
+	// +optional
+	WorldSizeBytes int64 `json:"worldSizeBytes,omitempty"`
+
+	// Checksum is the SHA-256 checksum of the archive, e.g. "sha256:…".
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// MinecraftBackupStatus defines the observed state of MinecraftBackup
+type MinecraftBackupStatus struct {
+	// Phase is where the backup is in its lifecycle.
+	// +optional
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Message details the phase, e.g. why the backup failed.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// StartTime is when the saving of the servers was turned off.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the backup completed or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// Duration is how long the backup took.
+	// +optional
+	Duration *metav1.Duration `json:"duration,omitempty"`
+
+	// SizeBytes is the total size of the archives.
+	// +optional
+	SizeBytes int64 `json:"sizeBytes,omitempty"`
+
+	// Archives are the archives of the worlds, one per instance.
+	// +optional
+	Archives []BackupArchive `json:"archives,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Minecraft",type=string,JSONPath=`.spec.minecraftRef.name`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.status.sizeBytes`
+// +kubebuilder:printcolumn:name="Duration",type=string,JSONPath=`.status.duration`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MinecraftBackup is the Schema for the minecraftbackups API
+type MinecraftBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MinecraftBackupSpec   `json:"spec,omitempty"`
+	Status MinecraftBackupStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MinecraftBackupList contains a list of MinecraftBackup
+type MinecraftBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MinecraftBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MinecraftBackup{}, &MinecraftBackupList{})
+}
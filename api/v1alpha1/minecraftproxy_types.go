@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxyType is the proxy software a MinecraftProxy runs
+// +kubebuilder:validation:Enum=Velocity;BungeeCord
+type ProxyType string
+
+const (
+	// ProxyTypeVelocity runs Velocity, which forwards the players to the
+	// backends with its modern forwarding
+	ProxyTypeVelocity ProxyType = "Velocity"
+	// ProxyTypeBungeeCord runs BungeeCord, which forwards the players to the
+	// backends with the legacy IP forwarding
+	ProxyTypeBungeeCord ProxyType = "BungeeCord"
+)
+
+// MinecraftProxySpec defines the desired state of MinecraftProxy
+type MinecraftProxySpec struct {
+	// Type is the proxy software to run.
+	// +kubebuilder:default=Velocity
+	// +optional
+	Type ProxyType `json:"type,omitempty"`
+
+	// Replicas is the number of proxy instances.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Image overrides the image of the proxy. The image of the operator
+	// configuration is used when it is not set.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Selector selects the Minecraft instances in the namespace of the proxy
+	// players are forwarded to. It must not be empty. The operator configures
+	// the backends to accept the forwarded players: Velocity forwards players
+	// to Paper and Purpur instances, which verify the secret of
+	// status.forwardingSecret, BungeeCord also to Spigot instances. Their
+	// servers run in offline mode and their Services are only reachable inside
+	// the cluster, the proxy authenticates the players. Other instances are
+	// not selected, and an instance selected by several proxies is only the
+	// backend of the first one by name.
+	// +kubebuilder:validation:XValidation:rule="(has(self.matchLabels) && size(self.matchLabels) > 0) || (has(self.matchExpressions) && size(self.matchExpressions) > 0)",message="the selector must not be empty"
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Try lists the names of the backends players join first, in order. All
+	// backends are tried by name when it is not set.
+	// +optional
+	Try []string `json:"try,omitempty"`
+
+	// MOTD is the message of the day shown in the server list.
+	// +optional
+	MOTD string `json:"motd,omitempty"`
+
+	// MaxPlayers is the number of players shown as maximum in the server list.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPlayers *int32 `json:"maxPlayers,omitempty"`
+
+	// Service configures the Service players connect to.
+	// +optional
+	Service ServiceSpec `json:"service,omitempty"`
+}
+
+// MinecraftProxyStatus defines the observed state of MinecraftProxy
+type MinecraftProxyStatus struct {
+	// Represents the observations of a MinecraftProxy's current state.
+	// MinecraftProxy.status.conditions.type are: "Available"
+	// MinecraftProxy.status.conditions.status are one of True, False, Unknown.
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ObservedGeneration is the generation of the spec the status was computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of proxy instances which are ready.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Backends are the names of the Minecraft instances players are forwarded to.
+	// +optional
+	Backends []string `json:"backends,omitempty"`
+
+	// ForwardingSecret is the name of the Secret holding the forwarding secret
+	// the backends verify the forwarded players with.
+	// +optional
+	ForwardingSecret string `json:"forwardingSecret,omitempty"`
+
+	// Address is where players connect to the proxy.
+	// +optional
+	Address string `json:"address,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.readyReplicas
+// +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.type`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Backends",type=string,JSONPath=`.status.backends`
+// +kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.address`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MinecraftProxy is the Schema for the minecraftproxies API
+type MinecraftProxy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MinecraftProxySpec   `json:"spec,omitempty"`
+	Status MinecraftProxyStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MinecraftProxyList contains a list of MinecraftProxy
+type MinecraftProxyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MinecraftProxy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MinecraftProxy{}, &MinecraftProxyList{})
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MinecraftRestoreSpec defines the desired state of MinecraftRestore
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="the spec of a restore is immutable"
+// +kubebuilder:validation:XValidation:rule="!has(self.checksum) || !has(self.source.volumeSnapshot)",message="checksum is not supported with a volumeSnapshot source"
+type MinecraftRestoreSpec struct {
+	// MinecraftRef is the Minecraft instance in the namespace of the restore
+	// whose world is replaced. The archive is unpacked into the world volume of
+	// every instance.
+	MinecraftRef corev1.LocalObjectReference `json:"minecraftRef"`
+
+	// Source is the world which is restored.
+	Source RestoreSource `json:"source"`
+
+	// Checksum is the expected SHA-256 checksum of the archive, e.g. "sha256:…"
+	// as recorded by a MinecraftBackup. The world is left untouched when the
+	// archive does not match.
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// RestoreSource defines where the restored world comes from. Exactly one of
+// its fields must be set.
+// +kubebuilder:validation:XValidation:rule="[has(self.persistentVolumeClaim), has(self.url), has(self.volumeSnapshot)].filter(x, x).size() == 1",message="exactly one restore source must be set"
+type RestoreSource struct {
+	// PersistentVolumeClaim restores a tarball stored on a volume, like the
+	// archives of a MinecraftBackup.
+	// +optional
+	PersistentVolumeClaim *PersistentVolumeClaimRestoreSource `json:"persistentVolumeClaim,omitempty"`
+
+	// URL restores a tarball downloaded over HTTP(S), e.g. a pre-signed URL of
+	// an archive in an object store.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// VolumeSnapshot restores the content of a VolumeSnapshot of a world volume.
+	// +optional
+	VolumeSnapshot *corev1.LocalObjectReference `json:"volumeSnapshot,omitempty"`
+}
+
+// PersistentVolumeClaimRestoreSource is a tarball stored on a PersistentVolumeClaim
+type PersistentVolumeClaimRestoreSource struct {
+	// ClaimName is the name of the PersistentVolumeClaim in the namespace of the
+	// restore. A world volume of the Minecraft instance may be used to restore
+	// from the archives kept in its backups directory.
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path is the path of the tarball on the volume.
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
+// MinecraftRestoreStatus defines the observed state of MinecraftRestore
+type MinecraftRestoreStatus struct {
+	// Represents the observations of a MinecraftRestore's current state.
+	// MinecraftRestore.status.conditions.type are: "ServerStopped", "WorldRestored",
+	// "ServerStarted" and "Complete", which is true once the restore succeeded and
+	// false when it failed.
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// StartTime is when the restore started.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// CompletionTime is when the restore completed or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Minecraft",type=string,JSONPath=`.spec.minecraftRef.name`
+// +kubebuilder:printcolumn:name="Complete",type=string,JSONPath=`.status.conditions[?(@.type=="Complete")].status`
+// +kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Complete")].reason`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// MinecraftRestore is the Schema for the minecraftrestores API
+type MinecraftRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MinecraftRestoreSpec   `json:"spec,omitempty"`
+	Status MinecraftRestoreStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MinecraftRestoreList contains a list of MinecraftRestore
+type MinecraftRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MinecraftRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MinecraftRestore{}, &MinecraftRestoreList{})
+}
@@ -0,0 +1,972 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+
+// MinecraftSpec defines the desired state of Minecraft
+// +kubebuilder:validation:XValidation:rule="!has(self.server) || !has(self.server.edition) || self.server.edition != 'Bedrock' || !has(self.probes) || !has(self.probes.type) || self.probes.type != 'TCP'",message="the Bedrock edition does not support TCP probes"
+// +kubebuilder:validation:XValidation:rule="!has(self.server) || !has(self.server.edition) || self.server.edition != 'Bedrock' || !has(self.autoPause)",message="the Bedrock edition does not support autoPause"
+// +kubebuilder:validation:XValidation:rule="!has(self.server) || !has(self.server.edition) || self.server.edition != 'Bedrock' || !has(self.players)",message="the Bedrock edition does not support players"
+// +kubebuilder:validation:XValidation:rule="!has(self.server) || !has(self.server.edition) || self.server.edition != 'Bedrock' || !has(self.jvm)",message="the Bedrock edition does not support jvm"
+// +kubebuilder:validation:XValidation:rule="!has(self.plugins) || size(self.plugins) == 0 || (has(self.server) && has(self.server.type) && self.server.type in ['Paper', 'Spigot', 'Purpur'])",message="plugins are only supported by the Paper, Spigot and Purpur types"
+// +kubebuilder:validation:XValidation:rule="!has(self.mods) || size(self.mods) == 0 || (has(self.server) && has(self.server.type) && self.server.type in ['Fabric', 'Forge', 'NeoForge', 'Quilt'])",message="mods are only supported by the Fabric, Forge, NeoForge and Quilt types"
+type MinecraftSpec struct {
+	// Replicas is the number of servers running the world. Each server has its
+	// own world volume.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3
+	// +kubebuilder:default=1
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Server defines the edition, the software and the version the servers run.
+	// +optional
+	Server ServerSpec `json:"server,omitempty"`
+
+	// Properties holds the server.properties settings of the servers. They are
+	// rendered into a ConfigMap owned by the custom resource and a change
+	// restarts the servers.
+	// +optional
+	Properties ServerProperties `json:"properties,omitempty"`
+
+	// Service defines how the server is exposed to the players.
+	// +optional
+	Service ServiceSpec `json:"service,omitempty"`
+
+	// Storage defines the persistent volume which holds the world data.
+	// A PersistentVolumeClaim is created for every instance from this template
+	// and mounted at /data in the server container.
+	// +optional
+	Storage StorageSpec `json:"storage,omitempty"`
+
+	// Probes configures how the kubelet checks that the server is started,
+	// accepts players and is still responsive.
+	// +optional
+	Probes ProbesSpec `json:"probes,omitempty"`
+
+	// Resources are the compute resources of the server container. The heap of
+	// the Java Edition servers is derived from the memory limit, see JVM.
+	// More info: https://kubernetes.io/docs/concepts/configuration/manage-resources-containers/
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// JVM tunes the Java virtual machine running the Java Edition servers.
+	// +optional
+	JVM *JVMSpec `json:"jvm,omitempty"`
+
+	// Shutdown defines how the servers are stopped when their pods terminate.
+	// The players are warned with a countdown, then the worlds are saved and
+	// the servers stopped through RCON before the grace period runs out.
+	// +optional
+	Shutdown *ShutdownSpec `json:"shutdown,omitempty"`
+
+	// Maintenance holds back the changes which restart the servers, like a new
+	// version, image, configuration or plugins, until a maintenance window opens
+	// or no player is online. Staged changes are reported by the PendingRestart
+	// condition. Without it, changes are applied right away.
+	// +optional
+	Maintenance *MaintenanceSpec `json:"maintenance,omitempty"`
+
+	// Backup schedules backups of the worlds. Backups can also be taken at any
+	// time by creating a MinecraftBackup.
+	// +optional
+	Backup *BackupSchedule `json:"backup,omitempty"`
+
+	// DeletionPolicy defines what happens to the worlds when the Minecraft
+	// instance is deleted. The instance is only removed once the final backup of
+	// the Snapshot policy completed. When it is not set, the world volumes are
+	// kept or deleted according to the retain policy of the storage.
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// AutoPause stops the servers once no player was online for a while. The
+	// servers are woken up by a player joining through the gateway, or by
+	// annotating the custom resource with cache.example.com/wake.
+	// +optional
+	AutoPause *AutoPauseSpec `json:"autoPause,omitempty"`
+
+	// Players manages the whitelist, the operators and the bans of the servers.
+	// The usernames are resolved to the UUIDs of the players, and changes are
+	// applied to the running servers through RCON without restarting them.
+	// Lists changed by hand on the servers are overwritten when they restart.
+	// +optional
+	Players *PlayersSpec `json:"players,omitempty"`
+
+	// Plugins are installed into /data/plugins by an init container before the
+	// servers start. Plugins removed from the list are uninstalled, files added
+	// to the directory by other means are left alone.
+	// +kubebuilder:validation:MaxItems=100
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Plugins []Artifact `json:"plugins,omitempty"`
+
+	// Mods are installed into /data/mods like the plugins.
+	// +kubebuilder:validation:MaxItems=100
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Mods []Artifact `json:"mods,omitempty"`
+}
+
+// ServerSpec defines the server software of a Minecraft instance
+// +kubebuilder:validation:XValidation:rule="!has(self.edition) || self.edition != 'Bedrock' || !has(self.type) || self.type == 'Vanilla'",message="the Bedrock edition only supports the Vanilla type"
+// +kubebuilder:validation:XValidation:rule="!has(self.options) || !has(self.options.loaderVersion) || (has(self.type) && self.type in ['Fabric', 'Quilt'])",message="options.loaderVersion is only supported by the Fabric and Quilt types"
+// +kubebuilder:validation:XValidation:rule="!has(self.options) || !has(self.options.installerVersion) || (has(self.type) && self.type in ['Forge', 'NeoForge'])",message="options.installerVersion is only supported by the Forge and NeoForge types"
+// +kubebuilder:validation:XValidation:rule="!has(self.options) || !has(self.options.build) || (has(self.type) && self.type in ['Paper', 'Purpur'])",message="options.build is only supported by the Paper and Purpur types"
+type ServerSpec struct {
+	// Edition is the edition of Minecraft the server runs. Java and Bedrock clients
+	// can only join servers of their own edition.
+	// +kubebuilder:default=Java
+	// +optional
+	Edition Edition `json:"edition,omitempty"`
+
+	// Type is the server software which runs the world. The Bedrock edition
+	// only supports Vanilla.
+	// +kubebuilder:default=Vanilla
+	// +optional
+	Type ServerType `json:"type,omitempty"`
+
+	// Version is the Minecraft version the server runs, e.g. "1.20.4".
+	// LATEST and SNAPSHOT follow the most recent release and snapshot respectively.
+	// +kubebuilder:default=LATEST
+	// +optional
+	Version string `json:"version,omitempty"`
+
+	// Options holds settings specific to the server type, like the version
+	// of the mod loader. Options not supported by the type are rejected.
+	// +optional
+	Options ServerTypeOptions `json:"options,omitempty"`
+
+	// Image is the container image of the server. It overrides the image configured
+	// on the operator, e.g. to pin a digest or use a mirror.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// AllowDowngrade allows changing Version to an older release than the one the
+	// world was last run with. Worlds are not guaranteed to load in older versions,
+	// so downgrades are refused unless this is set.
+	// +optional
+	AllowDowngrade bool `json:"allowDowngrade,omitempty"`
+}
+
+// BackupSchedule defines the backups taken periodically of a Minecraft instance
+type BackupSchedule struct {
+	// Schedule is the cron expression of when backups are taken, e.g. "0 4 * * *".
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Target is where the archives are stored.
+	// +optional
+	Target BackupTarget `json:"target,omitempty"`
+
+	// HistoryLimit is the number of scheduled MinecraftBackups kept, older ones
+	// are deleted. Their archives are left on the target.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=7
+	// +optional
+	HistoryLimit *int32 `json:"historyLimit,omitempty"`
+}
+
+// BackupTarget defines where backup archives are stored. At most one of its
+// fields may be set, when none is set the archives are stored in the backups
+// directory of the world volume they were taken from, which protects against
+// mistakes but not against the loss of the volume.
+// +kubebuilder:validation:XValidation:rule="[has(self.persistentVolumeClaim), has(self.s3)].filter(x, x).size() <= 1",message="at most one backup target may be set"
+type BackupTarget struct {
+	// PersistentVolumeClaim stores the archives on a volume shared by the backups.
+	// +optional
+	PersistentVolumeClaim *PersistentVolumeClaimBackupTarget `json:"persistentVolumeClaim,omitempty"`
+
+	// S3 uploads the archives to a bucket of an S3-compatible object store.
+	// +optional
+	S3 *S3BackupTarget `json:"s3,omitempty"`
+}
+
+// PersistentVolumeClaimBackupTarget stores archives on a PersistentVolumeClaim
+type PersistentVolumeClaimBackupTarget struct {
+	// ClaimName is the name of the PersistentVolumeClaim in the namespace of the
+	// backup. It must be mountable next to the world volume, e.g. ReadWriteMany.
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+
+	// Path is the directory of the volume the archives are stored in.
+	// +kubebuilder:default="/"
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+// S3BackupTarget uploads archives to an S3-compatible object store
+type S3BackupTarget struct {
+	// Endpoint is the URL of the object store, AWS S3 is used when it is not set.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Region is the region of the bucket.
+	// +optional
+	Region string `json:"region,omitempty"`
+
+	// Bucket is the name of the bucket the archives are uploaded to.
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// Prefix is prepended to the keys of the archives, e.g. "minecraft/".
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// CredentialsSecret is the Secret holding the AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY used to upload the archives.
+	CredentialsSecret corev1.LocalObjectReference `json:"credentialsSecret"`
+}
+
+// WakeAnnotation wakes up the sleeping servers of the Minecraft instance it is
+// set on, and restarts the idle timeout of running ones. The operator removes
+// it once handled.
+const WakeAnnotation = "cache.example.com/wake"
+
+// AutoPauseSpec defines when the servers of a Minecraft instance are stopped
+type AutoPauseSpec struct {
+	// IdleTimeout is how long no player must be online before the worlds are
+	// saved and the servers stopped.
+	// +kubebuilder:default="15m"
+	// +optional
+	IdleTimeout metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// Hostnames are the hostnames players connect to through the gateway of
+	// the operator. The gateway answers the status requests of the sleeping
+	// servers, wakes them up when a player joins and forwards the players to
+	// the running servers. The players of servers behind a MinecraftProxy are
+	// told to join through the proxy instead.
+	// +optional
+	Hostnames []string `json:"hostnames,omitempty"`
+}
+
+// PlayersSpec defines the players allowed on, operating and banned from the
+// servers of a Minecraft instance
+type PlayersSpec struct {
+	// Whitelist lists the usernames of the players allowed to join. The
+	// whitelist is enforced when it is not empty, enabling or disabling it
+	// restarts the servers.
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z0-9_]{3,16}$`
+	// +listType=set
+	// +optional
+	Whitelist []string `json:"whitelist,omitempty"`
+
+	// Ops lists the operators.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Ops []OpSpec `json:"ops,omitempty"`
+
+	// Bans lists the players banned from the servers.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Bans []BanSpec `json:"bans,omitempty"`
+}
+
+// OpSpec defines an operator of the servers
+type OpSpec struct {
+	// Name is the username of the player.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_]{3,16}$`
+	Name string `json:"name"`
+
+	// Level is the permission level of the operator, from 1 to 4. A change of
+	// the level of a running operator applies when the servers restart.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	// +kubebuilder:default=4
+	// +optional
+	Level int32 `json:"level,omitempty"`
+
+	// BypassesPlayerLimit lets the operator join servers which are full.
+	// +optional
+	BypassesPlayerLimit bool `json:"bypassesPlayerLimit,omitempty"`
+}
+
+// BanSpec defines a player banned from the servers
+type BanSpec struct {
+	// Name is the username of the player.
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9_]{3,16}$`
+	Name string `json:"name"`
+
+	// Reason is shown to the player when they are refused.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// Artifact is a plugin or a mod installed on the servers, downloaded from a
+// URL or an OCI registry, or read from a key of a ConfigMap or Secret
+// +kubebuilder:validation:XValidation:rule="[has(self.url), has(self.configMap), has(self.secret), has(self.oci)].filter(x, x).size() == 1",message="exactly one of url, configMap, secret and oci must be set"
+// +kubebuilder:validation:XValidation:rule="!(has(self.url) || has(self.oci)) || has(self.checksum)",message="a checksum is required for the url and oci sources"
+type Artifact struct {
+	// Name is the file name the artifact is installed as, e.g. "worldedit.jar".
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9][A-Za-z0-9._+-]*\.jar$`
+	Name string `json:"name"`
+
+	// URL downloads the artifact over HTTP(S).
+	// +kubebuilder:validation:Pattern=`^https?://\S+$`
+	// +optional
+	URL string `json:"url,omitempty"`
+
+	// ConfigMap reads the artifact from a key of a ConfigMap, as binaryData.
+	// +optional
+	ConfigMap *corev1.ConfigMapKeySelector `json:"configMap,omitempty"`
+
+	// Secret reads the artifact from a key of a Secret.
+	// +optional
+	Secret *corev1.SecretKeySelector `json:"secret,omitempty"`
+
+	// OCI pulls the artifact from an OCI registry, e.g.
+	// "ghcr.io/example/worldedit:7.3.0". The artifact must hold a single file.
+	// +kubebuilder:validation:Pattern=`^\S+$`
+	// +optional
+	OCI string `json:"oci,omitempty"`
+
+	// Checksum is the SHA-256 digest the artifact is verified against, e.g.
+	// "sha256:<hex>". Artifacts with a checksum are only downloaded again when
+	// the installed file does not match it.
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// DeletionPolicy describes what happens to the worlds of a Minecraft instance
+// when it is deleted.
+// +kubebuilder:validation:Enum=Delete;Retain;Snapshot
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the world volumes with the instance.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the world volumes, a new instance with the
+	// same name picks them up again.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+	// DeletionPolicySnapshot takes a final MinecraftBackup of the worlds onto
+	// the target of the backup schedule, which outlives the instance. The world
+	// volumes are deleted afterwards, unless the archives are stored on them.
+	DeletionPolicySnapshot DeletionPolicy = "Snapshot"
+)
+
+// Edition is the edition of Minecraft a server runs
+// +kubebuilder:validation:Enum=Java;Bedrock
+type Edition string
+
+const (
+	// EditionJava is Minecraft: Java Edition, played over TCP on port 25565
+	EditionJava Edition = "Java"
+	// EditionBedrock is Minecraft: Bedrock Edition, played over UDP on port 19132
+	EditionBedrock Edition = "Bedrock"
+)
+
+// ServerType is the server software which runs the world
+// +kubebuilder:validation:Enum=Vanilla;Paper;Spigot;Purpur;Fabric;Forge;NeoForge;Quilt
+type ServerType string
+
+const (
+	// ServerTypeVanilla is the official server released by Mojang
+	ServerTypeVanilla ServerType = "Vanilla"
+	// ServerTypePaper is the Paper fork of Spigot
+	ServerTypePaper ServerType = "Paper"
+	// ServerTypeSpigot is the Spigot plugin server
+	ServerTypeSpigot ServerType = "Spigot"
+	// ServerTypePurpur is the Purpur fork of Paper
+	ServerTypePurpur ServerType = "Purpur"
+	// ServerTypeFabric is the vanilla server with the Fabric mod loader
+	ServerTypeFabric ServerType = "Fabric"
+	// ServerTypeForge is the vanilla server with the Forge mod loader
+	ServerTypeForge ServerType = "Forge"
+	// ServerTypeNeoForge is the vanilla server with the NeoForge mod loader
+	ServerTypeNeoForge ServerType = "NeoForge"
+	// ServerTypeQuilt is the vanilla server with the Quilt mod loader
+	ServerTypeQuilt ServerType = "Quilt"
+)
+
+// ServerTypeOptions defines settings specific to a server type. The latest
+// release compatible with the Minecraft version is used for anything not set.
+type ServerTypeOptions struct {
+	// LoaderVersion is the version of the Fabric or Quilt loader.
+	// +optional
+	LoaderVersion string `json:"loaderVersion,omitempty"`
+
+	// InstallerVersion is the version of the Forge or NeoForge installer.
+	// +optional
+	InstallerVersion string `json:"installerVersion,omitempty"`
+
+	// Build is the Paper or Purpur build to run.
+	// +optional
+	Build string `json:"build,omitempty"`
+}
+
+// Difficulty is the difficulty of the world
+// +kubebuilder:validation:Enum=Peaceful;Easy;Normal;Hard
+type Difficulty string
+
+// Difficulties supported by the server
+const (
+	DifficultyPeaceful Difficulty = "Peaceful"
+	DifficultyEasy     Difficulty = "Easy"
+	DifficultyNormal   Difficulty = "Normal"
+	DifficultyHard     Difficulty = "Hard"
+)
+
+// GameMode is the default game mode of players joining the world
+// +kubebuilder:validation:Enum=Survival;Creative;Adventure;Spectator
+type GameMode string
+
+// Game modes supported by the server
+const (
+	GameModeSurvival  GameMode = "Survival"
+	GameModeCreative  GameMode = "Creative"
+	GameModeAdventure GameMode = "Adventure"
+	GameModeSpectator GameMode = "Spectator"
+)
+
+// ServerProperties defines the server.properties settings of a Minecraft instance.
+// Fields which are not set keep the default of the server software.
+type ServerProperties struct {
+	// EULA states that the Minecraft End User License Agreement
+	// (https://aka.ms/MinecraftEULA) is accepted. It must be set to true
+	// explicitly, the servers are not started otherwise. It is set once on
+	// instances created by previous versions of the operator which accepted it
+	// in the minecraft-config ConfigMap.
+	// +optional
+	EULA *bool `json:"eula,omitempty"`
+
+	// Difficulty is the difficulty of the world.
+	// +optional
+	Difficulty Difficulty `json:"difficulty,omitempty"`
+
+	// GameMode is the default game mode of players.
+	// +optional
+	GameMode GameMode `json:"gameMode,omitempty"`
+
+	// MOTD is the message shown in the server list.
+	// +optional
+	MOTD string `json:"motd,omitempty"`
+
+	// MaxPlayers is the maximum number of players online at the same time.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxPlayers *int32 `json:"maxPlayers,omitempty"`
+
+	// ViewDistance is the number of chunks sent to the clients in each direction.
+	// +kubebuilder:validation:Minimum=3
+	// +kubebuilder:validation:Maximum=32
+	// +optional
+	ViewDistance *int32 `json:"viewDistance,omitempty"`
+
+	// LevelName is the name of the world directory.
+	// +optional
+	LevelName string `json:"levelName,omitempty"`
+
+	// LevelSeed is the seed used to generate a new world.
+	// +optional
+	LevelSeed string `json:"levelSeed,omitempty"`
+
+	// OnlineMode makes the server authenticate players against the Mojang servers.
+	// +optional
+	OnlineMode *bool `json:"onlineMode,omitempty"`
+
+	// PVP allows players to damage each other.
+	// +optional
+	PVP *bool `json:"pvp,omitempty"`
+
+	// Hardcore deletes the world when the player dies.
+	// +optional
+	Hardcore *bool `json:"hardcore,omitempty"`
+
+	// AllowFlight allows players to fly in survival mode.
+	// +optional
+	AllowFlight *bool `json:"allowFlight,omitempty"`
+}
+
+// ServiceSpec defines the Service exposing a Minecraft instance
+// +kubebuilder:validation:XValidation:rule="!has(self.nodePort) || self.type != 'ClusterIP'",message="nodePort requires the NodePort or LoadBalancer service type"
+// +kubebuilder:validation:XValidation:rule="!has(self.loadBalancerIP) || self.type == 'LoadBalancer'",message="loadBalancerIP requires the LoadBalancer service type"
+// +kubebuilder:validation:XValidation:rule="!has(self.externalTrafficPolicy) || self.type != 'ClusterIP'",message="externalTrafficPolicy requires the NodePort or LoadBalancer service type"
+type ServiceSpec struct {
+	// Type determines how the Service is exposed.
+	// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+	// +kubebuilder:default=ClusterIP
+	// +optional
+	Type corev1.ServiceType `json:"type,omitempty"`
+
+	// Port is the port players connect to. Defaults to 25565 for the Java edition
+	// and 19132 for the Bedrock edition.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+
+	// NodePort is the port exposed on every node when the type is NodePort or
+	// LoadBalancer. A port is allocated by the cluster when it is not set.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// LoadBalancerIP requests a specific address from the load balancer
+	// implementation when the type is LoadBalancer.
+	// +optional
+	LoadBalancerIP string `json:"loadBalancerIP,omitempty"`
+
+	// ExternalTrafficPolicy defines whether external traffic is routed to node-local
+	// or cluster-wide endpoints. Local preserves the client address of the players.
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// Annotations are added to the Service, e.g. to configure a cloud load balancer.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// ExtraPorts are exposed by the Service and the server container in addition
+	// to the game port, e.g. for RCON, query or Bedrock clients through Geyser.
+	// +kubebuilder:validation:XValidation:rule="self.all(p, !p.name.startsWith('minecraft'))",message="port names starting with minecraft are reserved"
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	ExtraPorts []ServicePort `json:"extraPorts,omitempty"`
+}
+
+// ServicePort defines an additional port exposed by a Minecraft instance
+type ServicePort struct {
+	// Name of the port, unique within the Service.
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// Port exposed by the Service.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// TargetPort is the port the server listens on in the container. Defaults to Port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	TargetPort int32 `json:"targetPort,omitempty"`
+
+	// Protocol of the port.
+	// +kubebuilder:validation:Enum=TCP;UDP
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// NodePort is the port exposed on every node when the Service type is NodePort
+	// or LoadBalancer.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// StorageRetainPolicy describes what happens to the world volumes when the
+// StatefulSet is deleted or scaled down.
+// +kubebuilder:validation:Enum=Retain;Delete
+type StorageRetainPolicy string
+
+const (
+	// StorageRetainPolicyRetain keeps the PersistentVolumeClaims around so the
+	// world can be recovered after the instance is removed.
+	StorageRetainPolicyRetain StorageRetainPolicy = "Retain"
+	// StorageRetainPolicyDelete removes the PersistentVolumeClaims together with
+	// the pods which used them.
+	StorageRetainPolicyDelete StorageRetainPolicy = "Delete"
+)
+
+// StorageSpec defines the persistent storage of a Minecraft instance
+type StorageSpec struct {
+	// Size is the requested capacity of the world volume.
+	// +kubebuilder:default="10Gi"
+	// +optional
+	Size resource.Quantity `json:"size,omitempty"`
+
+	// StorageClassName is the name of the StorageClass used to provision the
+	// world volume. The cluster default is used when it is not set.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessMode is the access mode requested for the world volume.
+	// +kubebuilder:validation:Enum=ReadWriteOnce;ReadWriteOncePod;ReadWriteMany
+	// +kubebuilder:default=ReadWriteOnce
+	// +optional
+	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+
+	// RetainPolicy defines whether the world volumes are kept or deleted when
+	// the Minecraft instance is deleted or scaled down.
+	// +kubebuilder:default=Retain
+	// +optional
+	RetainPolicy StorageRetainPolicy `json:"retainPolicy,omitempty"`
+}
+
+// ProbeType is how the kubelet checks the server container
+// +kubebuilder:validation:Enum=Exec;TCP
+type ProbeType string
+
+const (
+	// ProbeTypeExec queries the server from within the container with the health
+	// check of the image, which performs a status request like a game client
+	ProbeTypeExec ProbeType = "Exec"
+	// ProbeTypeTCP only opens a connection to the game port
+	ProbeTypeTCP ProbeType = "TCP"
+)
+
+// ProbesSpec defines the startup, readiness and liveness probes of the server
+type ProbesSpec struct {
+	// Type is how the server is checked. The Bedrock edition only supports Exec.
+	// +kubebuilder:default=Exec
+	// +optional
+	Type ProbeType `json:"type,omitempty"`
+
+	// StartupTimeoutSeconds is how long the server may take to start, e.g. while
+	// generating the world, before it is restarted.
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:default=600
+	// +optional
+	StartupTimeoutSeconds int32 `json:"startupTimeoutSeconds,omitempty"`
+
+	// PeriodSeconds is how often the started server is checked.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// FailureThreshold is the number of consecutive failed checks after which the
+	// server stops receiving players and, unless DisableLiveness is set, is restarted.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+
+	// DisableLiveness turns off restarting an unresponsive server, e.g. while
+	// debugging it.
+	// +optional
+	DisableLiveness bool `json:"disableLiveness,omitempty"`
+}
+
+// DefaultHeapPercentage is the share of the memory limit of the server
+// container given to the heap when the spec does not set one
+const DefaultHeapPercentage = 75
+
+// JVMFlags is a preset of flags passed to the JVM
+// +kubebuilder:validation:Enum=None;Aikar
+type JVMFlags string
+
+const (
+	// JVMFlagsNone only passes the heap sizes and the flags of the spec
+	JVMFlagsNone JVMFlags = "None"
+	// JVMFlagsAikar passes the flags tuning the G1 garbage collector for
+	// Minecraft servers, known as Aikar's flags
+	// More info: https://docs.papermc.io/paper/aikars-flags
+	JVMFlagsAikar JVMFlags = "Aikar"
+)
+
+// GarbageCollector is the garbage collector of the JVM
+// +kubebuilder:validation:Enum=G1;ZGC;Shenandoah;Parallel
+type GarbageCollector string
+
+const (
+	// GarbageCollectorG1 is the default collector of the JVM
+	GarbageCollectorG1 GarbageCollector = "G1"
+	// GarbageCollectorZGC keeps pauses short on large heaps, at the cost of
+	// more CPU and memory
+	GarbageCollectorZGC GarbageCollector = "ZGC"
+	// GarbageCollectorShenandoah keeps pauses short like ZGC, it is only
+	// available in some builds of the JVM
+	GarbageCollectorShenandoah GarbageCollector = "Shenandoah"
+	// GarbageCollectorParallel maximizes throughput with longer pauses
+	GarbageCollectorParallel GarbageCollector = "Parallel"
+)
+
+// JVMSpec defines the heap and the flags of the JVM running a Java Edition server
+// +kubebuilder:validation:XValidation:rule="!has(self.flags) || self.flags != 'Aikar' || !has(self.gc) || self.gc == 'G1'",message="Aikar's flags require the G1 garbage collector"
+type JVMSpec struct {
+	// MinHeap is the initial size of the heap (-Xms). Defaults to the maximum
+	// size, so the heap never has to grow.
+	// +optional
+	MinHeap *resource.Quantity `json:"minHeap,omitempty"`
+
+	// MaxHeap is the maximum size of the heap (-Xmx). Defaults to
+	// HeapPercentage of the memory limit of the container, or to the default of
+	// the image when no limit is set. It may not exceed the memory limit.
+	// +optional
+	MaxHeap *resource.Quantity `json:"maxHeap,omitempty"`
+
+	// HeapPercentage is the share of the memory limit given to the heap when
+	// MaxHeap is not set. The rest is left to the memory the JVM uses outside
+	// of the heap, like the metaspace, the thread stacks and direct buffers.
+	// +kubebuilder:validation:Minimum=10
+	// +kubebuilder:validation:Maximum=100
+	// +kubebuilder:default=75
+	// +optional
+	HeapPercentage int32 `json:"heapPercentage,omitempty"`
+
+	// Flags is a preset of flags tuning the JVM.
+	// +kubebuilder:default=None
+	// +optional
+	Flags JVMFlags `json:"flags,omitempty"`
+
+	// GC is the garbage collector. The default of the JVM is used when it is
+	// not set, which is G1.
+	// +optional
+	GC GarbageCollector `json:"gc,omitempty"`
+
+	// ExtraArgs are passed to the JVM after the other flags, e.g.
+	// "-Dlog4j2.formatMsgNoLookups=true".
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+}
+
+// ShutdownSpec defines how the servers of a Minecraft instance are stopped
+// +kubebuilder:validation:XValidation:rule="!has(self.countdownSeconds) || !has(self.gracePeriodSeconds) || self.countdownSeconds < self.gracePeriodSeconds",message="the countdown must end before the grace period to leave time to save the worlds"
+type ShutdownSpec struct {
+	// GracePeriodSeconds is how long a server pod is given to stop before it
+	// is killed, set as the termination grace period of the pods. It must cover
+	// the countdown and the time the server takes to save its worlds.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=60
+	// +optional
+	GracePeriodSeconds int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// CountdownSeconds is how long the players are warned before the server
+	// stops. The remaining time is broadcast when the countdown starts, then at
+	// 60, 30 and 10 seconds and every second of the last 5. 0 stops the server
+	// without warning the players.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=10
+	// +optional
+	CountdownSeconds *int32 `json:"countdownSeconds,omitempty"`
+
+	// Message is broadcast to the players during the countdown, followed by the
+	// remaining time.
+	// +kubebuilder:validation:MaxLength=100
+	// +kubebuilder:default="Server shutting down"
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// MaintenancePolicy defines when the changes restarting the servers are applied
+// +kubebuilder:validation:Enum=Immediate;WindowOnly;WhenEmpty
+type MaintenancePolicy string
+
+const (
+	// MaintenancePolicyImmediate applies the changes as soon as they are made
+	MaintenancePolicyImmediate MaintenancePolicy = "Immediate"
+	// MaintenancePolicyWindowOnly applies the changes during the maintenance
+	// windows only
+	MaintenancePolicyWindowOnly MaintenancePolicy = "WindowOnly"
+	// MaintenancePolicyWhenEmpty applies the changes during the maintenance
+	// windows, or as soon as no player is online
+	MaintenancePolicyWhenEmpty MaintenancePolicy = "WhenEmpty"
+)
+
+// MaintenanceSpec defines when the servers of a Minecraft instance may be
+// restarted to apply changes
+// +kubebuilder:validation:XValidation:rule="!has(self.policy) || self.policy != 'WindowOnly' || (has(self.windows) && size(self.windows) > 0)",message="the WindowOnly policy requires a maintenance window"
+type MaintenanceSpec struct {
+	// Policy defines when the changes restarting the servers are applied.
+	// Servers which are not running, e.g. sleeping or failing to start, are
+	// restarted right away whatever the policy.
+	// +kubebuilder:default=Immediate
+	// +optional
+	Policy MaintenancePolicy `json:"policy,omitempty"`
+
+	// Windows are the periods during which the servers may be restarted.
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	Windows []MaintenanceWindow `json:"windows,omitempty"`
+}
+
+// MaintenanceWindow is a recurring period during which the servers may be
+// restarted
+type MaintenanceWindow struct {
+	// Schedule is the cron expression of when the window opens, e.g.
+	// "0 4 * * 1" for every Monday at 4am. The time zone of the operator
+	// applies unless the expression starts with CRON_TZ=<zone>.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// Duration is how long the window stays open.
+	// +kubebuilder:default="1h"
+	// +optional
+	Duration metav1.Duration `json:"duration,omitempty"`
+}
+
+// MinecraftPhase is a summary of where the servers are in their lifecycle
+// +kubebuilder:validation:Enum=Pending;Starting;Running;Stopping;Stopped;Sleeping;Failed
+type MinecraftPhase string
+
+const (
+	// MinecraftPhasePending means the server pods are not running yet, e.g. while
+	// they are scheduled, the image is pulled or the world volume is provisioned
+	MinecraftPhasePending MinecraftPhase = "Pending"
+	// MinecraftPhaseStarting means a server is running but does not accept
+	// players yet, e.g. while it generates the world
+	MinecraftPhaseStarting MinecraftPhase = "Starting"
+	// MinecraftPhaseRunning means a server accepts players
+	MinecraftPhaseRunning MinecraftPhase = "Running"
+	// MinecraftPhaseStopping means the servers are shutting down
+	MinecraftPhaseStopping MinecraftPhase = "Stopping"
+	// MinecraftPhaseStopped means no server is running
+	MinecraftPhaseStopped MinecraftPhase = "Stopped"
+	// MinecraftPhaseSleeping means the servers were stopped by autoPause since
+	// no player was online, until they are woken up
+	MinecraftPhaseSleeping MinecraftPhase = "Sleeping"
+	// MinecraftPhaseFailed means the servers can not run without intervention,
+	// e.g. because they crash or their image can not be pulled
+	MinecraftPhaseFailed MinecraftPhase = "Failed"
+)
+
+// MinecraftStatus defines the observed state of Minecraft
+type MinecraftStatus struct {
+	// Represents the observations of a Minecraft's current state.
+	// Minecraft.status.conditions.type are: "Available", "Progressing", and "Degraded"
+	// Minecraft.status.conditions.status are one of True, False, Unknown.
+	// Minecraft.status.conditions.reason the value should be a CamelCase string and producers of specific
+	// condition types may define expected values and meanings for this field, and whether the values
+	// are considered a guaranteed API.
+	// Minecraft.status.conditions.Message is a human readable message indicating details about the transition.
+	// For further information see: https://github.com/kubernetes/community/blob/master/contributors/devel/sig-architecture/api-conventions.md#typical-status-properties
+
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// Phase summarizes where the servers are in their lifecycle.
+	// +optional
+	Phase MinecraftPhase `json:"phase,omitempty"`
+
+	// ObservedGeneration is the generation of the spec the status was computed for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of servers which pass their readiness probe.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Address is the host and port players connect to. It is the external address
+	// of the load balancer or node when the server is exposed outside of the
+	// cluster, and the cluster DNS name of the Service otherwise.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// LastBackupTime is when the last backup of the world completed.
+	// +optional
+	LastBackupTime *metav1.Time `json:"lastBackupTime,omitempty"`
+
+	// IdleSince is when the last player left the servers, or when they became
+	// available without any player online. It is only tracked with autoPause.
+	// +optional
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+
+	// LastScheduleTime is when the last scheduled backup was created.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// CurrentVersion is the Minecraft version all instances were rolled out with.
+	// A different spec.version is rolled out after the world has been backed up.
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// ServerVersion is the version reported by the running server.
+	// +optional
+	ServerVersion string `json:"serverVersion,omitempty"`
+
+	// MOTD is the message of the day reported by the running server.
+	// +optional
+	MOTD string `json:"motd,omitempty"`
+
+	// OnlinePlayers is the number of players on the server.
+	// +optional
+	OnlinePlayers int32 `json:"onlinePlayers,omitempty"`
+
+	// MaxPlayers is the number of players the server accepts.
+	// +optional
+	MaxPlayers int32 `json:"maxPlayers,omitempty"`
+
+	// Artifacts lists the plugins and mods installed on each server when it
+	// last started.
+	// +optional
+	Artifacts []ServerArtifacts `json:"artifacts,omitempty"`
+}
+
+// ServerArtifacts lists the plugins and mods installed on a server
+type ServerArtifacts struct {
+	// Server is the name of the pod of the server.
+	Server string `json:"server"`
+
+	// Artifacts are the installed files.
+	// +optional
+	Artifacts []InstalledArtifact `json:"artifacts,omitempty"`
+
+	// Truncated is set when the server installed more files than it could
+	// report, Artifacts then lists only part of them. The full list is kept in
+	// the .managed file of the plugins and mods directories.
+	// +optional
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// InstalledArtifact is a plugin or mod installed on a server
+type InstalledArtifact struct {
+	// Path is the path of the file relative to /data, e.g. "plugins/worldedit.jar".
+	Path string `json:"path"`
+
+	// Checksum is the SHA-256 digest of the installed file.
+	Checksum string `json:"checksum"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Edition",type=string,JSONPath=`.spec.server.edition`,priority=1
+// +kubebuilder:printcolumn:name="Version",type=string,JSONPath=`.status.serverVersion`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Players",type=integer,JSONPath=`.status.onlinePlayers`
+// +kubebuilder:printcolumn:name="Max Players",type=integer,JSONPath=`.status.maxPlayers`,priority=1
+// +kubebuilder:printcolumn:name="Address",type=string,JSONPath=`.status.address`
+// +kubebuilder:printcolumn:name="Last Backup",type=date,JSONPath=`.status.lastBackupTime`,priority=1
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// Minecraft is the Schema for the minecrafts API
+type Minecraft struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MinecraftSpec   `json:"spec,omitempty"`
+	Status MinecraftStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// MinecraftList contains a list of Minecraft
+type MinecraftList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Minecraft `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Minecraft{}, &MinecraftList{})
+}
@@ -31,12 +31,17 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	cachev1beta1 "github.com/example/minecraft-operator/api/v1beta1"
 	"github.com/example/minecraft-operator/internal/controller"
+	"github.com/example/minecraft-operator/internal/gateway"
+	webhookcachev1alpha1 "github.com/example/minecraft-operator/internal/webhook/v1alpha1"
+	webhookcachev1beta1 "github.com/example/minecraft-operator/internal/webhook/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -49,6 +54,7 @@ func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
 	utilruntime.Must(cachev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(cachev1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -58,6 +64,7 @@ func main() {
 	var probeAddr string
 	var secureMetrics bool
 	var enableHTTP2 bool
+	var gatewayAddr string
 	var tlsOpts []func(*tls.Config)
 	flag.StringVar(&metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or :8080 for HTTP, or leave as 0 to disable the metrics service.")
@@ -69,6 +76,8 @@ func main() {
 		"If set, the metrics endpoint is served securely via HTTPS. Use --metrics-secure=false to use HTTP instead.")
 	flag.BoolVar(&enableHTTP2, "enable-http2", false,
 		"If set, HTTP/2 will be enabled for the metrics and webhook servers")
+	flag.StringVar(&gatewayAddr, "gateway-bind-address", "0", "The address the gateway fronting the "+
+		"instances with autoPause binds to. Use :25565 to serve it, or leave as 0 to disable the gateway.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -152,8 +161,58 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "Minecraft")
 		os.Exit(1)
 	}
+	if err = (&controller.MinecraftBackupReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("minecraftbackup-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MinecraftBackup")
+		os.Exit(1)
+	}
+	if err = (&controller.MinecraftRestoreReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("minecraftrestore-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MinecraftRestore")
+		os.Exit(1)
+	}
+	if err = (&controller.MinecraftProxyReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("minecraftproxy-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MinecraftProxy")
+		os.Exit(1)
+	}
+	// nolint:goconst
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = webhookcachev1alpha1.SetupMinecraftWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Minecraft")
+			os.Exit(1)
+		}
+		if err = webhookcachev1beta1.SetupMinecraftWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Minecraft")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
+	// Export the players, backups and worlds of the Minecraft instances next to
+	// the metrics of the controllers
+	metrics.Registry.MustRegister(&controller.MinecraftCollector{Reader: mgr.GetClient()})
+
+	if gatewayAddr != "0" {
+		if err = (&gateway.Gateway{
+			Client: mgr.GetClient(),
+			Addr:   gatewayAddr,
+			Log:    ctrl.Log.WithName("gateway"),
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to set up the gateway")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
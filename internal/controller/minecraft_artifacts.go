@@ -0,0 +1,287 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// artifactsContainerName is the name of the init container installing the
+	// plugins and mods
+	artifactsContainerName = "artifacts"
+	// artifactSourcesVolumeName is the name of the volume holding the artifacts
+	// read from ConfigMaps and Secrets
+	artifactSourcesVolumeName = "artifact-sources"
+	// artifactSourcesMountPath is where the artifacts read from ConfigMaps and
+	// Secrets are mounted in the init container
+	artifactSourcesMountPath = "/sources"
+	// pluginsDirectory is the directory of the plugins, relative to the world volume
+	pluginsDirectory = "plugins"
+	// modsDirectory is the directory of the mods, relative to the world volume
+	modsDirectory = "mods"
+)
+
+// artifactsScript installs the artifacts listed in ARTIFACTS, one per line as
+// "<directory> <name> <source> <location> [<checksum>]". The files installed
+// by the operator are recorded in a .managed file per directory, so the ones
+// removed from the spec are deleted without touching the others. The installed
+// files and their checksums are reported as the termination message, which
+// the operator records in the status. The kubelet keeps only the last 4096
+// bytes of it, so the message starts with the number of files, telling a
+// complete report from a truncated one.
+const artifactsScript = `set -eu
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+for dir in plugins mods; do
+  mkdir -p "/data/$dir"
+  touch "/data/$dir/.managed"
+  : > "$tmp/$dir.managed"
+done
+
+while read -r dir name source location checksum; do
+  [ -n "$dir" ] || continue
+  echo "$name" >> "$tmp/$dir.managed"
+  dest="/data/$dir/$name"
+  if [ -n "$checksum" ] && [ -f "$dest" ] && echo "$checksum  $dest" | sha256sum -c - >/dev/null 2>&1; then
+    continue
+  fi
+  echo "Installing $dir/$name from $source"
+  case "$source" in
+    url)
+      wget -q -O "$tmp/$name" "$location"
+      ;;
+    oci)
+      mkdir "$tmp/oci"
+      oras pull -o "$tmp/oci" "$location"
+      file=$(find "$tmp/oci" -type f | head -n 1)
+      [ -n "$file" ]
+      mv "$file" "$tmp/$name"
+      rm -rf "$tmp/oci"
+      ;;
+    file)
+      cp "$location" "$tmp/$name"
+      ;;
+  esac
+  if [ -n "$checksum" ] && ! echo "$checksum  $tmp/$name" | sha256sum -c - >/dev/null 2>&1; then
+    echo "Checksum mismatch of $dir/$name" | tee /dev/termination-log >&2
+    exit 1
+  fi
+  mv "$tmp/$name" "$dest"
+done <<EOF
+$ARTIFACTS
+EOF
+
+for dir in plugins mods; do
+  while read -r name; do
+    [ -n "$name" ] || continue
+    if ! grep -qxF "$name" "$tmp/$dir.managed"; then
+      echo "Removing $dir/$name"
+      rm -f "/data/$dir/$name"
+    fi
+  done < "/data/$dir/.managed"
+  cp "$tmp/$dir.managed" "/data/$dir/.managed"
+done
+
+{
+  echo "# $(cat /data/plugins/.managed /data/mods/.managed | grep -c . || true)"
+  for dir in plugins mods; do
+    while read -r name; do
+      echo "$dir/$name $(sha256sum "/data/$dir/$name" | cut -d ' ' -f 1)"
+    done < "/data/$dir/.managed"
+  done
+} > /dev/termination-log
+`
+
+// installsArtifacts returns whether the servers of the custom resource run the
+// init container. Once the existing StatefulSet runs it, it keeps running after
+// the lists are emptied. It removes the artifacts it installed before, and
+// dropping it afterwards would restart the servers a second time.
+func installsArtifacts(minecraft *cachev1alpha1.Minecraft, found *appsv1.StatefulSet) bool {
+	if isBedrock(minecraft) {
+		return false
+	}
+	return len(minecraft.Spec.Plugins) > 0 || len(minecraft.Spec.Mods) > 0 || artifactsConfigured(found)
+}
+
+// artifactsConfigured returns whether the servers of the StatefulSet run the
+// init container installing the artifacts
+func artifactsConfigured(sts *appsv1.StatefulSet) bool {
+	if sts == nil {
+		return false
+	}
+	for _, c := range sts.Spec.Template.Spec.InitContainers {
+		if c.Name == artifactsContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// artifactsForMinecraft returns the artifacts of the spec by the directory
+// they are installed into
+func artifactsForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string][]cachev1alpha1.Artifact {
+	return map[string][]cachev1alpha1.Artifact{
+		pluginsDirectory: minecraft.Spec.Plugins,
+		modsDirectory:    minecraft.Spec.Mods,
+	}
+}
+
+// artifactsManifestForMinecraft renders the artifacts of the spec into the
+// lines read by the init container
+func artifactsManifestForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	var lines []string
+	for _, dir := range []string{pluginsDirectory, modsDirectory} {
+		for _, a := range artifactsForMinecraft(minecraft)[dir] {
+			source, location := "url", a.URL
+			switch {
+			case a.OCI != "":
+				source, location = "oci", a.OCI
+			case a.ConfigMap != nil || a.Secret != nil:
+				source, location = "file", path.Join(artifactSourcesMountPath, dir, a.Name)
+			}
+			lines = append(lines, strings.TrimSpace(strings.Join([]string{
+				dir, a.Name, source, location, strings.TrimPrefix(a.Checksum, "sha256:"),
+			}, " ")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// artifactSourcesForMinecraft returns the projections of the ConfigMaps and
+// Secrets holding artifacts, each key mapped to the path the init container
+// copies it from
+func artifactSourcesForMinecraft(minecraft *cachev1alpha1.Minecraft) []corev1.VolumeProjection {
+	var sources []corev1.VolumeProjection
+	for _, dir := range []string{pluginsDirectory, modsDirectory} {
+		for _, a := range artifactsForMinecraft(minecraft)[dir] {
+			items := []corev1.KeyToPath{{Path: path.Join(dir, a.Name)}}
+			switch {
+			case a.ConfigMap != nil:
+				items[0].Key = a.ConfigMap.Key
+				sources = append(sources, corev1.VolumeProjection{ConfigMap: &corev1.ConfigMapProjection{
+					LocalObjectReference: a.ConfigMap.LocalObjectReference,
+					Items:                items,
+				}})
+			case a.Secret != nil:
+				items[0].Key = a.Secret.Key
+				sources = append(sources, corev1.VolumeProjection{Secret: &corev1.SecretProjection{
+					LocalObjectReference: a.Secret.LocalObjectReference,
+					Items:                items,
+				}})
+			}
+		}
+	}
+	return sources
+}
+
+// initContainersForMinecraft returns the init container installing the
+// plugins and mods into the world volume
+func initContainersForMinecraft(minecraft *cachev1alpha1.Minecraft,
+	found *appsv1.StatefulSet) ([]corev1.Container, error) {
+	if !installsArtifacts(minecraft, found) {
+		return nil, nil
+	}
+	image, err := imageForArtifacts()
+	if err != nil {
+		return nil, err
+	}
+
+	mounts := []corev1.VolumeMount{{
+		Name:      worldVolumeName,
+		MountPath: worldMountPath,
+	}}
+	if len(artifactSourcesForMinecraft(minecraft)) > 0 {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      artifactSourcesVolumeName,
+			MountPath: artifactSourcesMountPath,
+			ReadOnly:  true,
+		})
+	}
+	return []corev1.Container{{
+		Name:            artifactsContainerName,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c", artifactsScript},
+		Env:             []corev1.EnvVar{{Name: "ARTIFACTS", Value: artifactsManifestForMinecraft(minecraft)}},
+		VolumeMounts:    mounts,
+	}}, nil
+}
+
+// artifactsForPods returns the artifacts the init container of each server
+// pod reported when it last completed. A report missing its leading count was
+// truncated, its first line may be cut as well.
+func artifactsForPods(pods []corev1.Pod) []cachev1alpha1.ServerArtifacts {
+	var servers []cachev1alpha1.ServerArtifacts
+	for _, pod := range pods {
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name != artifactsContainerName {
+				continue
+			}
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.ExitCode != 0 {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil || terminated.ExitCode != 0 {
+				continue
+			}
+			server := cachev1alpha1.ServerArtifacts{Server: pod.Name}
+			lines := strings.Split(strings.TrimSpace(terminated.Message), "\n")
+			count := -1
+			if n, found := strings.CutPrefix(lines[0], "# "); found {
+				if c, err := strconv.Atoi(n); err == nil {
+					count = c
+				}
+			}
+			for _, line := range lines[1:] {
+				file, sum, found := strings.Cut(strings.TrimSpace(line), " ")
+				if !found {
+					continue
+				}
+				server.Artifacts = append(server.Artifacts, cachev1alpha1.InstalledArtifact{
+					Path:     file,
+					Checksum: "sha256:" + sum,
+				})
+			}
+			server.Truncated = count != len(server.Artifacts)
+			servers = append(servers, server)
+		}
+	}
+	return servers
+}
+
+// imageForArtifacts gets the image of the init container installing the
+// artifacts from the ARTIFACT_IMAGE environment variable defined in the
+// config/manager/manager.yaml. It must provide a shell, wget, sha256sum and oras.
+func imageForArtifacts() (string, error) {
+	var imageEnvVar = "ARTIFACT_IMAGE"
+	image, found := os.LookupEnv(imageEnvVar)
+	if !found {
+		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
+	}
+	return image, nil
+}
@@ -0,0 +1,111 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// defaultIdleTimeout is how long the servers stay idle when the spec does not set it
+const defaultIdleTimeout = 15 * time.Minute
+
+// isSleeping returns whether autoPause stopped the servers of the custom resource
+func isSleeping(minecraft *cachev1alpha1.Minecraft) bool {
+	return minecraft.Spec.AutoPause != nil && minecraft.Status.Phase == cachev1alpha1.MinecraftPhaseSleeping
+}
+
+// idleTimeoutForMinecraft returns how long no player must be online before
+// the servers are stopped
+func idleTimeoutForMinecraft(minecraft *cachev1alpha1.Minecraft) time.Duration {
+	if timeout := minecraft.Spec.AutoPause.IdleTimeout.Duration; timeout > 0 {
+		return timeout
+	}
+	return defaultIdleTimeout
+}
+
+// wakeUp handles the wake annotation of the custom resource. Sleeping servers
+// are started again on the next rendering of the StatefulSet. The status is
+// updated right away, so the servers are not put back to sleep when the
+// reconciliation fails before updating it.
+func (r *MinecraftReconciler) wakeUp(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	if _, found := minecraft.Annotations[cachev1alpha1.WakeAnnotation]; !found {
+		return nil
+	}
+	patch := client.MergeFrom(minecraft.DeepCopy())
+	delete(minecraft.Annotations, cachev1alpha1.WakeAnnotation)
+	if err := r.Patch(ctx, minecraft, patch); err != nil {
+		return err
+	}
+
+	if isSleeping(minecraft) {
+		log.Info("Waking up the servers of Minecraft")
+		minecraft.Status.Phase = cachev1alpha1.MinecraftPhasePending
+		r.Recorder.Event(minecraft, "Normal", "WakingUp", "Starting the sleeping servers")
+	}
+	minecraft.Status.IdleSince = nil
+	return r.Status().Update(ctx, minecraft)
+}
+
+// reconcileAutoPause tracks since when no player is online on the available
+// servers, and puts them to sleep once the idle timeout elapsed, after saving
+// their worlds. It returns how long until the servers would go to sleep, or
+// zero when they are not idle.
+func (r *MinecraftReconciler) reconcileAutoPause(ctx context.Context, minecraft *cachev1alpha1.Minecraft,
+	available bool) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	if minecraft.Spec.AutoPause == nil {
+		minecraft.Status.IdleSince = nil
+		return 0, nil
+	}
+	if isSleeping(minecraft) || !available {
+		return 0, nil
+	}
+	if minecraft.Status.OnlinePlayers > 0 {
+		minecraft.Status.IdleSince = nil
+		return 0, nil
+	}
+
+	now := time.Now()
+	if minecraft.Status.IdleSince == nil {
+		minecraft.Status.IdleSince = &metav1.Time{Time: now}
+	}
+	timeout := idleTimeoutForMinecraft(minecraft)
+	if idle := now.Sub(minecraft.Status.IdleSince.Time); idle < timeout {
+		return timeout - idle, nil
+	}
+
+	if err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-all flush"); err != nil {
+		return 0, err
+	}
+	log.Info("Putting the idle servers of Minecraft to sleep")
+	minecraft.Status.Phase = cachev1alpha1.MinecraftPhaseSleeping
+	r.Recorder.Event(minecraft, "Normal", "Sleeping",
+		fmt.Sprintf("No player online since %s, stopping the servers",
+			minecraft.Status.IdleSince.UTC().Format(time.RFC3339)))
+	return 0, nil
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// scheduledBackupLabel is set on the MinecraftBackups created by the backup
+	// schedule of a custom resource with its name
+	scheduledBackupLabel = "cache.example.com/scheduled-for"
+	// defaultBackupHistoryLimit is the number of scheduled backups kept when the
+	// spec does not set it
+	defaultBackupHistoryLimit = 7
+)
+
+// reconcileBackupSchedule creates the MinecraftBackup which is due according to
+// the backup schedule of the custom resource and returns how long to wait for
+// the next one, or zero when no backup is scheduled. Runs missed while the
+// operator was down are not caught up, only the latest one is taken.
+func (r *MinecraftReconciler) reconcileBackupSchedule(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft) (time.Duration, error) {
+	log := log.FromContext(ctx)
+
+	spec := minecraft.Spec.Backup
+	if spec == nil {
+		return 0, nil
+	}
+	schedule, err := cron.ParseStandard(spec.Schedule)
+	if err != nil {
+		r.Recorder.Event(minecraft, "Warning", "InvalidSchedule",
+			fmt.Sprintf("Backup schedule %q is invalid: %s", spec.Schedule, err))
+		return 0, nil
+	}
+
+	now := time.Now()
+	last := minecraft.CreationTimestamp.Time
+	if minecraft.Status.LastScheduleTime != nil {
+		last = minecraft.Status.LastScheduleTime.Time
+	}
+
+	var due time.Time
+	for t := schedule.Next(last); !t.After(now); t = schedule.Next(t) {
+		due = t
+	}
+	if !due.IsZero() {
+		backup := backupForSchedule(minecraft, due)
+		if err := ctrl.SetControllerReference(minecraft, backup, r.Scheme); err != nil {
+			return 0, err
+		}
+		log.Info("Creating scheduled backup", "MinecraftBackup.Name", backup.Name)
+		if err := r.Create(ctx, backup); err != nil && !apierrors.IsAlreadyExists(err) {
+			return 0, err
+		}
+		minecraft.Status.LastScheduleTime = &metav1.Time{Time: due}
+
+		if err := r.pruneScheduledBackups(ctx, minecraft); err != nil {
+			return 0, err
+		}
+		last = due
+	}
+
+	next := schedule.Next(last)
+	if !next.After(now) {
+		next = schedule.Next(now)
+	}
+	return next.Sub(now), nil
+}
+
+// backupForSchedule returns the MinecraftBackup taken at the given time of the
+// backup schedule
+func backupForSchedule(minecraft *cachev1alpha1.Minecraft, at time.Time) *cachev1alpha1.MinecraftBackup {
+	ls := labelsForMinecraft(minecraft)
+	ls[scheduledBackupLabel] = minecraft.Name
+	return &cachev1alpha1.MinecraftBackup{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", minecraft.Name, at.Unix()),
+			Namespace: minecraft.Namespace,
+			Labels:    ls,
+		},
+		Spec: cachev1alpha1.MinecraftBackupSpec{
+			MinecraftRef: corev1.LocalObjectReference{Name: minecraft.Name},
+			Target:       minecraft.Spec.Backup.Target,
+		},
+	}
+}
+
+// pruneScheduledBackups deletes the oldest finished scheduled backups beyond
+// the history limit. Their archives are left on the target.
+func (r *MinecraftReconciler) pruneScheduledBackups(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	limit := defaultBackupHistoryLimit
+	if minecraft.Spec.Backup.HistoryLimit != nil {
+		limit = int(*minecraft.Spec.Backup.HistoryLimit)
+	}
+
+	backups := &cachev1alpha1.MinecraftBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(minecraft.Namespace),
+		client.MatchingLabels{scheduledBackupLabel: minecraft.Name}); err != nil {
+		return err
+	}
+
+	var finished []cachev1alpha1.MinecraftBackup
+	for _, b := range backups.Items {
+		if b.Status.Phase == cachev1alpha1.BackupPhaseCompleted || b.Status.Phase == cachev1alpha1.BackupPhaseFailed {
+			finished = append(finished, b)
+		}
+	}
+	if len(finished) <= limit {
+		return nil
+	}
+
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreationTimestamp.Before(&finished[j].CreationTimestamp)
+	})
+	for i := range finished[:len(finished)-limit] {
+		if err := r.Delete(ctx, &finished[i]); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCompletedBackups records the completion time of the latest completed
+// MinecraftBackup of the custom resource as its last backup time
+func (r *MinecraftReconciler) recordCompletedBackups(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	backups := &cachev1alpha1.MinecraftBackupList{}
+	if err := r.List(ctx, backups, client.InNamespace(minecraft.Namespace)); err != nil {
+		return err
+	}
+	for _, b := range backups.Items {
+		if b.Spec.MinecraftRef.Name == minecraft.Name && b.Status.Phase == cachev1alpha1.BackupPhaseCompleted {
+			recordBackupTime(minecraft, b.Status.CompletionTime)
+		}
+	}
+	return nil
+}
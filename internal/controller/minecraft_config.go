@@ -0,0 +1,293 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// configHashAnnotation is set on the pod template with the hash of the rendered
+// server configuration, so a change of the configuration rolls the pods
+const configHashAnnotation = "cache.example.com/config-hash"
+
+// legacyConfigMapName is the ConfigMap shared by the instances of a namespace
+// which previous versions of the operator loaded as environment
+const legacyConfigMapName = "minecraft-config"
+
+// configMapNameForMinecraft returns the name of the ConfigMap holding the
+// server configuration of the custom resource
+func configMapNameForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	return fmt.Sprintf("%s-config", minecraft.Name)
+}
+
+// configMapForMinecraft returns the ConfigMap with the server configuration
+// which is loaded as environment of the server container
+func (r *MinecraftReconciler) configMapForMinecraft(
+	minecraft *cachev1alpha1.Minecraft) (*corev1.ConfigMap, error) {
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapNameForMinecraft(minecraft),
+			Namespace: minecraft.Namespace,
+			Labels:    labelsForMinecraft(minecraft),
+		},
+		Data: serverEnvForMinecraft(minecraft),
+	}
+
+	// Set the ownerRef for the ConfigMap
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(minecraft, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// serverEnvForMinecraft renders the spec of the custom resource into the
+// environment variables understood by the operand image
+// More info: https://docker-minecraft-server.readthedocs.io/en/latest/variables/
+func serverEnvForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string]string {
+	if isBedrock(minecraft) {
+		return bedrockServerEnvForMinecraft(minecraft)
+	}
+
+	spec := minecraft.Spec
+	config := spec.Config
+
+	env := map[string]string{
+		"EULA": strings.ToUpper(strconv.FormatBool(eulaAccepted(minecraft))),
+	}
+
+	env["VERSION"] = desiredVersionForMinecraft(minecraft)
+
+	serverType := spec.Type
+	if serverType == "" {
+		serverType = cachev1alpha1.ServerTypeVanilla
+	}
+	env["TYPE"] = strings.ToUpper(string(serverType))
+	for k, v := range typeOptionsEnvForMinecraft(serverType, spec.TypeOptions) {
+		env[k] = v
+	}
+
+	if config.Difficulty != "" {
+		env["DIFFICULTY"] = strings.ToLower(string(config.Difficulty))
+	}
+	if config.GameMode != "" {
+		env["MODE"] = strings.ToLower(string(config.GameMode))
+	}
+	setStringEnv(env, "MOTD", config.MOTD)
+	if config.MaxPlayers != nil {
+		env["MAX_PLAYERS"] = strconv.Itoa(int(*config.MaxPlayers))
+	}
+	if config.ViewDistance != nil {
+		env["VIEW_DISTANCE"] = strconv.Itoa(int(*config.ViewDistance))
+	}
+	setStringEnv(env, "LEVEL", config.LevelName)
+	setStringEnv(env, "SEED", config.LevelSeed)
+	setBoolEnv(env, "ONLINE_MODE", config.OnlineMode)
+	setBoolEnv(env, "PVP", config.PVP)
+	setBoolEnv(env, "HARDCORE", config.Hardcore)
+	setBoolEnv(env, "ALLOW_FLIGHT", config.AllowFlight)
+
+	for k, v := range jvmEnvForMinecraft(minecraft) {
+		env[k] = v
+	}
+	for k, v := range playersEnvForMinecraft(minecraft) {
+		env[k] = v
+	}
+
+	// The password is read from the RCON Secret by the server container
+	env["ENABLE_RCON"] = "TRUE"
+	env["RCON_PORT"] = strconv.Itoa(cachev1alpha1.RCONPort)
+
+	return env
+}
+
+// bedrockServerEnvForMinecraft renders the spec of the custom resource into the
+// environment variables understood by the Bedrock operand image. Bedrock has no
+// server types and its server.properties lacks the PVP, hardcore and flight keys.
+// More info: https://github.com/itzg/docker-minecraft-bedrock-server#server-properties
+func bedrockServerEnvForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string]string {
+	config := minecraft.Spec.Config
+
+	env := map[string]string{
+		"EULA":    strings.ToUpper(strconv.FormatBool(eulaAccepted(minecraft))),
+		"VERSION": desiredVersionForMinecraft(minecraft),
+	}
+
+	if config.Difficulty != "" {
+		env["DIFFICULTY"] = strings.ToLower(string(config.Difficulty))
+	}
+	if config.GameMode != "" {
+		env["GAMEMODE"] = strings.ToLower(string(config.GameMode))
+	}
+	setStringEnv(env, "SERVER_NAME", config.MOTD)
+	if config.MaxPlayers != nil {
+		env["MAX_PLAYERS"] = strconv.Itoa(int(*config.MaxPlayers))
+	}
+	if config.ViewDistance != nil {
+		env["VIEW_DISTANCE"] = strconv.Itoa(int(*config.ViewDistance))
+	}
+	setStringEnv(env, "LEVEL_NAME", config.LevelName)
+	setStringEnv(env, "LEVEL_SEED", config.LevelSeed)
+	setBoolEnv(env, "ONLINE_MODE", config.OnlineMode)
+
+	return env
+}
+
+// typeOptionsEnvForMinecraft renders the options of the server type into the
+// environment variables selecting the loader, installer or build to run
+func typeOptionsEnvForMinecraft(serverType cachev1alpha1.ServerType,
+	options cachev1alpha1.ServerTypeOptions) map[string]string {
+	env := map[string]string{}
+	switch serverType {
+	case cachev1alpha1.ServerTypeFabric:
+		setStringEnv(env, "FABRIC_LOADER_VERSION", options.LoaderVersion)
+	case cachev1alpha1.ServerTypeQuilt:
+		setStringEnv(env, "QUILT_LOADER_VERSION", options.LoaderVersion)
+	case cachev1alpha1.ServerTypeForge:
+		setStringEnv(env, "FORGE_VERSION", options.InstallerVersion)
+	case cachev1alpha1.ServerTypeNeoForge:
+		setStringEnv(env, "NEOFORGE_VERSION", options.InstallerVersion)
+	case cachev1alpha1.ServerTypePaper:
+		setStringEnv(env, "PAPER_BUILD", options.Build)
+	case cachev1alpha1.ServerTypePurpur:
+		setStringEnv(env, "PURPUR_BUILD", options.Build)
+	}
+	return env
+}
+
+// setStringEnv sets key to value when value is not empty
+func setStringEnv(env map[string]string, key, value string) {
+	if value != "" {
+		env[key] = value
+	}
+}
+
+// eulaAccepted returns whether the spec explicitly accepts the Minecraft EULA
+func eulaAccepted(minecraft *cachev1alpha1.Minecraft) bool {
+	return minecraft.Spec.Config.EULA != nil && *minecraft.Spec.Config.EULA
+}
+
+// acceptLegacyEULA carries the EULA accepted in the shared ConfigMap of previous
+// versions of the operator over to the spec. It is only done once, for instances
+// whose Deployment or StatefulSet still loads that ConfigMap, so they keep running
+// once the EULA has to be accepted by their spec.
+func (r *MinecraftReconciler) acceptLegacyEULA(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	if minecraft.Spec.Config.EULA != nil {
+		return nil
+	}
+
+	key := types.NamespacedName{Name: minecraft.Name, Namespace: minecraft.Namespace}
+	var templates []corev1.PodTemplateSpec
+	dep := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, dep); err == nil && metav1.IsControlledBy(dep, minecraft) {
+		templates = append(templates, dep.Spec.Template)
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	sts := &appsv1.StatefulSet{}
+	if err := r.Get(ctx, key, sts); err == nil && metav1.IsControlledBy(sts, minecraft) {
+		templates = append(templates, sts.Spec.Template)
+	} else if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	legacy := false
+	for _, template := range templates {
+		legacy = legacy || loadsConfigMap(&template, legacyConfigMapName)
+	}
+	if !legacy {
+		return nil
+	}
+
+	cm := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: legacyConfigMapName, Namespace: minecraft.Namespace}, cm)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if !strings.EqualFold(cm.Data["EULA"], "true") {
+		return nil
+	}
+
+	log.FromContext(ctx).Info("Accepting the EULA of the legacy ConfigMap in the spec",
+		"ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
+	patch := client.MergeFrom(minecraft.DeepCopy())
+	accepted := true
+	minecraft.Spec.Config.EULA = &accepted
+	if err := r.Patch(ctx, minecraft, patch); err != nil {
+		return err
+	}
+	r.Recorder.Event(minecraft, "Normal", "Migrated",
+		fmt.Sprintf("The EULA accepted in ConfigMap %s is now accepted by spec.config.eula", cm.Name))
+	return nil
+}
+
+// loadsConfigMap returns whether a container of the pod template loads the
+// ConfigMap as environment
+func loadsConfigMap(template *corev1.PodTemplateSpec, name string) bool {
+	for _, container := range template.Spec.Containers {
+		for _, source := range container.EnvFrom {
+			if source.ConfigMapRef != nil && source.ConfigMapRef.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// setBoolEnv sets key to TRUE or FALSE when value is defined
+func setBoolEnv(env map[string]string, key string, value *bool) {
+	if value != nil {
+		env[key] = strings.ToUpper(strconv.FormatBool(*value))
+	}
+}
+
+// hashForData returns a stable hash of the given key/value pairs
+func hashForData(data map[string]string) string {
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s=%s\n", k, data[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -20,13 +20,17 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
@@ -35,17 +39,38 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
 )
 
 const minecraftFinalizer = "cache.example.com/finalizer"
 
+// fieldOwner is the field manager used for the resources applied by the operator
+const fieldOwner = client.FieldOwner("minecraft-operator")
+
+const (
+	// worldVolumeName is the name of the volume claim template holding the world data
+	worldVolumeName = "data"
+	// worldMountPath is where the world volume is mounted in the server container
+	worldMountPath = "/data"
+	// gamePortName is the name of the port players connect to
+	gamePortName = "minecraft"
+	// gamePortV6Name is the name of the IPv6 port Bedrock players connect to
+	gamePortV6Name = "minecraft-v6"
+)
+
 // Definitions to manage status conditions
 const (
-	// typeAvailableMinecraft represents the status of the Deployment reconciliation
+	// typeAvailableMinecraft represents the status of the StatefulSet reconciliation
 	typeAvailableMinecraft = "Available"
+	// typeProgressingMinecraft represents the status of a version upgrade of the instances
+	typeProgressingMinecraft = "Progressing"
+	// typePendingRestartMinecraft represents the changes restarting the servers which are held back by
+	// the maintenance policy
+	typePendingRestartMinecraft = "PendingRestart"
 	// typeDegradedMinecraft represents the status used when the custom resource is deleted and the finalizer operations are yet to occur.
 	typeDegradedMinecraft = "Degraded"
 )
@@ -55,6 +80,12 @@ type MinecraftReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	// Ping queries the status of the servers, slp.Ping when not set
+	Ping PingFunc
+	// RCON runs commands on the servers, through the rcon package when not set
+	RCON RCONFunc
+	// Resolve resolves the usernames of the players, with the Mojang API when not set
+	Resolve ResolveFunc
 }
 
 // The following markers are used to generate the rules permissions (RBAC) on config/rbac using controller-gen
@@ -64,9 +95,16 @@ type MinecraftReconciler struct {
 // +kubebuilder:rbac:groups=cache.example.com,resources=minecrafts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=cache.example.com,resources=minecrafts/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cache.example.com,resources=minecrafts/finalizers,verbs=update
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftproxies,verbs=get;list;watch
 // +kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
-// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -79,9 +117,20 @@ type MinecraftReconciler struct {
 // - About Operator Pattern: https://kubernetes.io/docs/concepts/extend-kubernetes/operator/
 // - About Controllers: https://kubernetes.io/docs/concepts/architecture/controller/
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.18.4/pkg/reconcile
-func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, reterr error) {
 	log := log.FromContext(ctx)
 
+	// Count the outcome of the reconciliation, a deleted custom resource takes
+	// its metrics along
+	gone := false
+	defer func() {
+		if gone {
+			forgetMinecraftMetrics(req.NamespacedName)
+			return
+		}
+		recordReconcileOutcome(req.NamespacedName, result, reterr)
+	}()
+
 	// Fetch the Minecraft instance
 	// The purpose is check if the Custom Resource for the Kind Minecraft
 	// is applied on the cluster if not we return nil to stop the reconciliation
@@ -92,6 +141,7 @@ func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			// If the custom resource is not found then it usually means that it was deleted or not created
 			// In this way, we will stop the reconciliation
 			log.Info("minecraft resource not found. Ignoring since object must be deleted")
+			gone = true
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -102,6 +152,7 @@ func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 	// Let's just set the status as Unknown when no status is available
 	if minecraft.Status.Conditions == nil || len(minecraft.Status.Conditions) == 0 {
 		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft, Status: metav1.ConditionUnknown, Reason: "Reconciling", Message: "Starting reconciliation"})
+		minecraft.Status.Phase = cachev1alpha1.MinecraftPhasePending
 		if err = r.Status().Update(ctx, minecraft); err != nil {
 			log.Error(err, "Failed to update Minecraft status")
 			return ctrl.Result{}, err
@@ -145,6 +196,7 @@ func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeDegradedMinecraft,
 				Status: metav1.ConditionUnknown, Reason: "Finalizing",
 				Message: fmt.Sprintf("Performing finalizer operations for the custom resource: %s ", minecraft.Name)})
+			minecraft.Status.Phase = cachev1alpha1.MinecraftPhaseStopping
 
 			if err := r.Status().Update(ctx, minecraft); err != nil {
 				log.Error(err, "Failed to update Minecraft status")
@@ -152,12 +204,16 @@ func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 			}
 
 			// Perform all operations required before removing the finalizer and allow
-			// the Kubernetes API to remove the custom resource.
-			r.doFinalizerOperationsForMinecraft(minecraft)
-
-			// TODO(user): If you add operations to the doFinalizerOperationsForMinecraft method
-			// then you need to ensure that all worked fine before deleting and updating the Downgrade status
-			// otherwise, you should requeue here.
+			// the Kubernetes API to remove the custom resource. The finalizer is kept
+			// until they all succeeded.
+			done, err := r.doFinalizerOperationsForMinecraft(ctx, minecraft)
+			if err != nil {
+				log.Error(err, "Failed to perform finalizer operations for Minecraft")
+				return ctrl.Result{}, err
+			}
+			if !done {
+				return ctrl.Result{RequeueAfter: startingRequeueInterval}, nil
+			}
 
 			// Re-fetch the minecraft Custom Resource before updating the status
 			// so that we have the latest state of the resource on the cluster and we will avoid
@@ -191,182 +247,516 @@ func (r *MinecraftReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, nil
 	}
 
-	// Check if the deployment already exists, if not create a new one
-	found := &appsv1.Deployment{}
-	err = r.Get(ctx, types.NamespacedName{Name: minecraft.Name, Namespace: minecraft.Namespace}, found)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Define a new deployment
-		dep, err := r.deploymentForMinecraft(minecraft)
-		if err != nil {
-			log.Error(err, "Failed to define new Deployment resource for Minecraft")
+	// The servers put to sleep by autoPause are started again when asked to
+	if err := r.wakeUp(ctx, minecraft); err != nil {
+		log.Error(err, "Failed to wake up Minecraft")
+		return ctrl.Result{}, err
+	}
+
+	// The EULA was accepted in the ConfigMap shared by the instances created by
+	// previous versions of the operator, it is carried over before they stop
+	// loading it
+	if err := r.acceptLegacyEULA(ctx, minecraft); err != nil {
+		log.Error(err, "Failed to carry the EULA of the legacy ConfigMap over to Minecraft")
+		return ctrl.Result{}, err
+	}
 
-			// The following implementation will update the status
-			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
-				Status: metav1.ConditionFalse, Reason: "Reconciling",
-				Message: fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)", minecraft.Name, err)})
+	// Instances created by previous versions of the operator ran as a Deployment
+	// without any volume. The world now lives in a StatefulSet, so the old
+	// Deployment is removed before the StatefulSet takes over its pods.
+	if err := r.deleteLegacyDeployment(ctx, minecraft); err != nil {
+		log.Error(err, "Failed to delete legacy Deployment for Minecraft")
+		return ctrl.Result{}, err
+	}
 
-			if err := r.Status().Update(ctx, minecraft); err != nil {
-				log.Error(err, "Failed to update Minecraft status")
-				return ctrl.Result{}, err
-			}
+	// Decide which Minecraft version is rolled out. A new version is only rolled out
+	// once the world was backed up, so the resources below are rendered from a copy
+	// of the custom resource pinned to the version the instances should run.
+	version, waitForBackup, err := r.reconcileVersion(ctx, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to reconcile the version of Minecraft")
+		return ctrl.Result{}, err
+	}
+	desired := minecraft.DeepCopy()
+	desired.Spec.Version = version
+
+	// The players of the servers behind a MinecraftProxy are authenticated by
+	// the proxy, which forwards them to servers running in offline mode. The
+	// servers trust the forwarded players, so they are only reachable inside
+	// the cluster.
+	proxies, err := proxiesForMinecraft(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list the proxies of Minecraft")
+		return ctrl.Result{}, err
+	}
+	var proxy *cachev1alpha1.MinecraftProxy
+	if len(proxies) > 0 {
+		proxy = &proxies[0]
+		onlineMode := false
+		desired.Spec.Config.OnlineMode = &onlineMode
+		desired.Spec.Service = serviceBehindProxy(desired.Spec.Service)
+	}
 
+	// The operator talks to the servers through RCON with a generated password
+	if supportsRCON(minecraft) {
+		if err := r.reconcileRCONSecret(ctx, minecraft); err != nil {
+			log.Error(err, "Failed to reconcile RCON Secret for Minecraft")
 			return ctrl.Result{}, err
 		}
+	}
+
+	// Write the player lists of the spec, and apply their changes to the running servers
+	if err := r.reconcilePlayers(ctx, minecraft, len(proxies) > 0); err != nil {
+		log.Error(err, "Failed to reconcile the players of Minecraft")
+		return ctrl.Result{}, err
+	}
+
+	// Check if the statefulset already exists. Most of its spec is converged below
+	// with server-side apply, but a few of its fields can not be changed in place.
+	found := &appsv1.StatefulSet{}
+	err = r.Get(ctx, types.NamespacedName{Name: minecraft.Name, Namespace: minecraft.Namespace}, found)
+	if err != nil && apierrors.IsNotFound(err) {
+		found = nil
 
-		log.Info("Creating a new Deployment",
-			"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
-		if err = r.Create(ctx, dep); err != nil {
-			log.Error(err, "Failed to create new Deployment",
-				"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		// Pods left behind by a StatefulSet which was recreated to change its selector
+		// would block the new StatefulSet from creating pods with the same names
+		if err := r.deleteOrphanedPods(ctx, minecraft); err != nil {
+			log.Error(err, "Failed to delete orphaned pods for Minecraft")
 			return ctrl.Result{}, err
 		}
-
-		// Deployment created successfully
-		// We will requeue the reconciliation so that we can ensure the state
-		// and move forward for the next operations
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
 	} else if err != nil {
-		log.Error(err, "Failed to get Deployment")
+		log.Error(err, "Failed to get StatefulSet")
 		// Let's return the error for the reconciliation be re-trigged again
 		return ctrl.Result{}, err
+	} else if found.GetDeletionTimestamp() != nil {
+		// A StatefulSet which is being recreated is only replaced once it is gone
+		log.Info("Waiting for the StatefulSet to be deleted",
+			"StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
+	} else if !equality.Semantic.DeepEqual(found.Spec.Selector.MatchLabels, selectorLabelsForMinecraft(minecraft.Name)) {
+		// The selector of a StatefulSet can not be changed. StatefulSets created before
+		// the selector was scoped to the instance are deleted and recreated instead.
+		if err := r.recreateStatefulSetForSelector(ctx, minecraft, found); err != nil {
+			log.Error(err, "Failed to recreate StatefulSet with the instance selector",
+				"StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: 5 * time.Second}, nil
 	}
 
-	// create a service for the Minecraft deployment
-	service := &corev1.Service{}
-	err = r.Get(ctx, types.NamespacedName{Name: minecraft.Name, Namespace: minecraft.Namespace}, service)
-	if err != nil && apierrors.IsNotFound(err) {
-		// Define a new service
-		svc, err := r.serviceForMinecraft(minecraft)
-		if err != nil {
-			log.Error(err, "Failed to define new Service resource for Minecraft")
+	// Define the desired statefulset. The hash of the rendered configuration is kept
+	// on its pod template, so a change of the server configuration triggers a
+	// rolling restart of the pods.
+	// Servers behind a proxy are configured to accept the players it forwards.
+	// The forwarding is turned off again once no proxy selects them.
+	sts, err := r.statefulSetForMinecraft(desired, found)
+	if err == nil && (proxy != nil || forwardingConfigured(found)) {
+		err = configureForwarding(sts, desired, proxy)
+	}
+	if err != nil {
+		log.Error(err, "Failed to define StatefulSet resource for Minecraft")
 
-			// The following implementation will update the status
-			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
-				Status: metav1.ConditionFalse, Reason: "Reconciling",
-				Message: fmt.Sprintf("Failed to create Service for the custom resource (%s): (%s)", minecraft.Name, err)})
-			if err := r.Status().Update(ctx, minecraft); err != nil {
-				log.Error(err, "Failed to update Minecraft status")
-				return ctrl.Result{}, err
-			}
+		// The following implementation will update the status
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Failed to create StatefulSet for the custom resource (%s): (%s)", minecraft.Name, err)})
+		minecraft.Status.Phase = cachev1alpha1.MinecraftPhaseFailed
 
+		if err := r.Status().Update(ctx, minecraft); err != nil {
+			log.Error(err, "Failed to update Minecraft status")
 			return ctrl.Result{}, err
 		}
 
-		log.Info("Creating a new Service",
-			"Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
-		if err = r.Create(ctx, svc); err != nil {
-			log.Error(err, "Failed to create new Service",
-				"Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+		return ctrl.Result{}, err
+	}
+
+	// The volume claim templates of a StatefulSet are immutable, changes of the
+	// storage spec only apply to instances created afterwards
+	var pendingChanges []string
+	var pendingReason string
+	var untilMaintenance time.Duration
+	configStaged := false
+	if found != nil {
+		if !storageMatches(found, sts) {
+			log.Info("Ignoring storage change of existing StatefulSet",
+				"StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
+		}
+		sts.Spec.VolumeClaimTemplates = found.Spec.VolumeClaimTemplates
+
+		// Applying a new pod template restarts the servers. The maintenance policy
+		// may hold the changes back, the template applied last is applied again
+		// meanwhile. Otherwise the worlds are saved first.
+		applied, err := appliedPodTemplate(found)
+		if err != nil {
+			log.Error(err, "Failed to extract the applied pod template of the StatefulSet",
+				"StatefulSet.Namespace", found.Namespace, "StatefulSet.Name", found.Name)
 			return ctrl.Result{}, err
 		}
+		changes, err := podTemplateChanges(applied, &sts.Spec.Template)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(changes) > 0 {
+			allowed, reason, untilWindow := r.restartAllowed(ctx, minecraft, found, time.Now())
+			if allowed {
+				r.saveWorlds(ctx, minecraft)
+			} else {
+				log.Info("Staging changes restarting the servers until the maintenance policy allows it",
+					"changes", changes, "reason", reason)
+				configStaged = applied.Annotations[configHashAnnotation] !=
+					sts.Spec.Template.Annotations[configHashAnnotation]
+				sts.Spec.Template = *applied
+				pendingChanges, pendingReason, untilMaintenance = changes, reason, untilWindow
+			}
+		}
+	}
+	setPendingRestartCondition(minecraft, pendingChanges, pendingReason)
 
-		// Service created successfully
-		// We will requeue the reconciliation so that we can ensure the state
-		// and move forward for the next operations
-		return ctrl.Result{RequeueAfter: time.Minute}, nil
-	} else if err != nil {
-		log.Error(err, "Failed to get Service")
-		// Let's return the error for the reconciliation be re-trigged again
-		return ctrl.Result{}, err
+	// Render the server configuration of the custom resource into its own ConfigMap,
+	// which is loaded as environment by the server container. The pods load it when
+	// they start, so a staged change of the configuration is held back as well, and
+	// a pod recreated meanwhile keeps the configuration the servers run with.
+	if !configStaged {
+		cm, err := r.configMapForMinecraft(desired)
+		if err != nil {
+			log.Error(err, "Failed to define ConfigMap resource for Minecraft")
+			return ctrl.Result{}, err
+		}
+
+		if err = r.apply(ctx, cm); err != nil {
+			log.Error(err, "Failed to apply ConfigMap",
+				"ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
+			return ctrl.Result{}, err
+		}
 	}
 
 	// The CRD API defines that the Minecraft type have a MinecraftSpec.Size field
-	// to set the quantity of Deployment instances to the desired state on the cluster.
-	// Therefore, the following code will ensure the Deployment size is the same as defined
-	// via the Size spec of the Custom Resource which we are reconciling.
-	size := minecraft.Spec.Size
-	if *found.Spec.Replicas != size {
-		found.Spec.Replicas = &size
-		if err = r.Update(ctx, found); err != nil {
-			log.Error(err, "Failed to update Deployment",
-				"Deployment.Namespace", found.Namespace, "Deployment.Name", found.Name)
+	// to set the quantity of StatefulSet instances to the desired state on the cluster.
+	// Applying the desired StatefulSet ensures its size is the same as defined via the
+	// Size spec of the Custom Resource which we are reconciling, and reverts any other
+	// change made to the fields owned by the operator.
+	if err = r.apply(ctx, sts); err != nil {
+		log.Error(err, "Failed to apply StatefulSet",
+			"StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
 
-			// Re-fetch the minecraft Custom Resource before updating the status
-			// so that we have the latest state of the resource on the cluster and we will avoid
-			// raising the error "the object has been modified, please apply
-			// your changes to the latest version and try again" which would re-trigger the reconciliation
-			if err := r.Get(ctx, req.NamespacedName, minecraft); err != nil {
-				log.Error(err, "Failed to re-fetch minecraft")
-				return ctrl.Result{}, err
-			}
+		// Re-fetch the minecraft Custom Resource before updating the status
+		// so that we have the latest state of the resource on the cluster and we will avoid
+		// raising the error "the object has been modified, please apply
+		// your changes to the latest version and try again" which would re-trigger the reconciliation
+		if err := r.Get(ctx, req.NamespacedName, minecraft); err != nil {
+			log.Error(err, "Failed to re-fetch minecraft")
+			return ctrl.Result{}, err
+		}
 
-			// The following implementation will update the status
-			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
-				Status: metav1.ConditionFalse, Reason: "Resizing",
-				Message: fmt.Sprintf("Failed to update the size for the custom resource (%s): (%s)", minecraft.Name, err)})
+		// The following implementation will update the status
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Failed to apply the StatefulSet for the custom resource (%s): (%s)", minecraft.Name, err)})
+		minecraft.Status.Phase = cachev1alpha1.MinecraftPhaseFailed
 
-			if err := r.Status().Update(ctx, minecraft); err != nil {
-				log.Error(err, "Failed to update Minecraft status")
-				return ctrl.Result{}, err
-			}
+		if err := r.Status().Update(ctx, minecraft); err != nil {
+			log.Error(err, "Failed to update Minecraft status")
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, err
+	}
 
+	// Define the desired service for the Minecraft statefulset
+	svc, err := r.serviceForMinecraft(desired)
+	if err != nil {
+		log.Error(err, "Failed to define Service resource for Minecraft")
+
+		// The following implementation will update the status
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Reconciling",
+			Message: fmt.Sprintf("Failed to create Service for the custom resource (%s): (%s)", minecraft.Name, err)})
+		minecraft.Status.Phase = cachev1alpha1.MinecraftPhaseFailed
+		if err := r.Status().Update(ctx, minecraft); err != nil {
+			log.Error(err, "Failed to update Minecraft status")
 			return ctrl.Result{}, err
 		}
 
-		// Now, that we update the size we want to requeue the reconciliation
-		// so that we can ensure that we have the latest state of the resource before
-		// update. Also, it will help ensure the desired state on the cluster
-		return ctrl.Result{Requeue: true}, nil
+		return ctrl.Result{}, err
+	}
+
+	if err = r.apply(ctx, svc); err != nil {
+		log.Error(err, "Failed to apply Service",
+			"Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+		return ctrl.Result{}, err
+	}
+
+	// Once every instance runs the version which was rolled out it is recorded as
+	// the current version, the next change of spec.version is compared to it
+	if len(pendingChanges) == 0 && statefulSetRolledOut(sts) && minecraft.Status.CurrentVersion != version {
+		if minecraft.Status.CurrentVersion != "" {
+			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+				Status: metav1.ConditionFalse, Reason: "Upgraded",
+				Message: fmt.Sprintf("Upgraded from %s to %s", minecraft.Status.CurrentVersion, version)})
+		}
+		minecraft.Status.CurrentVersion = version
+	}
+
+	// Take the backups which are due and record the ones which completed
+	untilNextBackup, err := r.reconcileBackupSchedule(ctx, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to reconcile the backup schedule of Minecraft")
+		return ctrl.Result{}, err
+	}
+	if err := r.recordCompletedBackups(ctx, minecraft); err != nil {
+		log.Error(err, "Failed to list backups of Minecraft")
+		return ctrl.Result{}, err
+	}
+
+	pods, err := serverPods(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list server pods for Minecraft")
+		return ctrl.Result{}, err
 	}
 
-	// The following implementation will update the status
-	meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
-		Status: metav1.ConditionTrue, Reason: "Reconciling",
-		Message: fmt.Sprintf("Deployment for custom resource (%s) with %d replicas created successfully", minecraft.Name, size)})
+	// The server is only available once it answers like it does to the players
+	available, message := r.observeServers(ctx, minecraft, sts, pods)
+
+	// Idle servers are put to sleep, the StatefulSet is scaled to zero on the
+	// next reconciliation
+	untilSleep, err := r.reconcileAutoPause(ctx, minecraft, available)
+	if err != nil {
+		log.Error(err, "Failed to save the worlds of idle Minecraft")
+		return ctrl.Result{}, err
+	}
+	if !isSleeping(minecraft) {
+		minecraft.Status.Phase = phaseForMinecraft(sts, pods, available)
+	}
+	minecraft.Status.ObservedGeneration = minecraft.Generation
+	minecraft.Status.Artifacts = artifactsForPods(pods)
+	minecraft.Status.ReadyReplicas = sts.Status.ReadyReplicas
+	minecraft.Status.Address = addressForMinecraft(minecraft, svc, pods)
+	if available {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionTrue, Reason: "Reconciling", Message: message})
+	} else if !eulaAccepted(minecraft) {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "EULANotAccepted",
+			Message: "The servers are not started until the spec accepts the Minecraft EULA (https://aka.ms/MinecraftEULA)"})
+	} else if isSleeping(minecraft) {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Sleeping",
+			Message: fmt.Sprintf("The servers are stopped since no player was online for %s",
+				idleTimeoutForMinecraft(minecraft))})
+	} else if restore := minecraft.Annotations[restoreAnnotation]; restore != "" {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Restoring",
+			Message: fmt.Sprintf("The servers are stopped while MinecraftRestore %s restores the world", restore)})
+	} else {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Starting", Message: message})
+	}
 
 	if err := r.Status().Update(ctx, minecraft); err != nil {
 		log.Error(err, "Failed to update Minecraft status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, nil
+	// Requeue to refresh the player counts reported in the status
+	requeueAfter := statusRefreshInterval
+	if waitForBackup || (!available && !isSleeping(minecraft)) {
+		requeueAfter = startingRequeueInterval
+	}
+	for _, until := range []time.Duration{untilNextBackup, untilSleep, untilMaintenance} {
+		if until > 0 && until < requeueAfter {
+			requeueAfter = until
+		}
+	}
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// apply converges obj to the given desired state with server-side apply. Fields
+// owned by the operator are reverted when they were changed by someone else.
+// More info: https://kubernetes.io/docs/reference/using-api/server-side-apply/
+func (r *MinecraftReconciler) apply(ctx context.Context, obj client.Object) error {
+	return r.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
 }
 
 // finalizeMinecraft will perform the required operations before delete the CR.
-func (r *MinecraftReconciler) doFinalizerOperationsForMinecraft(cr *cachev1alpha1.Minecraft) {
+// It returns whether they are done, the operations are retried until they are.
+func (r *MinecraftReconciler) doFinalizerOperationsForMinecraft(ctx context.Context,
+	cr *cachev1alpha1.Minecraft) (bool, error) {
 	// TODO(user): Add the cleanup steps that the operator
 	// needs to do before the CR can be deleted. Examples
 	// of finalizers include performing backups and deleting
 	// resources that are not owned by this CR, like a PVC.
 
 	// Note: It is not recommended to use finalizers with the purpose of deleting resources which are
-	// created and managed in the reconciliation. These ones, such as the Deployment created on this reconcile,
+	// created and managed in the reconciliation. These ones, such as the StatefulSet created on this reconcile,
 	// are defined as dependent of the custom resource. See that we use the method ctrl.SetControllerReference.
-	// to set the ownerRef which means that the Deployment will be deleted by the Kubernetes API.
+	// to set the ownerRef which means that the StatefulSet will be deleted by the Kubernetes API.
 	// More info: https://kubernetes.io/docs/tasks/administer-cluster/use-cascading-deletion/
 
+	// The final backup is taken while the servers still run
+	if deletionPolicyForMinecraft(cr) == cachev1alpha1.DeletionPolicySnapshot {
+		done, err := r.takeFinalBackup(ctx, cr)
+		if !done || err != nil {
+			return false, err
+		}
+	}
+
+	// The servers are stopped when their pods are garbage collected, save the
+	// worlds while they still run
+	r.saveWorlds(ctx, cr)
+
+	if deletesWorldVolumes(cr) {
+		if err := r.deleteWorldVolumes(ctx, cr); err != nil {
+			return false, err
+		}
+	}
+
 	// The following implementation will raise an event
 	r.Recorder.Event(cr, "Warning", "Deleting",
 		fmt.Sprintf("Custom Resource %s is being deleted from the namespace %s",
 			cr.Name,
 			cr.Namespace))
+	return true, nil
+}
+
+// deleteLegacyDeployment removes the Deployment which backed Minecraft instances
+// before the world was persisted on a volume. Only a Deployment controlled by
+// the custom resource is removed, anything else with the same name is left alone.
+func (r *MinecraftReconciler) deleteLegacyDeployment(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	dep := &appsv1.Deployment{}
+	err := r.Get(ctx, types.NamespacedName{Name: minecraft.Name, Namespace: minecraft.Namespace}, dep)
+	if apierrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(dep, minecraft) {
+		return nil
+	}
+
+	log.Info("Migrating Minecraft from Deployment to StatefulSet, deleting legacy Deployment",
+		"Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+	if err := r.Delete(ctx, dep, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	r.Recorder.Event(minecraft, "Normal", "Migrated",
+		fmt.Sprintf("Deployment %s replaced by a StatefulSet with persistent storage", dep.Name))
+	return nil
+}
+
+// recreateStatefulSetForSelector deletes a StatefulSet whose selector does not match
+// the instance labels so it can be created again. The StatefulSet is deleted with the
+// orphan propagation policy so the world volumes are kept and claimed again by the new
+// StatefulSet, the orphaned pods are removed by deleteOrphanedPods.
+func (r *MinecraftReconciler) recreateStatefulSetForSelector(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft, sts *appsv1.StatefulSet) error {
+	log := log.FromContext(ctx)
+
+	log.Info("Migrating StatefulSet to the instance selector, deleting it",
+		"StatefulSet.Namespace", sts.Namespace, "StatefulSet.Name", sts.Name)
+	if err := r.Delete(ctx, sts, client.PropagationPolicy(metav1.DeletePropagationOrphan)); err != nil &&
+		!apierrors.IsNotFound(err) {
+		return err
+	}
+
+	r.Recorder.Event(minecraft, "Normal", "Migrated",
+		fmt.Sprintf("StatefulSet %s is recreated with a selector scoped to the instance", sts.Name))
+	return nil
+}
+
+// deleteOrphanedPods removes the server pods which are no longer controlled by a
+// StatefulSet. Only pods named after the StatefulSet of the custom resource are
+// considered, pods of other instances sharing the namespace are left alone.
+func (r *MinecraftReconciler) deleteOrphanedPods(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(minecraft.Namespace),
+		client.MatchingLabels{"app.kubernetes.io/managed-by": "MinecraftController"}); err != nil {
+		return err
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if metav1.GetControllerOf(pod) != nil || !isStatefulSetPodName(minecraft.Name, pod.Name) {
+			continue
+		}
+
+		log.Info("Deleting orphaned pod", "Pod.Namespace", pod.Namespace, "Pod.Name", pod.Name)
+		if err := r.Delete(ctx, pod); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// isStatefulSetPodName returns whether podName is the name of a pod created
+// by the StatefulSet stsName, i.e. <stsName>-<ordinal>
+func isStatefulSetPodName(stsName, podName string) bool {
+	ordinal, found := strings.CutPrefix(podName, stsName+"-")
+	if !found || ordinal == "" {
+		return false
+	}
+	_, err := strconv.Atoi(ordinal)
+	return err == nil
+}
+
+// replicasForMinecraft returns the number of servers to run, none until the
+// EULA is accepted, while they sleep or a MinecraftRestore replaces their worlds
+func replicasForMinecraft(minecraft *cachev1alpha1.Minecraft) int32 {
+	if !eulaAccepted(minecraft) || isSleeping(minecraft) || minecraft.Annotations[restoreAnnotation] != "" {
+		return 0
+	}
+	return minecraft.Spec.Size
 }
 
-// deploymentForMinecraft returns a Minecraft Deployment object
-func (r *MinecraftReconciler) deploymentForMinecraft(
-	minecraft *cachev1alpha1.Minecraft) (*appsv1.Deployment, error) {
-	ls := labelsForMinecraft(minecraft.Name)
-	replicas := minecraft.Spec.Size
+// statefulSetForMinecraft returns a Minecraft StatefulSet object
+func (r *MinecraftReconciler) statefulSetForMinecraft(
+	minecraft *cachev1alpha1.Minecraft, found *appsv1.StatefulSet) (*appsv1.StatefulSet, error) {
+	ls := labelsForMinecraft(minecraft)
+	replicas := replicasForMinecraft(minecraft)
 
 	// Get the Operand image
-	image, err := imageForMinecraft()
+	image, err := imageForMinecraft(minecraft)
 	if err != nil {
 		return nil, err
 	}
 
-	dep := &appsv1.Deployment{
+	pvc, err := persistentVolumeClaimForMinecraft(minecraft)
+	if err != nil {
+		return nil, err
+	}
+
+	initContainers, err := initContainersForMinecraft(minecraft, found)
+	if err != nil {
+		return nil, err
+	}
+
+	sts := &appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "StatefulSet",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      minecraft.Name,
 			Namespace: minecraft.Namespace,
+			Labels:    ls,
 		},
-		Spec: appsv1.DeploymentSpec{
-			Replicas: &replicas,
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: minecraft.Name,
 			Selector: &metav1.LabelSelector{
-				MatchLabels: ls,
+				MatchLabels: selectorLabelsForMinecraft(minecraft.Name),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: ls,
+					Annotations: map[string]string{
+						configHashAnnotation: hashForData(serverEnvForMinecraft(minecraft)),
+					},
 				},
 				Spec: corev1.PodSpec{
 					// TODO(user): Uncomment the following code to configure the nodeAffinity expression
@@ -408,19 +798,29 @@ func (r *MinecraftReconciler) deploymentForMinecraft(
 					// 		Type: corev1.SeccompProfileTypeRuntimeDefault,
 					// 	},
 					// },
+					TerminationGracePeriodSeconds: terminationGracePeriodForMinecraft(minecraft),
+					InitContainers:                initContainers,
 					Containers: []corev1.Container{{
 						Image:           image,
 						Name:            "minecraft",
 						ImagePullPolicy: corev1.PullIfNotPresent,
+						Ports:           containerPortsForMinecraft(minecraft),
+						StartupProbe:    startupProbeForMinecraft(minecraft),
+						ReadinessProbe:  readinessProbeForMinecraft(minecraft),
+						LivenessProbe:   livenessProbeForMinecraft(minecraft),
+						Lifecycle:       lifecycleForMinecraft(minecraft),
+						Resources:       minecraft.Spec.Resources,
+						VolumeMounts:    volumeMountsForMinecraft(minecraft),
 						EnvFrom: []corev1.EnvFromSource{
 							{
 								ConfigMapRef: &corev1.ConfigMapEnvSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: "minecraft-config",
+										Name: configMapNameForMinecraft(minecraft),
 									},
 								},
 							},
 						},
+						Env: rconEnvForMinecraft(minecraft),
 						// TODO(user): Uncomment the following code to configure the resources
 						// Ensure restrictive context for the container
 						// More info: https://kubernetes.io/docs/concepts/security/pod-security-standards/#restricted
@@ -435,38 +835,182 @@ func (r *MinecraftReconciler) deploymentForMinecraft(
 						// 	},
 						// },
 					}},
+					Volumes: volumesForMinecraft(minecraft, found),
 				},
 			},
+			VolumeClaimTemplates:                 []corev1.PersistentVolumeClaim{*pvc},
+			PersistentVolumeClaimRetentionPolicy: retentionPolicyForMinecraft(minecraft),
 		},
 	}
 
-	// Set the ownerRef for the Deployment
+	// Set the ownerRef for the StatefulSet
 	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
-	if err := ctrl.SetControllerReference(minecraft, dep, r.Scheme); err != nil {
+	if err := ctrl.SetControllerReference(minecraft, sts, r.Scheme); err != nil {
 		return nil, err
 	}
-	return dep, nil
+	return sts, nil
+}
+
+// podTemplateChanges returns what applying the desired pod template changes in
+// the one applied last, each change restarting the servers. Any difference
+// counts, the named ones describe it for the status.
+func podTemplateChanges(applied, desired *corev1.PodTemplateSpec) ([]string, error) {
+	// The applied template went through JSON, so does the desired one to not
+	// tell nil and empty fields apart
+	desired, err := roundTripPodTemplate(desired)
+	if err != nil {
+		return nil, err
+	}
+	if equality.Semantic.DeepEqual(applied, desired) {
+		return nil, nil
+	}
+	if len(applied.Spec.Containers) == 0 || len(desired.Spec.Containers) == 0 {
+		return []string{"pod template"}, nil
+	}
+
+	var changes []string
+	if applied.Annotations[configHashAnnotation] != desired.Annotations[configHashAnnotation] {
+		changes = append(changes, "configuration")
+	}
+	appliedContainer, desiredContainer := applied.Spec.Containers[0], desired.Spec.Containers[0]
+	if appliedContainer.Image != desiredContainer.Image {
+		changes = append(changes, "image")
+	}
+	if !equality.Semantic.DeepEqual(appliedContainer.Resources, desiredContainer.Resources) {
+		changes = append(changes, "resources")
+	}
+	if !equality.Semantic.DeepEqual(applied.Spec.InitContainers, desired.Spec.InitContainers) {
+		changes = append(changes, "plugins and mods")
+	}
+	if len(changes) == 0 {
+		changes = append(changes, "pod template")
+	}
+	return changes, nil
+}
+
+// storageMatches returns whether the world volume of the existing StatefulSet has
+// the capacity, storage class and access modes of the desired one
+func storageMatches(existing, desired *appsv1.StatefulSet) bool {
+	if len(existing.Spec.VolumeClaimTemplates) != len(desired.Spec.VolumeClaimTemplates) {
+		return false
+	}
+	for i := range existing.Spec.VolumeClaimTemplates {
+		e, d := existing.Spec.VolumeClaimTemplates[i].Spec, desired.Spec.VolumeClaimTemplates[i].Spec
+		if !e.Resources.Requests.Storage().Equal(*d.Resources.Requests.Storage()) ||
+			!equality.Semantic.DeepEqual(e.AccessModes, d.AccessModes) ||
+			(d.StorageClassName != nil && !equality.Semantic.DeepEqual(e.StorageClassName, d.StorageClassName)) {
+			return false
+		}
+	}
+	return true
+}
+
+// persistentVolumeClaimForMinecraft returns the claim template used by the
+// StatefulSet to provision the world volume of every instance
+func persistentVolumeClaimForMinecraft(minecraft *cachev1alpha1.Minecraft) (*corev1.PersistentVolumeClaim, error) {
+	storage := minecraft.Spec.Storage
+
+	size := storage.Size
+	if size.IsZero() {
+		var err error
+		if size, err = resource.ParseQuantity(cachev1alpha1.DefaultStorageSize); err != nil {
+			return nil, err
+		}
+	}
+
+	accessMode := storage.AccessMode
+	if accessMode == "" {
+		accessMode = corev1.ReadWriteOnce
+	}
+
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: worldVolumeName,
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{accessMode},
+			StorageClassName: storage.StorageClassName,
+			Resources: corev1.VolumeResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: size,
+				},
+			},
+		},
+	}, nil
+}
+
+// retentionPolicyForMinecraft maps the storage retain policy of the custom resource
+// to the PVC retention policy of the StatefulSet. The volumes of a deleted
+// instance are kept or deleted according to its deletion policy, which the
+// finalizer enforces.
+func retentionPolicyForMinecraft(
+	minecraft *cachev1alpha1.Minecraft) *appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy {
+	whenScaled := appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	if minecraft.Spec.Storage.RetainPolicy == cachev1alpha1.StorageRetainPolicyDelete {
+		whenScaled = appsv1.DeletePersistentVolumeClaimRetentionPolicyType
+	}
+	whenDeleted := appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+	if deletesWorldVolumes(minecraft) {
+		whenDeleted = appsv1.DeletePersistentVolumeClaimRetentionPolicyType
+	}
+	return &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+		WhenDeleted: whenDeleted,
+		WhenScaled:  whenScaled,
+	}
 }
 
 // serviceForMinecraft returns a Minecraft Service object
 func (r *MinecraftReconciler) serviceForMinecraft(
 	minecraft *cachev1alpha1.Minecraft) (*corev1.Service, error) {
-	ls := labelsForMinecraft(minecraft.Name)
+	spec := minecraft.Spec.Service
+
+	serviceType := spec.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+
+	var ports []corev1.ServicePort
+	for i, p := range gamePortsForMinecraft(minecraft) {
+		port := corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   p.Protocol,
+			Port:       p.ContainerPort,
+			TargetPort: intstr.FromInt32(p.ContainerPort),
+		}
+		// The port and node port of the spec apply to the main game port
+		if i == 0 {
+			port.Port = servicePortForMinecraft(minecraft)
+			port.NodePort = spec.NodePort
+		}
+		ports = append(ports, port)
+	}
+	for _, p := range spec.ExtraPorts {
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   protocolForPort(p),
+			Port:       p.Port,
+			TargetPort: intstr.FromInt32(targetPortForPort(p)),
+			NodePort:   p.NodePort,
+		})
+	}
+
 	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      minecraft.Name,
-			Namespace: minecraft.Namespace,
+			Name:        minecraft.Name,
+			Namespace:   minecraft.Namespace,
+			Labels:      labelsForMinecraft(minecraft),
+			Annotations: spec.Annotations,
 		},
 		Spec: corev1.ServiceSpec{
-			Selector: ls,
-			Ports: []corev1.ServicePort{
-				{
-					Name:     "minecraft",
-					Protocol: corev1.ProtocolTCP,
-					// Port:       25565,
-					TargetPort: intstr.FromInt(25565),
-				},
-			},
+			Type:                  serviceType,
+			Selector:              selectorLabelsForMinecraft(minecraft.Name),
+			Ports:                 ports,
+			LoadBalancerIP:        spec.LoadBalancerIP,
+			ExternalTrafficPolicy: spec.ExternalTrafficPolicy,
 		},
 	}
 
@@ -478,24 +1022,160 @@ func (r *MinecraftReconciler) serviceForMinecraft(
 	return service, nil
 }
 
-// labelsForMinecraft returns the labels for selecting the resources
+// gamePortsForMinecraft returns the ports players of the edition connect to
+func gamePortsForMinecraft(minecraft *cachev1alpha1.Minecraft) []corev1.ContainerPort {
+	if isBedrock(minecraft) {
+		return []corev1.ContainerPort{
+			{Name: gamePortName, ContainerPort: cachev1alpha1.BedrockGamePort, Protocol: corev1.ProtocolUDP},
+			{Name: gamePortV6Name, ContainerPort: cachev1alpha1.BedrockGamePortV6, Protocol: corev1.ProtocolUDP},
+		}
+	}
+	return []corev1.ContainerPort{
+		{Name: gamePortName, ContainerPort: cachev1alpha1.JavaGamePort, Protocol: corev1.ProtocolTCP},
+	}
+}
+
+// containerPortsForMinecraft returns the ports the server container listens on
+func containerPortsForMinecraft(minecraft *cachev1alpha1.Minecraft) []corev1.ContainerPort {
+	ports := gamePortsForMinecraft(minecraft)
+	if supportsRCON(minecraft) {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          cachev1alpha1.RCONPortName,
+			ContainerPort: cachev1alpha1.RCONPort,
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	for _, p := range minecraft.Spec.Service.ExtraPorts {
+		ports = append(ports, corev1.ContainerPort{
+			Name:          p.Name,
+			ContainerPort: targetPortForPort(p),
+			Protocol:      protocolForPort(p),
+		})
+	}
+	return ports
+}
+
+// volumeMountsForMinecraft returns the volumes mounted into the server container
+func volumeMountsForMinecraft(minecraft *cachev1alpha1.Minecraft) []corev1.VolumeMount {
+	mounts := []corev1.VolumeMount{{
+		Name:      worldVolumeName,
+		MountPath: worldMountPath,
+	}}
+	if managesPlayers(minecraft) {
+		mounts = append(mounts, corev1.VolumeMount{
+			Name:      playersVolumeName,
+			MountPath: playersMountPath,
+			ReadOnly:  true,
+		})
+	}
+	return mounts
+}
+
+// volumesForMinecraft returns the volumes of the server pods besides the
+// world volume, which comes from the volume claim template
+func volumesForMinecraft(minecraft *cachev1alpha1.Minecraft, found *appsv1.StatefulSet) []corev1.Volume {
+	var volumes []corev1.Volume
+	if managesPlayers(minecraft) {
+		volumes = append(volumes, corev1.Volume{
+			Name: playersVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{
+						Name: playersConfigMapNameForMinecraft(minecraft),
+					},
+				},
+			},
+		})
+	}
+	if sources := artifactSourcesForMinecraft(minecraft); installsArtifacts(minecraft, found) && len(sources) > 0 {
+		volumes = append(volumes, corev1.Volume{
+			Name: artifactSourcesVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Projected: &corev1.ProjectedVolumeSource{Sources: sources},
+			},
+		})
+	}
+	return volumes
+}
+
+// servicePortForMinecraft returns the port players connect to on the Service
+func servicePortForMinecraft(minecraft *cachev1alpha1.Minecraft) int32 {
+	if minecraft.Spec.Service.Port != 0 {
+		return minecraft.Spec.Service.Port
+	}
+	return gamePortsForMinecraft(minecraft)[0].ContainerPort
+}
+
+// isBedrock returns whether the custom resource runs a Bedrock Edition server
+func isBedrock(minecraft *cachev1alpha1.Minecraft) bool {
+	return minecraft.Spec.Edition == cachev1alpha1.EditionBedrock
+}
+
+// targetPortForPort returns the container port an extra port is routed to
+func targetPortForPort(p cachev1alpha1.ServicePort) int32 {
+	if p.TargetPort != 0 {
+		return p.TargetPort
+	}
+	return p.Port
+}
+
+// protocolForPort returns the protocol of an extra port
+func protocolForPort(p cachev1alpha1.ServicePort) corev1.Protocol {
+	if p.Protocol != "" {
+		return p.Protocol
+	}
+	return corev1.ProtocolTCP
+}
+
+// labelsForMinecraft returns the labels set on the resources of the custom resource
 // More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/common-labels/
-func labelsForMinecraft(name string) map[string]string {
-	var imageTag string
-	image, err := imageForMinecraft()
-	if err == nil {
-		imageTag = strings.Split(image, ":")[1]
+func labelsForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string]string {
+	ls := selectorLabelsForMinecraft(minecraft.Name)
+	ls["app.kubernetes.io/version"] = labelValue(desiredVersionForMinecraft(minecraft))
+	ls["app.kubernetes.io/component"] = "server"
+	ls["app.kubernetes.io/part-of"] = "minecraft-operator"
+	ls["app.kubernetes.io/managed-by"] = "MinecraftController"
+	return ls
+}
+
+// selectorLabelsForMinecraft returns the labels for selecting the pods of the custom resource.
+// They are scoped to the instance so several custom resources can share a namespace, and
+// must never change as the selector of a StatefulSet is immutable.
+func selectorLabelsForMinecraft(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "minecraft",
+		"app.kubernetes.io/instance": name,
+	}
+}
+
+// labelValue turns s into a valid label value by replacing unsupported characters
+// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/labels/#syntax-and-character-set
+func labelValue(s string) string {
+	value := []byte(s)
+	for i, c := range value {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			value[i] = '-'
+		}
 	}
-	return map[string]string{"app.kubernetes.io/name": "minecraft-operator",
-		"app.kubernetes.io/version":    imageTag,
-		"app.kubernetes.io/managed-by": "MinecraftController",
+	if len(value) > 63 {
+		value = value[:63]
 	}
+	return strings.Trim(string(value), "-_.")
 }
 
-// imageForMinecraft gets the Operand image which is managed by this controller
-// from the MINECRAFT_IMAGE environment variable defined in the config/manager/manager.yaml
-func imageForMinecraft() (string, error) {
+// imageForMinecraft gets the Operand image of the custom resource. Unless the spec
+// overrides it, the image managed by this controller is read from the MINECRAFT_IMAGE
+// or, for the Bedrock edition, the MINECRAFT_BEDROCK_IMAGE environment variable
+// defined in the config/manager/manager.yaml
+func imageForMinecraft(minecraft *cachev1alpha1.Minecraft) (string, error) {
+	if minecraft.Spec.Image != "" {
+		return minecraft.Spec.Image, nil
+	}
+
 	var imageEnvVar = "MINECRAFT_IMAGE"
+	if isBedrock(minecraft) {
+		imageEnvVar = "MINECRAFT_BEDROCK_IMAGE"
+	}
 	image, found := os.LookupEnv(imageEnvVar)
 	if !found {
 		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
@@ -503,12 +1183,37 @@ func imageForMinecraft() (string, error) {
 	return image, nil
 }
 
+// minecraftsForProxy maps a MinecraftProxy to the custom resources of its
+// namespace, so the servers switch to offline mode when a proxy selects them
+// and back when it no longer does
+func (r *MinecraftReconciler) minecraftsForProxy(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &cachev1alpha1.MinecraftList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(list.Items))
+	for _, m := range list.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: m.Name, Namespace: m.Namespace},
+		})
+	}
+	return requests
+}
+
 // SetupWithManager sets up the controller with the Manager.
-// Note that the Deployment will be also watched in order to ensure its
-// desirable state on the cluster
+// Note that the StatefulSet, Service, ConfigMap and Jobs owned by the custom
+// resource will be also watched in order to ensure their desirable state on the
+// cluster. The claims of the world volumes are created by the StatefulSet and
+// not owned by the custom resource, they are read when reconciling instead.
 func (r *MinecraftReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&cachev1alpha1.Minecraft{}).
-		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Owns(&batchv1.Job{}).
+		Owns(&cachev1alpha1.MinecraftBackup{}).
+		Watches(&cachev1alpha1.MinecraftProxy{}, handler.EnqueueRequestsFromMapFunc(r.minecraftsForProxy)).
 		Complete(r)
 }
@@ -20,22 +20,32 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	//nolint:golint
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/slp"
 )
 
 var _ = Describe("Minecraft controller", func() {
+	eula := true
+
 	Context("Minecraft controller test", func() {
 
 		const MinecraftName = "test-minecraft"
@@ -75,7 +85,8 @@ var _ = Describe("Minecraft controller", func() {
 						Namespace: namespace.Name,
 					},
 					Spec: cachev1alpha1.MinecraftSpec{
-						Size: 1,
+						Size:   1,
+						Config: cachev1alpha1.ServerConfig{EULA: &eula},
 					},
 				}
 
@@ -122,12 +133,58 @@ var _ = Describe("Minecraft controller", func() {
 			})
 			Expect(err).To(Not(HaveOccurred()))
 
-			By("Checking if Deployment was successfully created in the reconciliation")
+			By("Checking if StatefulSet was successfully created in the reconciliation")
 			Eventually(func() error {
-				found := &appsv1.Deployment{}
+				found := &appsv1.StatefulSet{}
 				return k8sClient.Get(ctx, typeNamespaceName, found)
 			}, time.Minute, time.Second).Should(Succeed())
 
+			By("Checking if the ConfigMap with the server configuration was created")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("EULA", "TRUE"))
+			Expect(cm.Data).To(HaveKeyWithValue("VERSION", "LATEST"))
+			Expect(cm.Data).To(HaveKeyWithValue("TYPE", "VANILLA"))
+
+			By("Checking if the world volume is mounted from the claim template")
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			Expect(found.Spec.VolumeClaimTemplates).To(HaveLen(1))
+			Expect(found.Spec.VolumeClaimTemplates[0].Name).To(Equal(worldVolumeName))
+			Expect(found.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name:      worldVolumeName,
+				MountPath: worldMountPath,
+			}))
+			Expect(found.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, hashForData(cm.Data)))
+
+			By("Checking if the StatefulSet only selects the pods of this instance")
+			Expect(found.Spec.Selector.MatchLabels).To(HaveKeyWithValue("app.kubernetes.io/instance", MinecraftName))
+			Expect(found.Spec.Template.Labels).To(HaveKeyWithValue("app.kubernetes.io/instance", MinecraftName))
+
+			By("Checking if Service was successfully created in the reconciliation")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, service)).To(Succeed())
+			Expect(service.Spec.Selector).To(Equal(found.Spec.Selector.MatchLabels))
+			Expect(service.Spec.Ports).To(HaveLen(1))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(25565)))
+
+			By("Changing the image of the StatefulSet outside of the operator")
+			found.Spec.Template.Spec.Containers[0].Image = "example.com/image:drifted"
+			Expect(k8sClient.Update(ctx, found)).To(Succeed())
+
+			By("Reconciling the custom resource again")
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the change to the StatefulSet was reverted")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			Expect(found.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/image:test"))
+
 			By("Checking the latest Status Condition added to the Minecraft instance")
 			Eventually(func() error {
 				if minecraft.Status.Conditions != nil &&
@@ -135,12 +192,11 @@ var _ = Describe("Minecraft controller", func() {
 					latestStatusCondition := minecraft.Status.Conditions[len(minecraft.Status.Conditions)-1]
 					expectedLatestStatusCondition := metav1.Condition{
 						Type:   typeAvailableMinecraft,
-						Status: metav1.ConditionTrue,
-						Reason: "Reconciling",
+						Status: metav1.ConditionFalse,
+						Reason: "Starting",
 						Message: fmt.Sprintf(
-							"Deployment for custom resource (%s) with %d replicas created successfully",
-							minecraft.Name,
-							minecraft.Spec.Size),
+							"Waiting for the server of custom resource (%s) to accept players",
+							minecraft.Name),
 					}
 					if latestStatusCondition != expectedLatestStatusCondition {
 						return fmt.Errorf("The latest status condition added to the Minecraft instance is not as expected")
@@ -150,4 +206,2070 @@ var _ = Describe("Minecraft controller", func() {
 			}, time.Minute, time.Second).Should(Succeed())
 		})
 	})
+
+	Context("Minecraft legacy Deployment migration", func() {
+
+		const MinecraftName = "test-minecraft-legacy"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should replace a legacy Deployment with a StatefulSet", func() {
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Creating a Deployment as previous versions of the operator did")
+			replicas := int32(1)
+			ls := map[string]string{"app.kubernetes.io/name": "minecraft-operator"}
+			legacy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: ls},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: ls},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+						},
+					},
+				},
+			}
+			Expect(controllerutil.SetControllerReference(minecraft, legacy, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, legacy)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking that the legacy Deployment was removed")
+			Eventually(func() bool {
+				found := &appsv1.Deployment{}
+				err := k8sClient.Get(ctx, typeNamespaceName, found)
+				return errors.IsNotFound(err) || (err == nil && found.DeletionTimestamp != nil)
+			}, time.Minute, time.Second).Should(BeTrue())
+
+			By("Checking that the StatefulSet is owned by the custom resource")
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			Expect(metav1.IsControlledBy(found, minecraft)).To(BeTrue())
+		})
+	})
+
+	Context("Minecraft legacy EULA migration", func() {
+
+		const MinecraftName = "test-minecraft-legacy-eula"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should carry the EULA of the shared ConfigMap over to the spec", func() {
+			By("Creating the custom resource as previous versions of the operator accepted it")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size: 1,
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Creating the shared ConfigMap and the Deployment which loads it")
+			Expect(k8sClient.Create(ctx, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: legacyConfigMapName, Namespace: namespace.Name},
+				Data:       map[string]string{"EULA": "TRUE"},
+			})).To(Succeed())
+			replicas := int32(1)
+			ls := map[string]string{"app.kubernetes.io/name": "minecraft-operator"}
+			legacy := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: &replicas,
+					Selector: &metav1.LabelSelector{MatchLabels: ls},
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{Labels: ls},
+						Spec: corev1.PodSpec{
+							Containers: []corev1.Container{{
+								Name:  "minecraft",
+								Image: "example.com/image:test",
+								EnvFrom: []corev1.EnvFromSource{{
+									ConfigMapRef: &corev1.ConfigMapEnvSource{
+										LocalObjectReference: corev1.LocalObjectReference{Name: legacyConfigMapName},
+									},
+								}},
+							}},
+						},
+					},
+				},
+			}
+			Expect(controllerutil.SetControllerReference(minecraft, legacy, k8sClient.Scheme())).To(Succeed())
+			Expect(k8sClient.Create(ctx, legacy)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking that the spec accepts the EULA and the servers keep running")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Spec.Config.EULA).To(HaveValue(BeTrue()))
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(1)))
+		})
+	})
+
+	Context("Minecraft exposure", func() {
+
+		const MinecraftName = "test-minecraft-service"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should expose the server as defined in the service spec", func() {
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Service: cachev1alpha1.ServiceSpec{
+						Type:        corev1.ServiceTypeNodePort,
+						Port:        25570,
+						NodePort:    30565,
+						Annotations: map[string]string{"example.com/exposed": "true"},
+						ExtraPorts: []cachev1alpha1.ServicePort{{
+							Name:     "bedrock",
+							Port:     19132,
+							Protocol: corev1.ProtocolUDP,
+						}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			minecraftReconciler := &MinecraftReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the Service matches the service spec")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, service)).To(Succeed())
+			Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeNodePort))
+			Expect(service.Annotations).To(HaveKeyWithValue("example.com/exposed", "true"))
+			Expect(service.Spec.Ports).To(HaveLen(2))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(25570)))
+			Expect(service.Spec.Ports[0].NodePort).To(Equal(int32(30565)))
+			Expect(service.Spec.Ports[0].TargetPort.IntVal).To(Equal(int32(25565)))
+			Expect(service.Spec.Ports[1].Protocol).To(Equal(corev1.ProtocolUDP))
+			Expect(service.Spec.Ports[1].TargetPort.IntVal).To(Equal(int32(19132)))
+
+			By("Checking if the extra port is exposed by the server container")
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			Expect(found.Spec.Template.Spec.Containers[0].Ports).To(ContainElement(corev1.ContainerPort{
+				Name:          "bedrock",
+				ContainerPort: 19132,
+				Protocol:      corev1.ProtocolUDP,
+			}))
+		})
+	})
+
+	Context("Minecraft version upgrades", func() {
+
+		const MinecraftName = "test-minecraft-upgrade"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		configMapName := types.NamespacedName{
+			Name:      MinecraftName + "-config",
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should back up the world before rolling out a new version", func() {
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:    1,
+					Config:  cachev1alpha1.ServerConfig{EULA: &eula},
+					Version: "1.20.4",
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			reconcileMinecraft := func() {
+				_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespaceName,
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}
+			reconcileMinecraft()
+
+			By("Recording the version the world runs and creating its volume")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Status.CurrentVersion = "1.20.4"
+			Expect(k8sClient.Status().Update(ctx, minecraft)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      worldVolumeName + "-" + MinecraftName + "-0",
+					Namespace: namespace.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			})).To(Succeed())
+
+			By("Refusing to downgrade the world")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Spec.Version = "1.19.4"
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			reconcileMinecraft()
+
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(meta.FindStatusCondition(minecraft.Status.Conditions, typeProgressingMinecraft)).To(
+				HaveField("Reason", "DowngradeRefused"))
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, configMapName, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("VERSION", "1.20.4"))
+
+			By("Backing up the world before upgrading")
+			minecraft.Spec.Version = "1.21"
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			reconcileMinecraft()
+
+			jobs := &batchv1.JobList{}
+			Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace.Name),
+				client.MatchingLabels{upgradeJobLabel: "true"})).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(1))
+			Expect(jobs.Items[0].Spec.Suspend).To(HaveValue(BeFalse()))
+			Expect(jobs.Items[0].Spec.ActiveDeadlineSeconds).To(HaveValue(Equal(int64(backupDeadline.Seconds()))))
+			Expect(k8sClient.Get(ctx, configMapName, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("VERSION", "1.20.4"))
+
+			By("Rolling out the new version once the backup completed")
+			job := &jobs.Items[0]
+			completed := metav1.NewTime(time.Now().Truncate(time.Second))
+			job.Status.StartTime = &completed
+			job.Status.CompletionTime = &completed
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+			})
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+			reconcileMinecraft()
+
+			Expect(k8sClient.Get(ctx, configMapName, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("VERSION", "1.21"))
+
+			By("Checking the completed backup is recorded in the status")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.LastBackupTime).NotTo(BeNil())
+			Expect(minecraft.Status.LastBackupTime.Time).To(BeTemporally("==", completed.Time))
+		})
+	})
+
+	Context("Minecraft server types", func() {
+
+		const MinecraftName = "test-minecraft-types"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should configure the server type and reject unsupported options", func() {
+			By("Rejecting an option the server type does not support")
+			invalid := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:        1,
+					Config:      cachev1alpha1.ServerConfig{EULA: &eula},
+					Type:        cachev1alpha1.ServerTypeForge,
+					TypeOptions: cachev1alpha1.ServerTypeOptions{Build: "100"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, invalid)).NotTo(Succeed())
+
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:        1,
+					Config:      cachev1alpha1.ServerConfig{EULA: &eula},
+					Type:        cachev1alpha1.ServerTypeFabric,
+					TypeOptions: cachev1alpha1.ServerTypeOptions{LoaderVersion: "0.15.11"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			minecraftReconciler := &MinecraftReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the server type is rendered into the configuration")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("TYPE", "FABRIC"))
+			Expect(cm.Data).To(HaveKeyWithValue("FABRIC_LOADER_VERSION", "0.15.11"))
+		})
+	})
+
+	Context("Minecraft Bedrock edition", func() {
+
+		const MinecraftName = "test-minecraft-bedrock"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Bedrock Operand image")
+			Expect(os.Setenv("MINECRAFT_BEDROCK_IMAGE", "example.com/bedrock:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Bedrock Operand image")
+			_ = os.Unsetenv("MINECRAFT_BEDROCK_IMAGE")
+		})
+
+		It("should run the Bedrock image and expose UDP ports", func() {
+			By("Rejecting a server type the Bedrock edition does not support")
+			invalid := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:    1,
+					Edition: cachev1alpha1.EditionBedrock,
+					Type:    cachev1alpha1.ServerTypePaper,
+				},
+			}
+			Expect(k8sClient.Create(ctx, invalid)).NotTo(Succeed())
+
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:    1,
+					Edition: cachev1alpha1.EditionBedrock,
+					Config: cachev1alpha1.ServerConfig{
+						EULA: &eula,
+						MOTD: "Bedrock on Kubernetes",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			minecraftReconciler := &MinecraftReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the StatefulSet runs the Bedrock image with a readiness check")
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			container := found.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("example.com/bedrock:test"))
+			Expect(container.ReadinessProbe).NotTo(BeNil())
+			Expect(container.ReadinessProbe.Exec).NotTo(BeNil())
+
+			By("Checking if the Bedrock properties are rendered into the configuration")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("SERVER_NAME", "Bedrock on Kubernetes"))
+			Expect(cm.Data).NotTo(HaveKey("TYPE"))
+
+			By("Checking if the Service exposes the UDP game ports")
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, service)).To(Succeed())
+			Expect(service.Spec.Ports).To(HaveLen(2))
+			Expect(service.Spec.Ports[0].Protocol).To(Equal(corev1.ProtocolUDP))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(19132)))
+			Expect(service.Spec.Ports[1].Port).To(Equal(int32(19133)))
+		})
+	})
+
+	Context("Minecraft server status", func() {
+
+		const MinecraftName = "test-minecraft-status"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should only be available once the server answers the status request", func() {
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Probes: cachev1alpha1.ProbesSpec{StartupTimeoutSeconds: 300},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			var pinged []string
+			minecraftReconciler := &MinecraftReconciler{
+				Client: k8sClient,
+				Scheme: k8sClient.Scheme(),
+				Ping: func(_ context.Context, address string) (*slp.Status, error) {
+					pinged = append(pinged, address)
+					return &slp.Status{
+						Version:     slp.Version{Name: "1.21", Protocol: 767},
+						Players:     slp.Players{Max: 20, Online: 2},
+						Description: slp.Description{Text: "A Minecraft Server"},
+					}, nil
+				},
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the server container is probed")
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			container := found.Spec.Template.Spec.Containers[0]
+			Expect(container.StartupProbe).NotTo(BeNil())
+			Expect(container.StartupProbe.FailureThreshold).To(Equal(int32(30)))
+			Expect(container.ReadinessProbe).NotTo(BeNil())
+			Expect(container.ReadinessProbe.Exec.Command).To(Equal([]string{"mc-health"}))
+			Expect(container.LivenessProbe).NotTo(BeNil())
+
+			By("Checking the server is not available before it is ready")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(minecraft.Status.Conditions, typeAvailableMinecraft)).To(BeTrue())
+			Expect(minecraft.Status.Phase).To(Equal(cachev1alpha1.MinecraftPhasePending))
+			Expect(minecraft.Status.ObservedGeneration).To(Equal(minecraft.Generation))
+			Expect(minecraft.Status.Address).To(Equal(
+				fmt.Sprintf("%s.%s.svc:25565", MinecraftName, namespace.Name)))
+			Expect(pinged).To(BeEmpty())
+
+			By("Marking the server pod and the StatefulSet ready")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.10"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			found.Status.Replicas = 1
+			found.Status.ReadyReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, found)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking the server is pinged and its status reported")
+			Expect(pinged).To(Equal([]string{"10.0.0.10:25565"}))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(minecraft.Status.Conditions, typeAvailableMinecraft)).To(BeTrue())
+			Expect(minecraft.Status.ServerVersion).To(Equal("1.21"))
+			Expect(minecraft.Status.MOTD).To(Equal("A Minecraft Server"))
+			Expect(minecraft.Status.OnlinePlayers).To(Equal(int32(2)))
+			Expect(minecraft.Status.MaxPlayers).To(Equal(int32(20)))
+			Expect(minecraft.Status.Phase).To(Equal(cachev1alpha1.MinecraftPhaseRunning))
+			Expect(minecraft.Status.ReadyReplicas).To(Equal(int32(1)))
+		})
+	})
+
+	Context("Minecraft RCON", func() {
+
+		const MinecraftName = "test-minecraft-rcon"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should enable RCON and save the world before restarting the server", func() {
+			By("Creating the custom resource for the Kind Minecraft")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			type rconCall struct {
+				address, password string
+				commands          []string
+			}
+			var calls []rconCall
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				RCON: func(_ context.Context, address, password string, commands ...string) ([]string, error) {
+					calls = append(calls, rconCall{address, password, commands})
+					return make([]string, len(commands)), nil
+				},
+			}
+
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if a password was generated into the RCON Secret")
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-rcon",
+				Namespace: MinecraftName,
+			}, secret)).To(Succeed())
+			password := string(secret.Data["password"])
+			Expect(password).NotTo(BeEmpty())
+
+			By("Checking if the server container enables RCON with the password")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("ENABLE_RCON", "TRUE"))
+
+			found := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, found)).To(Succeed())
+			container := found.Spec.Template.Spec.Containers[0]
+			Expect(container.Env).To(ContainElement(HaveField("ValueFrom.SecretKeyRef.Name", MinecraftName+"-rcon")))
+			Expect(container.Lifecycle.PreStop.Exec.Command).To(HaveLen(3))
+			Expect(container.Lifecycle.PreStop.Exec.Command[2]).To(HaveSuffix("rcon-cli save-all flush || true\nrcon-cli stop\n"))
+
+			By("Starting a ready server pod")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.11"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			By("Changing the server configuration")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Spec.Config.MOTD = "Restarted"
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the world was saved through RCON before the restart")
+			Expect(calls).To(Equal([]rconCall{{
+				address:  "10.0.0.11:25575",
+				password: password,
+				commands: []string{"save-all flush"},
+			}}))
+		})
+	})
+
+	Context("Minecraft controller deletion policy test", func() {
+
+		const MinecraftName = "test-minecraft-deletion"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should take a final backup before deleting the world volumes", func() {
+			By("Creating a custom resource with the Snapshot deletion policy")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Backup: &cachev1alpha1.BackupSchedule{
+						Schedule: "0 4 * * *",
+						Target: cachev1alpha1.BackupTarget{
+							PersistentVolumeClaim: &cachev1alpha1.PersistentVolumeClaimBackupTarget{ClaimName: "backups"},
+						},
+					},
+					DeletionPolicy: cachev1alpha1.DeletionPolicySnapshot,
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			claim := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "data-" + MinecraftName + "-0",
+					Namespace: namespace.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, claim)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.PersistentVolumeClaimRetentionPolicy.WhenDeleted).
+				To(Equal(appsv1.DeletePersistentVolumeClaimRetentionPolicyType))
+			Expect(sts.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled).
+				To(Equal(appsv1.RetainPersistentVolumeClaimRetentionPolicyType))
+
+			By("Leaving the completed final backup of an earlier instance with the same name")
+			stale := &cachev1alpha1.MinecraftBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-final",
+					Namespace: namespace.Name,
+					Labels:    map[string]string{finalBackupLabel: MinecraftName},
+				},
+				Spec: cachev1alpha1.MinecraftBackupSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: MinecraftName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, stale)).To(Succeed())
+			stale.Status.Phase = cachev1alpha1.BackupPhaseCompleted
+			Expect(k8sClient.Status().Update(ctx, stale)).To(Succeed())
+
+			By("Deleting the custom resource")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, minecraft)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the custom resource is kept until the final backup completed")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Finalizers).To(ContainElement(minecraftFinalizer))
+
+			backup := &cachev1alpha1.MinecraftBackup{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: finalBackupName(minecraft),
+				Namespace: namespace.Name}, backup)).To(Succeed())
+			Expect(backup.Name).To(HavePrefix(MinecraftName + "-final-"))
+			Expect(backup.Labels).To(HaveKeyWithValue(finalBackupUIDLabel, string(minecraft.UID)))
+			Expect(backup.OwnerReferences).To(BeEmpty())
+			Expect(backup.Spec.Target.PersistentVolumeClaim.ClaimName).To(Equal("backups"))
+
+			backup.Status.Phase = cachev1alpha1.BackupPhaseCompleted
+			Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the world volumes and the custom resource were deleted")
+			Eventually(func() bool {
+				err := k8sClient.Get(ctx, types.NamespacedName{Name: claim.Name, Namespace: namespace.Name}, claim)
+				return errors.IsNotFound(err) || (err == nil && claim.DeletionTimestamp != nil)
+			}, time.Minute, time.Second).Should(BeTrue())
+			Eventually(func() bool {
+				return errors.IsNotFound(k8sClient.Get(ctx, typeNamespaceName, minecraft))
+			}, time.Minute, time.Second).Should(BeTrue())
+		})
+	})
+
+	Context("Minecraft controller autoPause test", func() {
+
+		const MinecraftName = "test-minecraft-autopause"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should put idle servers to sleep and wake them up", func() {
+			By("Creating a custom resource with autoPause")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					AutoPause: &cachev1alpha1.AutoPauseSpec{
+						IdleTimeout: metav1.Duration{Duration: 10 * time.Minute},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			var commands []string
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					return &slp.Status{Players: slp.Players{Max: 20, Online: 0}}, nil
+				},
+				RCON: func(_ context.Context, _, _ string, cmds ...string) ([]string, error) {
+					commands = append(commands, cmds...)
+					return make([]string, len(cmds)), nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Marking the server pod and the StatefulSet ready")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.13"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			sts.Status.Replicas = 1
+			sts.Status.ReadyReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, sts)).To(Succeed())
+
+			result, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the idle time is tracked")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.IdleSince).NotTo(BeNil())
+			Expect(minecraft.Status.Phase).To(Equal(cachev1alpha1.MinecraftPhaseRunning))
+			Expect(result.RequeueAfter).To(BeNumerically("<=", statusRefreshInterval))
+
+			By("Putting the servers to sleep once the idle timeout elapsed")
+			minecraft.Status.IdleSince = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, minecraft)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(commands).To(Equal([]string{"save-all flush"}))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.Phase).To(Equal(cachev1alpha1.MinecraftPhaseSleeping))
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(0)))
+
+			By("Waking the servers up")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Annotations = map[string]string{cachev1alpha1.WakeAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(1)))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Annotations).NotTo(HaveKey(cachev1alpha1.WakeAnnotation))
+			Expect(minecraft.Status.Phase).NotTo(Equal(cachev1alpha1.MinecraftPhaseSleeping))
+		})
+	})
+
+	Context("Minecraft controller players test", func() {
+
+		const MinecraftName = "test-minecraft-players"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		playersNamespaceName := types.NamespacedName{
+			Name:      MinecraftName + "-players",
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should write the player lists and apply their changes through RCON", func() {
+			By("Creating a custom resource with players")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Players: &cachev1alpha1.PlayersSpec{
+						Whitelist: []string{"Alice", "Nobody"},
+						Ops:       []cachev1alpha1.OpSpec{{Name: "Alice", Level: 3}},
+						Bans:      []cachev1alpha1.BanSpec{{Name: "Mallory", Reason: "Griefing"}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			var commands []string
+			var resolved []string
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					return &slp.Status{Players: slp.Players{Max: 20}}, nil
+				},
+				RCON: func(_ context.Context, _, _ string, cmds ...string) ([]string, error) {
+					commands = append(commands, cmds...)
+					return make([]string, len(cmds)), nil
+				},
+				Resolve: func(_ context.Context, names []string) (map[string]string, error) {
+					resolved = append(resolved, names...)
+					uuids := map[string]string{}
+					for i, name := range names {
+						if name != "Nobody" {
+							uuids[strings.ToLower(name)] = fmt.Sprintf("00000000-0000-0000-0000-%012d", i)
+						}
+					}
+					return uuids, nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the player lists were written and mounted")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, playersNamespaceName, cm)).To(Succeed())
+			Expect(cm.Data[whitelistKey]).To(ContainSubstring(`"name": "Alice"`))
+			Expect(cm.Data[whitelistKey]).NotTo(ContainSubstring("Nobody"))
+			Expect(cm.Data[opsKey]).To(ContainSubstring(`"level": 3`))
+			Expect(cm.Data[bannedPlayersKey]).To(ContainSubstring(`"reason": "Griefing"`))
+			Expect(resolved).To(ConsistOf("Alice", "Nobody", "Mallory"))
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].VolumeMounts).To(ContainElement(corev1.VolumeMount{
+				Name: playersVolumeName, MountPath: playersMountPath, ReadOnly: true}))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(minecraft),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("ENFORCE_WHITELIST", "TRUE"))
+			Expect(commands).To(BeEmpty())
+
+			By("Marking the server pod ready")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.18"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			By("Changing the players while the server runs")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Spec.Players.Whitelist = []string{"Alice", "Bob"}
+			minecraft.Spec.Players.Bans = nil
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			resolved = nil
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the changes were applied without resolving known players again")
+			Expect(commands).To(Equal([]string{"pardon Mallory", "whitelist add Bob"}))
+			Expect(resolved).To(Equal([]string{"Bob"}))
+			Expect(k8sClient.Get(ctx, playersNamespaceName, cm)).To(Succeed())
+			Expect(cm.Data[whitelistKey]).To(ContainSubstring(`"name": "Bob"`))
+			Expect(cm.Data[bannedPlayersKey]).To(Equal("[]"))
+		})
+	})
+
+	Context("Minecraft controller artifacts test", func() {
+
+		const MinecraftName = "test-minecraft-artifacts"
+		const Checksum = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VARs which store the Operand images")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+			Expect(os.Setenv("ARTIFACT_IMAGE", "example.com/artifacts:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VARs which store the Operand images")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+			_ = os.Unsetenv("ARTIFACT_IMAGE")
+		})
+
+		It("should install the plugins and report them in the status", func() {
+			By("Rejecting mods on a server type loading plugins")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Type:   cachev1alpha1.ServerTypePaper,
+					Mods: []cachev1alpha1.Artifact{{Name: "sodium.jar", URL: "https://example.com/sodium.jar",
+						Checksum: Checksum}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).NotTo(Succeed())
+
+			By("Rejecting a download without a checksum")
+			minecraft.Spec.Mods = nil
+			minecraft.Spec.Plugins = []cachev1alpha1.Artifact{{Name: "worldedit.jar",
+				URL: "https://example.com/worldedit.jar"}}
+			Expect(k8sClient.Create(ctx, minecraft)).NotTo(Succeed())
+
+			By("Creating a custom resource with plugins")
+			minecraft.Spec.Plugins = []cachev1alpha1.Artifact{
+				{Name: "worldedit.jar", URL: "https://example.com/worldedit.jar", Checksum: Checksum},
+				{Name: "custom.jar", ConfigMap: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "plugins"},
+					Key:                  "custom.jar",
+				}},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					return &slp.Status{Players: slp.Players{Max: 20}}, nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if an init container installs the plugins")
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+			initContainer := sts.Spec.Template.Spec.InitContainers[0]
+			Expect(initContainer.Image).To(Equal("example.com/artifacts:test"))
+			Expect(initContainer.Env).To(ContainElement(corev1.EnvVar{Name: "ARTIFACTS", Value: "" +
+				"plugins worldedit.jar url https://example.com/worldedit.jar " + Checksum[len("sha256:"):] + "\n" +
+				"plugins custom.jar file /sources/plugins/custom.jar"}))
+			Expect(sts.Spec.Template.Spec.Volumes).To(ContainElement(HaveField("Name", artifactSourcesVolumeName)))
+
+			By("Recording the plugins the init container reported")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+				Name:  artifactsContainerName,
+				Image: "example.com/artifacts:test",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					ExitCode: 0,
+					Message:  "# 1\nplugins/worldedit.jar " + Checksum[len("sha256:"):] + "\n",
+				}},
+			}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.Artifacts).To(Equal([]cachev1alpha1.ServerArtifacts{{
+				Server:    MinecraftName + "-0",
+				Artifacts: []cachev1alpha1.InstalledArtifact{{Path: "plugins/worldedit.jar", Checksum: Checksum}},
+			}}))
+
+			By("Keeping the init container until it removed the plugins")
+			minecraft.Spec.Plugins = nil
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+			Expect(sts.Spec.Template.Spec.InitContainers[0].Env[0].Value).To(BeEmpty())
+
+			By("Keeping the init container once it removed the plugins")
+			pod.Status.InitContainerStatuses[0].State.Terminated.Message = "# 0\n"
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+			for i := 0; i < 2; i++ {
+				_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespaceName,
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.Artifacts).To(Equal([]cachev1alpha1.ServerArtifacts{{
+				Server: MinecraftName + "-0",
+			}}))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.InitContainers).To(HaveLen(1))
+		})
+	})
+
+	Context("Minecraft controller artifacts reports", func() {
+		It("should flag the reports truncated by the kubelet", func() {
+			pod := corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "test-minecraft-artifacts-0"}}
+			pod.Status.InitContainerStatuses = []corev1.ContainerStatus{{
+				Name: artifactsContainerName,
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Message: "s/worldedit.jar 00\nplugins/essentials.jar 11\n",
+				}},
+			}}
+			Expect(artifactsForPods([]corev1.Pod{pod})).To(Equal([]cachev1alpha1.ServerArtifacts{{
+				Server:    "test-minecraft-artifacts-0",
+				Artifacts: []cachev1alpha1.InstalledArtifact{{Path: "plugins/essentials.jar", Checksum: "sha256:11"}},
+				Truncated: true,
+			}}))
+
+			pod.Status.InitContainerStatuses[0].State.Terminated.Message = "# 1\nplugins/essentials.jar 11\n"
+			Expect(artifactsForPods([]corev1.Pod{pod})).To(Equal([]cachev1alpha1.ServerArtifacts{{
+				Server:    "test-minecraft-artifacts-0",
+				Artifacts: []cachev1alpha1.InstalledArtifact{{Path: "plugins/essentials.jar", Checksum: "sha256:11"}},
+			}}))
+		})
+	})
+
+	Context("Minecraft controller jvm test", func() {
+
+		const MinecraftName = "test-minecraft-jvm"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should derive the heap from the memory limit", func() {
+			By("Rejecting Aikar's flags with another garbage collector")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Resources: corev1.ResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1")},
+						Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+					},
+					JVM: &cachev1alpha1.JVMSpec{
+						Flags: cachev1alpha1.JVMFlagsAikar,
+						GC:    cachev1alpha1.GarbageCollectorZGC,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).NotTo(Succeed())
+
+			By("Creating the custom resource with a memory limit")
+			minecraft.Spec.JVM.GC = ""
+			minecraft.Spec.JVM.ExtraArgs = []string{"-Dlog4j2.formatMsgNoLookups=true"}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					return &slp.Status{Players: slp.Players{Max: 20}}, nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the heap takes the default share of the memory limit")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("MAX_MEMORY", "3072M"))
+			Expect(cm.Data).To(HaveKeyWithValue("INIT_MEMORY", "3072M"))
+			Expect(cm.Data).To(HaveKeyWithValue("USE_AIKAR_FLAGS", "TRUE"))
+			Expect(cm.Data).To(HaveKeyWithValue("JVM_OPTS", "-Dlog4j2.formatMsgNoLookups=true"))
+			Expect(cm.Data).NotTo(HaveKey("JVM_XX_OPTS"))
+
+			By("Checking if the resources are set on the server container")
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			resources := sts.Spec.Template.Spec.Containers[0].Resources
+			Expect(resources.Limits.Memory().String()).To(Equal("4Gi"))
+			Expect(resources.Requests.Cpu().String()).To(Equal("1"))
+
+			By("Resizing the heap with the ratio of the spec")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Spec.JVM.HeapPercentage = 50
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      MinecraftName + "-config",
+				Namespace: MinecraftName,
+			}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("MAX_MEMORY", "2048M"))
+		})
+	})
+
+	Context("Minecraft controller shutdown test", func() {
+
+		const MinecraftName = "test-minecraft-shutdown"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should warn the players and save the worlds before the pods terminate", func() {
+			By("Rejecting a countdown longer than the grace period")
+			countdown := int32(120)
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Shutdown: &cachev1alpha1.ShutdownSpec{
+						GracePeriodSeconds: 90,
+						CountdownSeconds:   &countdown,
+						Message:            "Restarting for maintenance, it's quick",
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).NotTo(Succeed())
+
+			By("Creating the custom resource with a countdown")
+			countdown = 45
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the grace period is set on the pods")
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.TerminationGracePeriodSeconds).To(HaveValue(Equal(int64(90))))
+
+			By("Checking if the preStop hook counts down, saves the worlds and stops the server")
+			message := "'Restarting for maintenance, it'\\''s quick"
+			Expect(sts.Spec.Template.Spec.Containers[0].Lifecycle.PreStop.Exec.Command).To(Equal([]string{
+				"/bin/sh", "-c",
+				"if ! rcon-cli list 2>/dev/null | grep -q 'There are 0 '; then\n" +
+					"  rcon-cli say " + message + " in 45 seconds' || true\n" +
+					"  sleep 15\n" +
+					"  rcon-cli say " + message + " in 30 seconds' || true\n" +
+					"  sleep 20\n" +
+					"  rcon-cli say " + message + " in 10 seconds' || true\n" +
+					"  sleep 5\n" +
+					"  rcon-cli say " + message + " in 5 seconds' || true\n" +
+					"  sleep 1\n" +
+					"  rcon-cli say " + message + " in 4 seconds' || true\n" +
+					"  sleep 1\n" +
+					"  rcon-cli say " + message + " in 3 seconds' || true\n" +
+					"  sleep 1\n" +
+					"  rcon-cli say " + message + " in 2 seconds' || true\n" +
+					"  sleep 1\n" +
+					"  rcon-cli say " + message + " in 1 second' || true\n" +
+					"  sleep 1\n" +
+					"fi\n" +
+					"rcon-cli save-all flush || true\n" +
+					"rcon-cli stop\n",
+			}))
+
+			By("Stopping the server right away without a countdown")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			countdown = 0
+			minecraft.Spec.Shutdown.CountdownSeconds = &countdown
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Spec.Containers[0].Lifecycle.PreStop.Exec.Command[2]).To(
+				Equal("rcon-cli save-all flush || true\nrcon-cli stop\n"))
+		})
+	})
+
+	Context("Minecraft controller maintenance test", func() {
+
+		const MinecraftName = "test-minecraft-maintenance"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should stage the changes restarting the servers while players are online", func() {
+			By("Rejecting the WindowOnly policy without a window")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Maintenance: &cachev1alpha1.MaintenanceSpec{
+						Policy: cachev1alpha1.MaintenancePolicyWindowOnly,
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).NotTo(Succeed())
+
+			By("Creating the custom resource with a window far away")
+			minecraft.Spec.Maintenance.Policy = cachev1alpha1.MaintenancePolicyWhenEmpty
+			minecraft.Spec.Maintenance.Windows = []cachev1alpha1.MaintenanceWindow{{Schedule: "0 4 29 2 *"}}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			online := int32(2)
+			var pingErr error
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					if pingErr != nil {
+						return nil, pingErr
+					}
+					return &slp.Status{Players: slp.Players{Online: online, Max: 20}}, nil
+				},
+				RCON: func(_ context.Context, _, _ string, commands ...string) ([]string, error) {
+					return make([]string, len(commands)), nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Starting a ready server with players online")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.14"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			configHash := sts.Spec.Template.Annotations[configHashAnnotation]
+			sts.Status.Replicas = 1
+			sts.Status.ReadyReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, sts)).To(Succeed())
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Changing the server configuration")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.OnlinePlayers).To(Equal(int32(2)))
+			minecraft.Spec.Config.MOTD = "Restarted"
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+
+			result, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+
+			By("Checking if the change is staged while players are online")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, configHash))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			condition := meta.FindStatusCondition(minecraft.Status.Conditions, typePendingRestartMinecraft)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("configuration"))
+			Expect(condition.Message).To(ContainSubstring("2 players are online"))
+
+			By("Checking if staging does not take over the fields defaulted by the API server")
+			applied, err := appliedPodTemplate(sts)
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(applied.Spec.DNSPolicy).To(BeEmpty())
+			Expect(applied.Spec.SchedulerName).To(BeEmpty())
+
+			By("Keeping the change staged while the servers do not answer")
+			online = 0
+			pingErr = fmt.Errorf("connection refused")
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, configHash))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			condition = meta.FindStatusCondition(minecraft.Status.Conditions, typePendingRestartMinecraft)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("unknown"))
+
+			By("Applying the change once the players left")
+			pingErr = nil
+			online = 0
+			for i := 0; i < 2; i++ {
+				_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: typeNamespaceName,
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Annotations[configHashAnnotation]).NotTo(Equal(configHash))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			condition = meta.FindStatusCondition(minecraft.Status.Conditions, typePendingRestartMinecraft)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionFalse))
+		})
+	})
+
+	Context("Minecraft controller maintenance window test", func() {
+
+		const MinecraftName = "test-minecraft-window"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should keep the configuration of a pod recreated outside the window", func() {
+			By("Creating the custom resource with a window far away")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula, MOTD: "Before"},
+					Maintenance: &cachev1alpha1.MaintenanceSpec{
+						Policy:  cachev1alpha1.MaintenancePolicyWindowOnly,
+						Windows: []cachev1alpha1.MaintenanceWindow{{Schedule: "0 4 29 2 *"}},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				Ping: func(_ context.Context, _ string) (*slp.Status, error) {
+					return &slp.Status{Players: slp.Players{Max: 20}}, nil
+				},
+				RCON: func(_ context.Context, _, _ string, commands ...string) ([]string, error) {
+					return make([]string, len(commands)), nil
+				},
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			configHash := sts.Spec.Template.Annotations[configHashAnnotation]
+			sts.Status.Replicas = 1
+			sts.Status.ReadyReplicas = 1
+			Expect(k8sClient.Status().Update(ctx, sts)).To(Succeed())
+
+			By("Changing the server configuration outside the window")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Spec.Config.MOTD = "After"
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Recreating the pod of the server while the change is staged")
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, pod, client.GracePeriodSeconds(0))).To(Succeed())
+			pod.ResourceVersion = ""
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the recreated pod loads the configuration applied last")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(sts.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, configHash))
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(minecraft),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("MOTD", "Before"))
+			Expect(hashForData(cm.Data)).To(Equal(configHash))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			condition := meta.FindStatusCondition(minecraft.Status.Conditions, typePendingRestartMinecraft)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Status).To(Equal(metav1.ConditionTrue))
+			Expect(condition.Message).To(ContainSubstring("configuration"))
+		})
+	})
+
+	Context("Minecraft controller EULA test", func() {
+
+		const MinecraftName = "test-minecraft-eula"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should not start the servers until the EULA is accepted", func() {
+			By("Creating the custom resource without accepting the EULA")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size: 1,
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Spec.Config.EULA).To(BeNil())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the StatefulSet runs no server")
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(0)))
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(minecraft),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("EULA", "FALSE"))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			condition := meta.FindStatusCondition(minecraft.Status.Conditions, typeAvailableMinecraft)
+			Expect(condition).NotTo(BeNil())
+			Expect(condition.Reason).To(Equal("EULANotAccepted"))
+
+			By("Keeping an explicit false on update")
+			declined := false
+			minecraft.Spec.Config.EULA = &declined
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Spec.Config.EULA).To(HaveValue(BeFalse()))
+
+			By("Starting the servers once the EULA is accepted")
+			minecraft.Spec.Config.EULA = &eula
+			Expect(k8sClient.Update(ctx, minecraft)).To(Succeed())
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(1)))
+		})
+	})
 })
@@ -0,0 +1,227 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"time"
+
+	//nolint:golint
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	cachev1beta1 "github.com/example/minecraft-operator/api/v1beta1"
+)
+
+var _ = Describe("Minecraft conversion", func() {
+	eula := true
+	storageClass := "standard"
+	maxPlayers := int32(20)
+	onlineMode := false
+	historyLimit := int32(3)
+	replicas := int32(1)
+	maxHeap := resource.MustParse("3Gi")
+	countdown := int32(30)
+	now := metav1.Now()
+
+	It("should round-trip v1alpha1 through the v1beta1 hub", func() {
+		minecraft := &cachev1alpha1.Minecraft{
+			ObjectMeta: metav1.ObjectMeta{Name: "round-trip", Namespace: "default", Generation: 2},
+			Spec: cachev1alpha1.MinecraftSpec{
+				Size:           2,
+				Edition:        cachev1alpha1.EditionJava,
+				Version:        "1.20.4",
+				Image:          "example.com/minecraft:pinned",
+				AllowDowngrade: true,
+				Type:           cachev1alpha1.ServerTypeFabric,
+				TypeOptions:    cachev1alpha1.ServerTypeOptions{LoaderVersion: "0.15.11"},
+				Config: cachev1alpha1.ServerConfig{
+					EULA:       &eula,
+					Difficulty: cachev1alpha1.DifficultyHard,
+					GameMode:   cachev1alpha1.GameModeCreative,
+					MOTD:       "Round trip",
+					MaxPlayers: &maxPlayers,
+					OnlineMode: &onlineMode,
+				},
+				Service: cachev1alpha1.ServiceSpec{
+					Type:        corev1.ServiceTypeNodePort,
+					Port:        25565,
+					NodePort:    30565,
+					Annotations: map[string]string{"example.com/key": "value"},
+					ExtraPorts:  []cachev1alpha1.ServicePort{{Name: "map", Port: 8123, Protocol: corev1.ProtocolTCP}},
+				},
+				Storage: cachev1alpha1.StorageSpec{
+					Size:             resource.MustParse("20Gi"),
+					StorageClassName: &storageClass,
+					AccessMode:       corev1.ReadWriteOnce,
+					RetainPolicy:     cachev1alpha1.StorageRetainPolicyDelete,
+				},
+				Probes: cachev1alpha1.ProbesSpec{Type: cachev1alpha1.ProbeTypeTCP, PeriodSeconds: 5},
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+				},
+				JVM: &cachev1alpha1.JVMSpec{
+					MaxHeap:   &maxHeap,
+					Flags:     cachev1alpha1.JVMFlagsAikar,
+					GC:        cachev1alpha1.GarbageCollectorG1,
+					ExtraArgs: []string{"-Dlog4j2.formatMsgNoLookups=true"},
+				},
+				Shutdown: &cachev1alpha1.ShutdownSpec{
+					GracePeriodSeconds: 120,
+					CountdownSeconds:   &countdown,
+					Message:            "Restarting",
+				},
+				Maintenance: &cachev1alpha1.MaintenanceSpec{
+					Policy: cachev1alpha1.MaintenancePolicyWhenEmpty,
+					Windows: []cachev1alpha1.MaintenanceWindow{
+						{Schedule: "0 4 * * 1", Duration: metav1.Duration{Duration: 2 * time.Hour}},
+					},
+				},
+				Backup: &cachev1alpha1.BackupSchedule{
+					Schedule: "0 4 * * *",
+					Target: cachev1alpha1.BackupTarget{S3: &cachev1alpha1.S3BackupTarget{
+						Bucket:            "worlds",
+						CredentialsSecret: corev1.LocalObjectReference{Name: "s3"},
+					}},
+					HistoryLimit: &historyLimit,
+				},
+				DeletionPolicy: cachev1alpha1.DeletionPolicySnapshot,
+				AutoPause: &cachev1alpha1.AutoPauseSpec{
+					IdleTimeout: metav1.Duration{Duration: 600000000000},
+					Hostnames:   []string{"survival.example.com"},
+				},
+				Players: &cachev1alpha1.PlayersSpec{
+					Whitelist: []string{"Alice"},
+					Ops:       []cachev1alpha1.OpSpec{{Name: "Alice", Level: 4}},
+					Bans:      []cachev1alpha1.BanSpec{{Name: "Mallory", Reason: "Griefing"}},
+				},
+				Mods: []cachev1alpha1.Artifact{{
+					Name:      "lithium.jar",
+					ConfigMap: &corev1.ConfigMapKeySelector{Key: "lithium.jar"},
+				}},
+			},
+			Status: cachev1alpha1.MinecraftStatus{
+				Conditions: []metav1.Condition{{
+					Type: typeAvailableMinecraft, Status: metav1.ConditionTrue, Reason: "Reconciling",
+					LastTransitionTime: now,
+				}},
+				Phase:              cachev1alpha1.MinecraftPhaseRunning,
+				ObservedGeneration: 2,
+				ReadyReplicas:      2,
+				LastBackupTime:     &now,
+				CurrentVersion:     "1.20.4",
+				OnlinePlayers:      1,
+				Artifacts: []cachev1alpha1.ServerArtifacts{{
+					Server:    "round-trip-0",
+					Artifacts: []cachev1alpha1.InstalledArtifact{{Path: "mods/lithium.jar", Checksum: "sha256:00"}},
+					Truncated: true,
+				}},
+			},
+		}
+
+		hub := &cachev1beta1.Minecraft{}
+		Expect(minecraft.ConvertTo(hub)).To(Succeed())
+		Expect(hub.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+		Expect(hub.Spec.Server.Type).To(Equal(cachev1beta1.ServerTypeFabric))
+		Expect(hub.Spec.Server.Options.LoaderVersion).To(Equal("0.15.11"))
+		Expect(hub.Spec.Properties.MOTD).To(Equal("Round trip"))
+
+		converted := &cachev1alpha1.Minecraft{}
+		Expect(converted.ConvertFrom(hub)).To(Succeed())
+		Expect(converted).To(Equal(minecraft))
+	})
+
+	It("should round-trip v1beta1 through v1alpha1", func() {
+		hub := &cachev1beta1.Minecraft{
+			ObjectMeta: metav1.ObjectMeta{Name: "round-trip", Namespace: "default"},
+			Spec: cachev1beta1.MinecraftSpec{
+				Replicas: &replicas,
+				Server: cachev1beta1.ServerSpec{
+					Edition: cachev1beta1.EditionJava,
+					Type:    cachev1beta1.ServerTypePaper,
+					Version: "LATEST",
+					Options: cachev1beta1.ServerTypeOptions{Build: "496"},
+				},
+				Properties: cachev1beta1.ServerProperties{EULA: &eula, LevelName: "world"},
+				Service:    cachev1beta1.ServiceSpec{Type: corev1.ServiceTypeClusterIP},
+				Storage: cachev1beta1.StorageSpec{
+					Size: resource.MustParse("10Gi"),
+				},
+				Backup: &cachev1beta1.BackupSchedule{
+					Schedule: "@daily",
+					Target: cachev1beta1.BackupTarget{
+						PersistentVolumeClaim: &cachev1beta1.PersistentVolumeClaimBackupTarget{ClaimName: "backups"},
+					},
+				},
+				Plugins: []cachev1beta1.Artifact{{
+					Name:     "worldedit.jar",
+					URL:      "https://example.com/worldedit.jar",
+					Checksum: "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef",
+				}},
+			},
+			Status: cachev1beta1.MinecraftStatus{
+				Phase:         cachev1beta1.MinecraftPhaseSleeping,
+				IdleSince:     &now,
+				ServerVersion: "1.21",
+			},
+		}
+
+		spoke := &cachev1alpha1.Minecraft{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Spec.Size).To(Equal(int32(1)))
+		Expect(spoke.Spec.Type).To(Equal(cachev1alpha1.ServerTypePaper))
+		Expect(spoke.Spec.TypeOptions.Build).To(Equal("496"))
+		Expect(spoke.Spec.Config.LevelName).To(Equal("world"))
+
+		converted := &cachev1beta1.Minecraft{}
+		Expect(spoke.ConvertTo(converted)).To(Succeed())
+		Expect(converted).To(Equal(hub))
+	})
+
+	It("should keep a replicas of 0 through the stored v1alpha1", func() {
+		none := int32(0)
+		hub := &cachev1beta1.Minecraft{
+			ObjectMeta: metav1.ObjectMeta{Name: "stopped", Namespace: "default"},
+			Spec: cachev1beta1.MinecraftSpec{
+				Replicas:   &none,
+				Properties: cachev1beta1.ServerProperties{EULA: &eula},
+			},
+		}
+
+		spoke := &cachev1alpha1.Minecraft{}
+		Expect(spoke.ConvertFrom(hub)).To(Succeed())
+		Expect(spoke.Spec.Size).To(BeZero())
+
+		By("Storing the v1alpha1 object the way the API server does")
+		data, err := json.Marshal(spoke)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"size":0`))
+		stored := &cachev1alpha1.Minecraft{}
+		Expect(json.Unmarshal(data, stored)).To(Succeed())
+
+		converted := &cachev1beta1.Minecraft{}
+		Expect(stored.ConvertTo(converted)).To(Succeed())
+		Expect(converted.Spec.Replicas).To(HaveValue(BeZero()))
+		data, err = json.Marshal(converted)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"replicas":0`))
+	})
+})
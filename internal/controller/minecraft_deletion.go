@@ -0,0 +1,165 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// finalBackupLabel is set on the final MinecraftBackup taken when a custom
+	// resource with the Snapshot deletion policy is deleted, with its name
+	finalBackupLabel = "cache.example.com/final-backup-for"
+	// finalBackupUIDLabel is set on the final MinecraftBackup with the UID of
+	// the custom resource, telling it apart from the final backup of an earlier
+	// instance with the same name
+	finalBackupUIDLabel = "cache.example.com/final-backup-for-uid"
+)
+
+// deletionPolicyForMinecraft returns the deletion policy of the custom
+// resource, derived from the retain policy of the storage when it is not set
+func deletionPolicyForMinecraft(minecraft *cachev1alpha1.Minecraft) cachev1alpha1.DeletionPolicy {
+	if minecraft.Spec.DeletionPolicy != "" {
+		return minecraft.Spec.DeletionPolicy
+	}
+	if minecraft.Spec.Storage.RetainPolicy == cachev1alpha1.StorageRetainPolicyDelete {
+		return cachev1alpha1.DeletionPolicyDelete
+	}
+	return cachev1alpha1.DeletionPolicyRetain
+}
+
+// deletesWorldVolumes returns whether the world volumes are deleted with the
+// custom resource. The Snapshot policy keeps them when the final backup is
+// archived onto them.
+func deletesWorldVolumes(minecraft *cachev1alpha1.Minecraft) bool {
+	switch deletionPolicyForMinecraft(minecraft) {
+	case cachev1alpha1.DeletionPolicyDelete:
+		return true
+	case cachev1alpha1.DeletionPolicySnapshot:
+		target := finalBackupTarget(minecraft)
+		return target.PersistentVolumeClaim != nil || target.S3 != nil
+	}
+	return false
+}
+
+// finalBackupTarget returns where the final backup is archived, the target of
+// the backup schedule
+func finalBackupTarget(minecraft *cachev1alpha1.Minecraft) cachev1alpha1.BackupTarget {
+	if minecraft.Spec.Backup == nil {
+		return cachev1alpha1.BackupTarget{}
+	}
+	return minecraft.Spec.Backup.Target
+}
+
+// finalBackupName returns the name of the final MinecraftBackup of the custom
+// resource. It is unique to the instance, as the final backups of the earlier
+// instances with the same name are kept.
+func finalBackupName(minecraft *cachev1alpha1.Minecraft) string {
+	uid := string(minecraft.UID)
+	if len(uid) > 8 {
+		uid = uid[:8]
+	}
+	return fmt.Sprintf("%s-final-%s", minecraft.Name, uid)
+}
+
+// takeFinalBackup creates the final MinecraftBackup of the custom resource and
+// returns whether it completed. The backup is not owned by the custom resource
+// so it outlives it. A failed backup is an error, the custom resource is only
+// removed once the backup is deleted to be taken again, or the deletion policy
+// changed.
+func (r *MinecraftReconciler) takeFinalBackup(ctx context.Context, minecraft *cachev1alpha1.Minecraft) (bool, error) {
+	log := log.FromContext(ctx)
+
+	backup := &cachev1alpha1.MinecraftBackup{}
+	err := r.Get(ctx, types.NamespacedName{Name: finalBackupName(minecraft), Namespace: minecraft.Namespace}, backup)
+	if apierrors.IsNotFound(err) {
+		ls := labelsForMinecraft(minecraft)
+		ls[finalBackupLabel] = minecraft.Name
+		ls[finalBackupUIDLabel] = string(minecraft.UID)
+		backup = &cachev1alpha1.MinecraftBackup{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      finalBackupName(minecraft),
+				Namespace: minecraft.Namespace,
+				Labels:    ls,
+			},
+			Spec: cachev1alpha1.MinecraftBackupSpec{
+				MinecraftRef: corev1.LocalObjectReference{Name: minecraft.Name},
+				Target:       finalBackupTarget(minecraft),
+			},
+		}
+		log.Info("Creating the final backup of Minecraft", "MinecraftBackup.Name", backup.Name)
+		if err := r.Create(ctx, backup); err != nil {
+			return false, err
+		}
+		r.Recorder.Event(minecraft, "Normal", "FinalBackup",
+			fmt.Sprintf("Taking the final backup %s before deleting the instance", backup.Name))
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	// A backup with the name which was not taken for this instance says nothing
+	// about its worlds, they are kept until it is removed
+	if backup.Labels[finalBackupUIDLabel] != string(minecraft.UID) {
+		r.Recorder.Event(minecraft, "Warning", "FinalBackupConflict",
+			fmt.Sprintf("MinecraftBackup %s was not taken for this instance, delete it to take the final backup",
+				backup.Name))
+		return false, fmt.Errorf("final backup %s belongs to another instance", backup.Name)
+	}
+
+	switch backup.Status.Phase {
+	case cachev1alpha1.BackupPhaseCompleted:
+		return true, nil
+	case cachev1alpha1.BackupPhaseFailed:
+		r.Recorder.Event(minecraft, "Warning", "FinalBackupFailed",
+			fmt.Sprintf("Final backup %s failed, delete it to retry or change the deletion policy: %s",
+				backup.Name, backup.Status.Message))
+		return false, fmt.Errorf("final backup %s failed: %s", backup.Name, backup.Status.Message)
+	}
+	return false, nil
+}
+
+// deleteWorldVolumes deletes the world volumes of the custom resource. They are
+// only removed once the pods using them are gone.
+func (r *MinecraftReconciler) deleteWorldVolumes(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	claims, err := worldVolumeClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		return err
+	}
+	for _, name := range claims {
+		claim := &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: minecraft.Namespace},
+		}
+		log.Info("Deleting world volume of Minecraft", "PersistentVolumeClaim.Name", name)
+		if err := r.Delete(ctx, claim); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// forwardingContainerName is the name of the init container configuring
+	// the forwarding of the players by a proxy
+	forwardingContainerName = "forwarding"
+	// forwardingPatchPath is where the init container writes the patch the
+	// server applies to its configuration files on startup
+	forwardingPatchPath = worldMountPath + "/.forwarding.json"
+	// forwardingSecretEnv is the environment variable holding the forwarding
+	// secret. The server only substitutes variables with the CFG_ prefix into
+	// the patched files.
+	forwardingSecretEnv = "CFG_FORWARDING_SECRET"
+)
+
+// supportsForwarding returns whether the server type of the custom resource
+// accepts the players forwarded by the given type of proxy. Velocity signs the
+// forwarded players with a secret only Paper and its forks verify, the legacy
+// forwarding of BungeeCord is supported by Spigot and its forks.
+func supportsForwarding(proxyType cachev1alpha1.ProxyType, minecraft *cachev1alpha1.Minecraft) bool {
+	if isBedrock(minecraft) {
+		return false
+	}
+	switch minecraft.Spec.Type {
+	case cachev1alpha1.ServerTypePaper, cachev1alpha1.ServerTypePurpur:
+		return true
+	case cachev1alpha1.ServerTypeSpigot:
+		return proxyType == cachev1alpha1.ProxyTypeBungeeCord
+	}
+	return false
+}
+
+// forwardingTypesForProxy returns the server types the given type of proxy
+// forwards players to
+func forwardingTypesForProxy(proxyType cachev1alpha1.ProxyType) string {
+	if proxyType == cachev1alpha1.ProxyTypeBungeeCord {
+		return "Paper, Purpur and Spigot"
+	}
+	return "Paper and Purpur"
+}
+
+// forwardingConfigured returns whether the servers of the StatefulSet were
+// configured for the forwarding of a proxy
+func forwardingConfigured(sts *appsv1.StatefulSet) bool {
+	if sts == nil {
+		return false
+	}
+	for _, c := range sts.Spec.Template.Spec.InitContainers {
+		if c.Name == forwardingContainerName {
+			return true
+		}
+	}
+	return false
+}
+
+// patchOperation sets a value of a configuration file, the path is a JSONPath
+// into the document
+type patchOperation struct {
+	Set patchSet `json:"$set"`
+}
+
+// patchSet is the value of a $set operation
+type patchSet struct {
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// filePatch holds the operations applied to one configuration file
+type filePatch struct {
+	File string           `json:"file"`
+	Ops  []patchOperation `json:"ops"`
+}
+
+// patchDefinitions is the patch the server applies on startup, see
+// PATCH_DEFINITIONS of the server image
+type patchDefinitions struct {
+	Patches []filePatch `json:"patches"`
+}
+
+// forwardingPatchesForMinecraft returns the changes of the configuration files
+// accepting the players forwarded by the proxy. Without a proxy the forwarding
+// is turned off again, servers then only accept players connecting directly.
+func forwardingPatchesForMinecraft(minecraft *cachev1alpha1.Minecraft,
+	proxy *cachev1alpha1.MinecraftProxy) []filePatch {
+	velocity := proxy != nil && proxy.Spec.Type != cachev1alpha1.ProxyTypeBungeeCord
+	bungeeCord := proxy != nil && proxy.Spec.Type == cachev1alpha1.ProxyTypeBungeeCord
+
+	set := func(path string, value any) patchOperation {
+		return patchOperation{Set: patchSet{Path: path, Value: value}}
+	}
+	patches := []filePatch{{
+		File: path.Join(worldMountPath, "spigot.yml"),
+		Ops:  []patchOperation{set("$.settings.bungeecord", bungeeCord)},
+	}}
+	if minecraft.Spec.Type != cachev1alpha1.ServerTypePaper && minecraft.Spec.Type != cachev1alpha1.ServerTypePurpur {
+		return patches
+	}
+
+	// Paper moved its global settings into config/paper-global.yml in 1.19
+	file, prefix := path.Join(worldMountPath, "config", "paper-global.yml"), "$.proxies.velocity"
+	if cmp, ok := cachev1alpha1.CompareVersions(minecraft.Spec.Version, "1.19"); ok && cmp < 0 {
+		file, prefix = path.Join(worldMountPath, "paper.yml"), "$.settings.velocity-support"
+	}
+	ops := []patchOperation{set(prefix+".enabled", velocity)}
+	if velocity {
+		ops = append(ops,
+			set(prefix+".online-mode", true),
+			set(prefix+".secret", fmt.Sprintf("${%s}", forwardingSecretEnv)))
+	}
+	return append(patches, filePatch{File: file, Ops: ops})
+}
+
+// seedForPatch returns a configuration file holding the keys the patch sets.
+// The patch only changes existing files, the server fills in the rest of the
+// seeded file when it first starts.
+func seedForPatch(patch filePatch) (string, error) {
+	doc := map[string]any{}
+	for _, op := range patch.Ops {
+		keys := strings.Split(strings.TrimPrefix(op.Set.Path, "$."), ".")
+		node := doc
+		for _, key := range keys[:len(keys)-1] {
+			child, ok := node[key].(map[string]any)
+			if !ok {
+				child = map[string]any{}
+				node[key] = child
+			}
+			node = child
+		}
+		value := op.Set.Value
+		if _, ok := value.(string); ok {
+			// Secrets are only written by the patch
+			value = ""
+		}
+		node[keys[len(keys)-1]] = value
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// forwardingScriptForMinecraft seeds the configuration files the server has
+// not created yet, so the forwarding is in place when it first starts, and
+// writes the patch applied on every start
+func forwardingScriptForMinecraft(patches []filePatch) (string, error) {
+	definitions, err := json.Marshal(patchDefinitions{Patches: patches})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("set -eu\n")
+	for _, patch := range patches {
+		seed, err := seedForPatch(patch)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "mkdir -p %s\n", shellQuote(path.Dir(patch.File)))
+		fmt.Fprintf(&b, "[ -f %[1]s ] || printf '%%s' %[2]s > %[1]s\n", shellQuote(patch.File), shellQuote(seed))
+	}
+	fmt.Fprintf(&b, "printf '%%s' %s > %s\n", shellQuote(string(definitions)), shellQuote(forwardingPatchPath))
+	return b.String(), nil
+}
+
+// configureForwarding configures the servers of the StatefulSet to accept the
+// players forwarded by the proxy, or turns the forwarding off when proxy is nil.
+// The forwarding secret of Velocity is read from the Secret of the proxy.
+func configureForwarding(sts *appsv1.StatefulSet, minecraft *cachev1alpha1.Minecraft,
+	proxy *cachev1alpha1.MinecraftProxy) error {
+	image, err := imageForArtifacts()
+	if err != nil {
+		return err
+	}
+	patches := forwardingPatchesForMinecraft(minecraft, proxy)
+	script, err := forwardingScriptForMinecraft(patches)
+	if err != nil {
+		return err
+	}
+
+	podSpec := &sts.Spec.Template.Spec
+	podSpec.InitContainers = append(podSpec.InitContainers, corev1.Container{
+		Name:            forwardingContainerName,
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c", script},
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      worldVolumeName,
+			MountPath: worldMountPath,
+		}},
+	})
+
+	env := []corev1.EnvVar{{Name: "PATCH_DEFINITIONS", Value: forwardingPatchPath}}
+	if proxy != nil && proxy.Spec.Type != cachev1alpha1.ProxyTypeBungeeCord {
+		env = append(env, corev1.EnvVar{
+			Name: forwardingSecretEnv,
+			ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: forwardingSecretNameForProxy(proxy)},
+				Key:                  forwardingSecretKey,
+			}},
+		})
+	}
+	container := &podSpec.Containers[0]
+	container.Env = append(container.Env, env...)
+	return nil
+}
+
+// serviceBehindProxy returns the Service spec of an instance behind a proxy.
+// Players must join through the proxy, the servers trust the players it
+// forwards, so the Service is only reachable inside the cluster.
+func serviceBehindProxy(spec cachev1alpha1.ServiceSpec) cachev1alpha1.ServiceSpec {
+	spec.Type = corev1.ServiceTypeClusterIP
+	spec.NodePort = 0
+	spec.LoadBalancerIP = ""
+	spec.ExternalTrafficPolicy = ""
+	spec.ExtraPorts = append([]cachev1alpha1.ServicePort(nil), spec.ExtraPorts...)
+	for i := range spec.ExtraPorts {
+		spec.ExtraPorts[i].NodePort = 0
+	}
+	return spec
+}
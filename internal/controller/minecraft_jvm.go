@@ -0,0 +1,87 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// gcFlags are the JVM flags selecting the garbage collectors
+var gcFlags = map[cachev1alpha1.GarbageCollector]string{
+	cachev1alpha1.GarbageCollectorG1:         "-XX:+UseG1GC",
+	cachev1alpha1.GarbageCollectorZGC:        "-XX:+UseZGC",
+	cachev1alpha1.GarbageCollectorShenandoah: "-XX:+UseShenandoahGC",
+	cachev1alpha1.GarbageCollectorParallel:   "-XX:+UseParallelGC",
+}
+
+// jvmEnvForMinecraft renders the heap sizes and the flags of the JVM into the
+// environment variables understood by the operand image
+// More info: https://docker-minecraft-server.readthedocs.io/en/latest/configuration/jvm-options/
+func jvmEnvForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string]string {
+	env := map[string]string{}
+	jvm := minecraft.Spec.JVM
+	if jvm == nil {
+		jvm = &cachev1alpha1.JVMSpec{}
+	}
+
+	maxHeap := maxHeapForMinecraft(minecraft)
+	minHeap := maxHeap
+	if jvm.MinHeap != nil {
+		minHeap = jvm.MinHeap.Value()
+	}
+	if maxHeap > 0 {
+		env["MAX_MEMORY"] = heapSize(maxHeap)
+	}
+	if minHeap > 0 {
+		env["INIT_MEMORY"] = heapSize(minHeap)
+	}
+
+	if jvm.Flags == cachev1alpha1.JVMFlagsAikar {
+		env["USE_AIKAR_FLAGS"] = "TRUE"
+	}
+	setStringEnv(env, "JVM_XX_OPTS", gcFlags[jvm.GC])
+	setStringEnv(env, "JVM_OPTS", strings.Join(jvm.ExtraArgs, " "))
+	return env
+}
+
+// maxHeapForMinecraft returns the maximum heap size in bytes, derived from the
+// memory limit of the server container unless the spec sets it. It returns 0
+// when the default of the image applies.
+func maxHeapForMinecraft(minecraft *cachev1alpha1.Minecraft) int64 {
+	jvm := minecraft.Spec.JVM
+	if jvm != nil && jvm.MaxHeap != nil {
+		return jvm.MaxHeap.Value()
+	}
+	limit := minecraft.Spec.Resources.Limits.Memory()
+	if limit.IsZero() {
+		return 0
+	}
+	percentage := int64(cachev1alpha1.DefaultHeapPercentage)
+	if jvm != nil && jvm.HeapPercentage > 0 {
+		percentage = int64(jvm.HeapPercentage)
+	}
+	return limit.Value() * percentage / 100
+}
+
+// heapSize formats a heap size in bytes as whole mebibytes, the unit the JVM
+// flags understand
+func heapSize(bytes int64) string {
+	return fmt.Sprintf("%dM", bytes>>20)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1ac "k8s.io/client-go/applyconfigurations/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// restartAllowed returns whether the maintenance policy of the custom resource
+// allows restarting its servers now. Otherwise it also returns why not, and
+// how long until the next maintenance window opens or zero when none is
+// scheduled. Servers which are not running have nothing to disrupt.
+func (r *MinecraftReconciler) restartAllowed(ctx context.Context, minecraft *cachev1alpha1.Minecraft,
+	found *appsv1.StatefulSet, now time.Time) (bool, string, time.Duration) {
+	spec := minecraft.Spec.Maintenance
+	if spec == nil || spec.Policy == "" || spec.Policy == cachev1alpha1.MaintenancePolicyImmediate {
+		return true, "", 0
+	}
+	if found.Status.ReadyReplicas == 0 {
+		return true, "", 0
+	}
+
+	open, untilNext := r.maintenanceWindows(minecraft, now)
+	if open {
+		return true, "", 0
+	}
+	if spec.Policy == cachev1alpha1.MaintenancePolicyWhenEmpty {
+		online, known := r.playersOnline(ctx, minecraft)
+		if known && online == 0 {
+			return true, "", 0
+		}
+		reason := fmt.Sprintf("%d players are online", online)
+		if !known {
+			reason = "The number of players online is unknown"
+		}
+		if untilNext > 0 {
+			return false, fmt.Sprintf("%s and the next maintenance window opens in %s",
+				reason, untilNext.Round(time.Second)), untilNext
+		}
+		return false, reason, 0
+	}
+	if untilNext > 0 {
+		return false, fmt.Sprintf("The next maintenance window opens in %s", untilNext.Round(time.Second)), untilNext
+	}
+	return false, "No maintenance window is scheduled", 0
+}
+
+// playersOnline asks the running servers how many players are online. The
+// number is only known when every ready server answered, Bedrock servers are
+// never asked.
+func (r *MinecraftReconciler) playersOnline(ctx context.Context, minecraft *cachev1alpha1.Minecraft) (int32, bool) {
+	log := log.FromContext(ctx)
+
+	if isBedrock(minecraft) {
+		return 0, false
+	}
+	pods, err := serverPods(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list server pods for Minecraft")
+		return 0, false
+	}
+
+	var online int32
+	for i := range pods {
+		pod := &pods[i]
+		if !podReady(pod) {
+			continue
+		}
+		if pod.Status.PodIP == "" {
+			return 0, false
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		status, err := r.ping()(pingCtx, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(cachev1alpha1.JavaGamePort)))
+		cancel()
+		if err != nil {
+			log.Info("Server does not answer the status request", "Pod.Name", pod.Name, "error", err.Error())
+			return 0, false
+		}
+		online += status.Players.Online
+	}
+	return online, true
+}
+
+// appliedPodTemplate returns the pod template the operator applied last on
+// the StatefulSet, without the fields defaulted by the API server or set by
+// others. Applying it again keeps the servers as they are. A StatefulSet the
+// operator never applied a template on falls back to its whole template.
+func appliedPodTemplate(sts *appsv1.StatefulSet) (*corev1.PodTemplateSpec, error) {
+	applied, err := appsv1ac.ExtractStatefulSet(sts, string(fieldOwner))
+	if err != nil {
+		return nil, err
+	}
+	if applied.Spec == nil || applied.Spec.Template == nil {
+		return roundTripPodTemplate(&sts.Spec.Template)
+	}
+	data, err := json.Marshal(applied.Spec.Template)
+	if err != nil {
+		return nil, err
+	}
+	template := &corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(data, template); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+// roundTripPodTemplate returns a copy of the pod template read back from JSON
+func roundTripPodTemplate(template *corev1.PodTemplateSpec) (*corev1.PodTemplateSpec, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	out := &corev1.PodTemplateSpec{}
+	if err := json.Unmarshal(data, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// maintenanceWindows returns whether a maintenance window of the custom
+// resource is open at now, and otherwise how long until the next one opens or
+// zero when none is scheduled. Windows with an invalid schedule are skipped.
+func (r *MinecraftReconciler) maintenanceWindows(minecraft *cachev1alpha1.Minecraft,
+	now time.Time) (bool, time.Duration) {
+	var untilNext time.Duration
+	for _, w := range minecraft.Spec.Maintenance.Windows {
+		schedule, err := cron.ParseStandard(w.Schedule)
+		if err != nil {
+			r.Recorder.Event(minecraft, "Warning", "InvalidSchedule",
+				fmt.Sprintf("Maintenance window schedule %q is invalid: %s", w.Schedule, err))
+			continue
+		}
+		// The window opened last before now is still open if it did so within
+		// its duration, otherwise this is when it opens next
+		opens := schedule.Next(now.Add(-w.Duration.Duration))
+		if opens.IsZero() {
+			continue
+		}
+		if !opens.After(now) {
+			return true, 0
+		}
+		if until := opens.Sub(now); untilNext == 0 || until < untilNext {
+			untilNext = until
+		}
+	}
+	return false, untilNext
+}
+
+// setPendingRestartCondition reports the changes held back by the maintenance
+// policy of the custom resource, the condition is only kept on the ones
+// having a policy
+func setPendingRestartCondition(minecraft *cachev1alpha1.Minecraft, changes []string, reason string) {
+	if minecraft.Spec.Maintenance == nil {
+		meta.RemoveStatusCondition(&minecraft.Status.Conditions, typePendingRestartMinecraft)
+		return
+	}
+	if len(changes) == 0 {
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typePendingRestartMinecraft,
+			Status: metav1.ConditionFalse, Reason: "UpToDate",
+			Message: "The servers run the latest changes"})
+		return
+	}
+	meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typePendingRestartMinecraft,
+		Status: metav1.ConditionTrue, Reason: "WaitingForMaintenance",
+		Message: fmt.Sprintf("Changes to the %s are staged until the servers may restart: %s",
+			strings.Join(changes, ", "), reason)})
+}
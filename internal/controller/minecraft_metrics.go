@@ -0,0 +1,283 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// collectTimeout bounds the listing of the custom resources on a scrape
+const collectTimeout = 10 * time.Second
+
+// instanceLabels identify the Minecraft instance of a metric
+var instanceLabels = []string{"namespace", "name"}
+
+var (
+	// reconcileOutcomes counts the reconciliations of every Minecraft instance
+	reconcileOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "minecraft_reconcile_total",
+		Help: "Total number of reconciliations of the Minecraft instance by result (success, requeue or error)",
+	}, append(instanceLabels, "result"))
+	// ticksPerSecond is the tick rate of the slowest server of every instance
+	ticksPerSecond = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "minecraft_ticks_per_second",
+		Help: "Ticks per second (TPS) of the slowest server of the Minecraft instance, as reported through RCON",
+	}, instanceLabels)
+	// tickDuration is the mean tick duration of the slowest server of every instance
+	tickDuration = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "minecraft_tick_duration_seconds",
+		Help: "Mean duration of a tick (MSPT) of the slowest server of the Minecraft instance, " +
+			"as reported through RCON",
+	}, instanceLabels)
+	// pingLatency is the duration of the status request of the slowest server of every instance
+	pingLatency = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "minecraft_ping_latency_seconds",
+		Help: "Duration of the status request of the slowest server of the Minecraft instance",
+	}, instanceLabels)
+)
+
+var (
+	playersOnlineDesc = prometheus.NewDesc("minecraft_players_online",
+		"Number of players online on the servers of the Minecraft instance", instanceLabels, nil)
+	playersMaxDesc = prometheus.NewDesc("minecraft_players_max",
+		"Number of players the servers of the Minecraft instance accept", instanceLabels, nil)
+	backupAgeDesc = prometheus.NewDesc("minecraft_backup_age_seconds",
+		"Time since the last backup of the Minecraft instance completed", instanceLabels, nil)
+	backupWorldSizeDesc = prometheus.NewDesc("minecraft_backup_world_size_bytes",
+		"Size on disk of the worlds archived by the last completed backup of the Minecraft instance",
+		instanceLabels, nil)
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileOutcomes, ticksPerSecond, tickDuration, pingLatency)
+}
+
+// recordReconcileOutcome counts a reconciliation of the Minecraft instance
+func recordReconcileOutcome(name types.NamespacedName, result ctrl.Result, err error) {
+	outcome := "success"
+	switch {
+	case err != nil:
+		outcome = "error"
+	case result.Requeue:
+		outcome = "requeue"
+	}
+	reconcileOutcomes.WithLabelValues(name.Namespace, name.Name, outcome).Inc()
+}
+
+// forgetMinecraftMetrics removes the metrics of a deleted Minecraft instance
+func forgetMinecraftMetrics(name types.NamespacedName) {
+	labels := prometheus.Labels{"namespace": name.Namespace, "name": name.Name}
+	for _, vec := range []interface {
+		DeletePartialMatch(prometheus.Labels) int
+	}{reconcileOutcomes, ticksPerSecond, tickDuration, pingLatency} {
+		vec.DeletePartialMatch(labels)
+	}
+}
+
+// serverStats is what the servers of a Minecraft instance report when they
+// are observed. The slowest server is kept, a lagging server is not hidden by
+// the others.
+type serverStats struct {
+	pingLatency time.Duration
+	ticks       tickStats
+}
+
+// add records what a server reported
+func (s *serverStats) add(latency time.Duration, ticks tickStats) {
+	s.pingLatency = max(s.pingLatency, latency)
+	if ticks.hasTPS && (!s.ticks.hasTPS || ticks.tps < s.ticks.tps) {
+		s.ticks.tps, s.ticks.hasTPS = ticks.tps, true
+	}
+	if ticks.hasMSPT && (!s.ticks.hasMSPT || ticks.mspt > s.ticks.mspt) {
+		s.ticks.mspt, s.ticks.hasMSPT = ticks.mspt, true
+	}
+}
+
+// record sets the gauges of the Minecraft instance, the ones the servers did
+// not report are removed rather than left stale
+func (s *serverStats) record(minecraft *cachev1alpha1.Minecraft) {
+	labels := prometheus.Labels{"namespace": minecraft.Namespace, "name": minecraft.Name}
+	setGauge(pingLatency, labels, s.pingLatency.Seconds(), s.pingLatency > 0)
+	setGauge(ticksPerSecond, labels, s.ticks.tps, s.ticks.hasTPS)
+	setGauge(tickDuration, labels, s.ticks.mspt/1000, s.ticks.hasMSPT)
+}
+
+// setGauge sets the gauge to value when it is known and removes it otherwise
+func setGauge(vec *prometheus.GaugeVec, labels prometheus.Labels, value float64, known bool) {
+	if known {
+		vec.With(labels).Set(value)
+	} else {
+		vec.Delete(labels)
+	}
+}
+
+// tickCommands are the commands reporting the tick rate and duration, by the
+// server types which support them
+var tickCommands = map[cachev1alpha1.ServerType][]string{
+	cachev1alpha1.ServerTypePaper:    {"tps", "mspt"},
+	cachev1alpha1.ServerTypePurpur:   {"tps", "mspt"},
+	cachev1alpha1.ServerTypeSpigot:   {"tps"},
+	cachev1alpha1.ServerTypeForge:    {"forge tps"},
+	cachev1alpha1.ServerTypeNeoForge: {"neoforge tps"},
+}
+
+var (
+	// formattingCodes are the color and style codes of the command outputs
+	formattingCodes = regexp.MustCompile(`§[0-9a-fk-or]`)
+	// tpsOutput is the output of tps on Spigot and its forks, e.g.
+	// "TPS from last 1m, 5m, 15m: 20.0, 20.0, 20.0". Rates above 20 are starred.
+	tpsOutput = regexp.MustCompile(`TPS from last 1m, 5m, 15m: \*?([0-9.]+)`)
+	// msptOutput is the output of mspt on Paper and its forks, the average,
+	// minimum and maximum of the last 5 seconds come first, e.g.
+	// "Server tick times (avg/min/max) from last 5s, 10s, 1m:\n◴ 1.2/0.5/3.1, ..."
+	msptOutput = regexp.MustCompile(`◴ ([0-9.]+)/`)
+	// forgeOutput is the output of forge tps and neoforge tps, e.g.
+	// "Overall: 20.000 TPS (1.234 ms/tick)"
+	forgeOutput = regexp.MustCompile(`Overall\s*:\s*([0-9.]+) TPS \(([0-9.]+) ms/tick\)`)
+	// forgeLegacyOutput is the output of forge tps before Minecraft 1.19, e.g.
+	// "Overall : Mean tick time: 1.234 ms. Mean TPS: 20.000"
+	forgeLegacyOutput = regexp.MustCompile(`Overall\s*:\s*Mean tick time: ([0-9.]+) ms\. Mean TPS: ([0-9.]+)`)
+)
+
+// tickStats is the tick rate and the mean tick duration in milliseconds
+// reported by a server
+type tickStats struct {
+	tps     float64
+	hasTPS  bool
+	mspt    float64
+	hasMSPT bool
+}
+
+// parseTickStats reads the tick rate and duration from the outputs of the
+// tick commands
+func parseTickStats(outputs []string) tickStats {
+	var stats tickStats
+	for _, output := range outputs {
+		output = formattingCodes.ReplaceAllString(output, "")
+		if m := tpsOutput.FindStringSubmatch(output); m != nil {
+			stats.tps, stats.hasTPS = parseStat(m[1])
+		}
+		if m := msptOutput.FindStringSubmatch(output); m != nil {
+			stats.mspt, stats.hasMSPT = parseStat(m[1])
+		}
+		if m := forgeOutput.FindStringSubmatch(output); m != nil {
+			stats.tps, stats.hasTPS = parseStat(m[1])
+			stats.mspt, stats.hasMSPT = parseStat(m[2])
+		}
+		if m := forgeLegacyOutput.FindStringSubmatch(output); m != nil {
+			stats.mspt, stats.hasMSPT = parseStat(m[1])
+			stats.tps, stats.hasTPS = parseStat(m[2])
+		}
+	}
+	return stats
+}
+
+// parseStat parses a number of a command output
+func parseStat(s string) (float64, bool) {
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil
+}
+
+// MinecraftCollector reports the players, the backups and the worlds of the
+// Minecraft instances from their status and their MinecraftBackups. They are
+// read on every scrape, the metrics of deleted instances go away with them.
+type MinecraftCollector struct {
+	// Reader lists the custom resources, usually the cache of the manager
+	Reader client.Reader
+}
+
+var _ prometheus.Collector = &MinecraftCollector{}
+
+// Describe implements prometheus.Collector
+func (c *MinecraftCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- playersOnlineDesc
+	ch <- playersMaxDesc
+	ch <- backupAgeDesc
+	ch <- backupWorldSizeDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *MinecraftCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	minecrafts := &cachev1alpha1.MinecraftList{}
+	if err := c.Reader.List(ctx, minecrafts); err != nil {
+		ch <- prometheus.NewInvalidMetric(playersOnlineDesc, err)
+		return
+	}
+	backups := &cachev1alpha1.MinecraftBackupList{}
+	if err := c.Reader.List(ctx, backups); err != nil {
+		ch <- prometheus.NewInvalidMetric(backupWorldSizeDesc, err)
+		return
+	}
+	worldSizes := worldSizesForBackups(backups.Items)
+
+	for _, m := range minecrafts.Items {
+		ch <- prometheus.MustNewConstMetric(playersOnlineDesc, prometheus.GaugeValue,
+			float64(m.Status.OnlinePlayers), m.Namespace, m.Name)
+		ch <- prometheus.MustNewConstMetric(playersMaxDesc, prometheus.GaugeValue,
+			float64(m.Status.MaxPlayers), m.Namespace, m.Name)
+		if last := m.Status.LastBackupTime; last != nil {
+			ch <- prometheus.MustNewConstMetric(backupAgeDesc, prometheus.GaugeValue,
+				time.Since(last.Time).Seconds(), m.Namespace, m.Name)
+		}
+		if size, found := worldSizes[types.NamespacedName{Namespace: m.Namespace, Name: m.Name}]; found {
+			ch <- prometheus.MustNewConstMetric(backupWorldSizeDesc, prometheus.GaugeValue,
+				float64(size), m.Namespace, m.Name)
+		}
+	}
+}
+
+// worldSizesForBackups returns the total size of the worlds archived by the
+// latest completed backup of every Minecraft instance
+func worldSizesForBackups(backups []cachev1alpha1.MinecraftBackup) map[types.NamespacedName]int64 {
+	latest := map[types.NamespacedName]*cachev1alpha1.MinecraftBackup{}
+	for i := range backups {
+		b := &backups[i]
+		if b.Status.Phase != cachev1alpha1.BackupPhaseCompleted || b.Status.CompletionTime == nil {
+			continue
+		}
+		name := types.NamespacedName{Namespace: b.Namespace, Name: b.Spec.MinecraftRef.Name}
+		if l, found := latest[name]; !found || l.Status.CompletionTime.Before(b.Status.CompletionTime) {
+			latest[name] = b
+		}
+	}
+
+	sizes := make(map[types.NamespacedName]int64, len(latest))
+	for name, b := range latest {
+		var size int64
+		for _, a := range b.Status.Archives {
+			size += a.WorldSizeBytes
+		}
+		if size > 0 {
+			sizes[name] = size
+		}
+	}
+	return sizes
+}
@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"time"
+
+	//nolint:golint
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+var _ = Describe("Minecraft metrics", func() {
+	It("should parse the tick statistics of Paper", func() {
+		stats := parseTickStats([]string{
+			"§6TPS from last 1m, 5m, 15m: §a*20.0, §a19.98, §a19.5",
+			"§6Server tick times §e(§7avg§e/§7min§e/§7max§e)§6 from last 5s§7,§6 10s§7,§6 1m§e:\n" +
+				"§6◴ §a12.3§7/§a4.5§7/§a40.1§e, §a11.0§7/§a4.2§7/§a38.9§e, §a10.2§7/§a4.0§7/§a52.7",
+		})
+		Expect(stats).To(Equal(tickStats{tps: 20, hasTPS: true, mspt: 12.3, hasMSPT: true}))
+	})
+
+	It("should parse the tick statistics of Forge", func() {
+		stats := parseTickStats([]string{
+			"Dim minecraft:overworld (minecraft:overworld): Mean tick time: 2.345 ms. Mean TPS: 20.000\n" +
+				"Overall: 18.500 TPS (54.054 ms/tick)",
+		})
+		Expect(stats).To(Equal(tickStats{tps: 18.5, hasTPS: true, mspt: 54.054, hasMSPT: true}))
+
+		legacy := parseTickStats([]string{"Overall : Mean tick time: 3.5 ms. Mean TPS: 20.000"})
+		Expect(legacy).To(Equal(tickStats{tps: 20, hasTPS: true, mspt: 3.5, hasMSPT: true}))
+	})
+
+	It("should keep the slowest server", func() {
+		var stats serverStats
+		stats.add(20*time.Millisecond, tickStats{tps: 20, hasTPS: true})
+		stats.add(5*time.Millisecond, tickStats{tps: 17.5, hasTPS: true, mspt: 57, hasMSPT: true})
+		stats.add(10*time.Millisecond, tickStats{})
+		Expect(stats.pingLatency).To(Equal(20 * time.Millisecond))
+		Expect(stats.ticks).To(Equal(tickStats{tps: 17.5, hasTPS: true, mspt: 57, hasMSPT: true}))
+	})
+
+	It("should collect the players, backups and worlds of the instances", func() {
+		scheme := runtime.NewScheme()
+		Expect(cachev1alpha1.AddToScheme(scheme)).To(Succeed())
+
+		lastBackup := metav1.NewTime(time.Now().Add(-time.Hour))
+		earlier := metav1.NewTime(lastBackup.Add(-24 * time.Hour))
+		backup := func(name string, completed metav1.Time, phase cachev1alpha1.BackupPhase,
+			sizes ...int64) *cachev1alpha1.MinecraftBackup {
+			b := &cachev1alpha1.MinecraftBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "metrics"},
+				Spec: cachev1alpha1.MinecraftBackupSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: "survival"},
+				},
+				Status: cachev1alpha1.MinecraftBackupStatus{Phase: phase, CompletionTime: &completed},
+			}
+			for _, size := range sizes {
+				b.Status.Archives = append(b.Status.Archives, cachev1alpha1.BackupArchive{WorldSizeBytes: size})
+			}
+			return b
+		}
+
+		reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+			&cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{Name: "survival", Namespace: "metrics"},
+				Status: cachev1alpha1.MinecraftStatus{
+					OnlinePlayers:  3,
+					MaxPlayers:     40,
+					LastBackupTime: &lastBackup,
+				},
+			},
+			&cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{Name: "creative", Namespace: "metrics"},
+				Status:     cachev1alpha1.MinecraftStatus{MaxPlayers: 20},
+			},
+			backup("survival-old", earlier, cachev1alpha1.BackupPhaseCompleted, 1024),
+			backup("survival-new", lastBackup, cachev1alpha1.BackupPhaseCompleted, 2048, 4096),
+			backup("survival-failed", metav1.Now(), cachev1alpha1.BackupPhaseFailed, 1),
+		).Build()
+		collector := &MinecraftCollector{Reader: reader}
+
+		Expect(testutil.CollectAndCompare(collector, strings.NewReader(`
+# HELP minecraft_players_online Number of players online on the servers of the Minecraft instance
+# TYPE minecraft_players_online gauge
+minecraft_players_online{name="creative",namespace="metrics"} 0
+minecraft_players_online{name="survival",namespace="metrics"} 3
+# HELP minecraft_players_max Number of players the servers of the Minecraft instance accept
+# TYPE minecraft_players_max gauge
+minecraft_players_max{name="creative",namespace="metrics"} 20
+minecraft_players_max{name="survival",namespace="metrics"} 40
+# HELP minecraft_backup_world_size_bytes Size on disk of the worlds archived by the last completed backup of the Minecraft instance
+# TYPE minecraft_backup_world_size_bytes gauge
+minecraft_backup_world_size_bytes{name="survival",namespace="metrics"} 6144
+`), "minecraft_players_online", "minecraft_players_max", "minecraft_backup_world_size_bytes")).To(Succeed())
+
+		Expect(testutil.CollectAndCount(collector, "minecraft_backup_age_seconds")).To(Equal(1))
+	})
+})
@@ -0,0 +1,423 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/mojang"
+)
+
+const (
+	// playersVolumeName is the name of the volume holding the player lists
+	playersVolumeName = "players"
+	// playersMountPath is where the player lists are mounted, the image copies
+	// the files found there into the server directory on startup
+	playersMountPath = "/config"
+	// whitelistKey is the file of the players allowed to join
+	whitelistKey = "whitelist.json"
+	// opsKey is the file of the operators
+	opsKey = "ops.json"
+	// bannedPlayersKey is the file of the banned players
+	bannedPlayersKey = "banned-players.json"
+	// banTimeFormat is the format of the times of banned-players.json
+	banTimeFormat = "2006-01-02 15:04:05 -0700"
+)
+
+// ResolveFunc returns the UUIDs of the players with the usernames, keyed by
+// the lowercased username. Usernames no account has are left out.
+type ResolveFunc func(ctx context.Context, names []string) (map[string]string, error)
+
+// resolve returns the function resolving the usernames of the players
+func (r *MinecraftReconciler) resolve() ResolveFunc {
+	if r.Resolve != nil {
+		return r.Resolve
+	}
+	return mojang.Resolve
+}
+
+// whitelistEntry is an entry of whitelist.json
+type whitelistEntry struct {
+	UUID string `json:"uuid"`
+	Name string `json:"name"`
+}
+
+// opEntry is an entry of ops.json
+type opEntry struct {
+	UUID                string `json:"uuid"`
+	Name                string `json:"name"`
+	Level               int32  `json:"level"`
+	BypassesPlayerLimit bool   `json:"bypassesPlayerLimit"`
+}
+
+// banEntry is an entry of banned-players.json
+type banEntry struct {
+	UUID    string `json:"uuid"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Source  string `json:"source"`
+	Expires string `json:"expires"`
+	Reason  string `json:"reason"`
+}
+
+// playerLists are the lists of players written for the servers
+type playerLists struct {
+	whitelist []whitelistEntry
+	ops       []opEntry
+	bans      []banEntry
+}
+
+// playersConfigMapNameForMinecraft returns the name of the ConfigMap holding
+// the player lists of the custom resource
+func playersConfigMapNameForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	return fmt.Sprintf("%s-players", minecraft.Name)
+}
+
+// managesPlayers returns whether the operator writes the player lists of the
+// servers of the custom resource
+func managesPlayers(minecraft *cachev1alpha1.Minecraft) bool {
+	return minecraft.Spec.Players != nil && supportsRCON(minecraft)
+}
+
+// playersEnvForMinecraft returns the environment enforcing the whitelist. The
+// files copied on startup must replace the ones the servers wrote.
+func playersEnvForMinecraft(minecraft *cachev1alpha1.Minecraft) map[string]string {
+	if !managesPlayers(minecraft) {
+		return nil
+	}
+	whitelist := strings.ToUpper(strconv.FormatBool(len(minecraft.Spec.Players.Whitelist) > 0))
+	return map[string]string{
+		"ENABLE_WHITELIST":               whitelist,
+		"ENFORCE_WHITELIST":              whitelist,
+		"SYNC_SKIP_NEWER_IN_DESTINATION": "false",
+	}
+}
+
+// reconcilePlayers writes the player lists of the spec into their ConfigMap,
+// which the servers load when they start. The changes since the lists were
+// last written are applied to the running servers through RCON first, so they
+// are retried until the servers took them.
+func (r *MinecraftReconciler) reconcilePlayers(ctx context.Context, minecraft *cachev1alpha1.Minecraft,
+	proxied bool) error {
+	log := log.FromContext(ctx)
+
+	if !managesPlayers(minecraft) {
+		return nil
+	}
+
+	current := &corev1.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{Name: playersConfigMapNameForMinecraft(minecraft),
+		Namespace: minecraft.Namespace}, current)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	found := err == nil
+	var previous playerLists
+	if found {
+		previous = parsePlayerLists(current.Data)
+	}
+
+	uuids, err := r.uuidsForPlayers(ctx, minecraft, proxied, previous)
+	if err != nil {
+		return err
+	}
+	lists := playerListsForMinecraft(minecraft, uuids, previous)
+
+	// The servers which are running when the lists are first written restart
+	// to mount them, they are only told about the changes afterwards
+	if found {
+		if commands := playerCommands(previous, lists); len(commands) > 0 {
+			log.Info("Applying the player lists to the servers", "commands", commands)
+			if err := runOnServers(ctx, r.Client, r.RCON, minecraft, commands...); err != nil {
+				return err
+			}
+		}
+	}
+
+	cm, err := r.playersConfigMapForMinecraft(minecraft, lists)
+	if err != nil {
+		return err
+	}
+	return r.apply(ctx, cm)
+}
+
+// uuidsForPlayers returns the UUIDs of the players of the spec, keyed by the
+// lowercased username. Servers in offline mode derive them from the usernames,
+// behind a proxy the players keep the UUIDs of their accounts. The UUIDs of
+// the lists written before are reused, so only new players are resolved.
+func (r *MinecraftReconciler) uuidsForPlayers(ctx context.Context, minecraft *cachev1alpha1.Minecraft,
+	proxied bool, previous playerLists) (map[string]string, error) {
+	offline := !proxied && minecraft.Spec.Config.OnlineMode != nil && !*minecraft.Spec.Config.OnlineMode
+
+	known := map[string]string{}
+	for _, e := range previous.whitelist {
+		known[strings.ToLower(e.Name)] = e.UUID
+	}
+	for _, e := range previous.ops {
+		known[strings.ToLower(e.Name)] = e.UUID
+	}
+	for _, e := range previous.bans {
+		known[strings.ToLower(e.Name)] = e.UUID
+	}
+
+	uuids := map[string]string{}
+	var unknown []string
+	for _, name := range namesForPlayers(minecraft.Spec.Players) {
+		key := strings.ToLower(name)
+		if _, done := uuids[key]; done {
+			continue
+		}
+		if offline {
+			uuids[key] = mojang.OfflineUUID(name)
+		} else if uuid, ok := known[key]; ok && uuid != mojang.OfflineUUID(name) {
+			uuids[key] = uuid
+		} else {
+			unknown = append(unknown, name)
+			uuids[key] = ""
+		}
+	}
+	if len(unknown) == 0 {
+		return uuids, nil
+	}
+
+	resolved, err := r.resolve()(ctx, unknown)
+	if err != nil {
+		return nil, fmt.Errorf("resolving players: %w", err)
+	}
+	for _, name := range unknown {
+		key := strings.ToLower(name)
+		if uuid, ok := resolved[key]; ok {
+			uuids[key] = uuid
+			continue
+		}
+		delete(uuids, key)
+		r.Recorder.Event(minecraft, "Warning", "UnknownPlayer",
+			fmt.Sprintf("No account has the username %s, it is left out of the player lists", name))
+	}
+	return uuids, nil
+}
+
+// namesForPlayers returns the usernames of every list of the spec
+func namesForPlayers(players *cachev1alpha1.PlayersSpec) []string {
+	names := append([]string{}, players.Whitelist...)
+	for _, op := range players.Ops {
+		names = append(names, op.Name)
+	}
+	for _, ban := range players.Bans {
+		names = append(names, ban.Name)
+	}
+	return names
+}
+
+// playerListsForMinecraft returns the lists of the players of the spec whose
+// UUID is known. Bans keep the time they were first written.
+func playerListsForMinecraft(minecraft *cachev1alpha1.Minecraft, uuids map[string]string,
+	previous playerLists) playerLists {
+	players := minecraft.Spec.Players
+	lists := playerLists{
+		whitelist: []whitelistEntry{},
+		ops:       []opEntry{},
+		bans:      []banEntry{},
+	}
+
+	for _, name := range players.Whitelist {
+		if uuid, ok := uuids[strings.ToLower(name)]; ok {
+			lists.whitelist = append(lists.whitelist, whitelistEntry{UUID: uuid, Name: name})
+		}
+	}
+	for _, op := range players.Ops {
+		uuid, ok := uuids[strings.ToLower(op.Name)]
+		if !ok {
+			continue
+		}
+		level := op.Level
+		if level == 0 {
+			level = 4
+		}
+		lists.ops = append(lists.ops, opEntry{UUID: uuid, Name: op.Name, Level: level,
+			BypassesPlayerLimit: op.BypassesPlayerLimit})
+	}
+
+	created := map[string]string{}
+	for _, e := range previous.bans {
+		created[strings.ToLower(e.Name)] = e.Created
+	}
+	now := time.Now().UTC().Format(banTimeFormat)
+	for _, ban := range players.Bans {
+		uuid, ok := uuids[strings.ToLower(ban.Name)]
+		if !ok {
+			continue
+		}
+		at, ok := created[strings.ToLower(ban.Name)]
+		if !ok {
+			at = now
+		}
+		reason := ban.Reason
+		if reason == "" {
+			reason = "Banned by an operator."
+		}
+		lists.bans = append(lists.bans, banEntry{UUID: uuid, Name: ban.Name, Created: at,
+			Source: "minecraft-operator", Expires: "forever", Reason: reason})
+	}
+	return lists
+}
+
+// parsePlayerLists returns the lists written into the ConfigMap. Files which
+// can not be parsed are taken as empty, they are written again.
+func parsePlayerLists(data map[string]string) playerLists {
+	var lists playerLists
+	_ = json.Unmarshal([]byte(data[whitelistKey]), &lists.whitelist)
+	_ = json.Unmarshal([]byte(data[opsKey]), &lists.ops)
+	_ = json.Unmarshal([]byte(data[bannedPlayersKey]), &lists.bans)
+	return lists
+}
+
+// playerCommands returns the RCON commands turning the previous lists into
+// the desired ones. Players are removed from the lists before others are
+// added. A change of the level of an operator needs a restart.
+func playerCommands(previous, desired playerLists) []string {
+	var removals, additions []string
+
+	added, removed := diffNames(whitelistNames(previous.whitelist), whitelistNames(desired.whitelist))
+	for _, name := range removed {
+		removals = append(removals, "whitelist remove "+name)
+	}
+	for _, name := range added {
+		additions = append(additions, "whitelist add "+name)
+	}
+
+	added, removed = diffNames(opNames(previous.ops), opNames(desired.ops))
+	for _, name := range removed {
+		removals = append(removals, "deop "+name)
+	}
+	for _, name := range added {
+		additions = append(additions, "op "+name)
+	}
+
+	reasons := map[string]string{}
+	for _, e := range desired.bans {
+		reasons[e.Name] = e.Reason
+	}
+	added, removed = diffNames(banNames(previous.bans), banNames(desired.bans))
+	for _, name := range removed {
+		removals = append(removals, "pardon "+name)
+	}
+	for _, name := range added {
+		additions = append(additions, strings.TrimSpace("ban "+name+" "+reasons[name]))
+	}
+
+	return append(removals, additions...)
+}
+
+// diffNames returns the usernames only found in desired, and the ones only
+// found in previous. Usernames are compared regardless of their case.
+func diffNames(previous, desired []string) (added, removed []string) {
+	in := func(names []string, name string) bool {
+		return slices.ContainsFunc(names, func(n string) bool { return strings.EqualFold(n, name) })
+	}
+	for _, name := range desired {
+		if !in(previous, name) {
+			added = append(added, name)
+		}
+	}
+	for _, name := range previous {
+		if !in(desired, name) {
+			removed = append(removed, name)
+		}
+	}
+	return added, removed
+}
+
+// whitelistNames returns the usernames of whitelist.json
+func whitelistNames(entries []whitelistEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// opNames returns the usernames of ops.json
+func opNames(entries []opEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// banNames returns the usernames of banned-players.json
+func banNames(entries []banEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		names = append(names, e.Name)
+	}
+	return names
+}
+
+// playersConfigMapForMinecraft returns the ConfigMap holding the player lists
+// which are copied into the server directory on startup. It is left out of
+// the configuration hash, changing the lists does not restart the servers.
+func (r *MinecraftReconciler) playersConfigMapForMinecraft(minecraft *cachev1alpha1.Minecraft,
+	lists playerLists) (*corev1.ConfigMap, error) {
+	data := map[string]string{}
+	for key, list := range map[string]any{
+		whitelistKey:     lists.whitelist,
+		opsKey:           lists.ops,
+		bannedPlayersKey: lists.bans,
+	} {
+		out, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		data[key] = string(out)
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      playersConfigMapNameForMinecraft(minecraft),
+			Namespace: minecraft.Namespace,
+			Labels:    labelsForMinecraft(minecraft),
+		},
+		Data: data,
+	}
+
+	// Set the ownerRef for the ConfigMap
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(minecraft, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
@@ -0,0 +1,230 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/slp"
+)
+
+const (
+	// pingTimeout bounds the status request sent to a server
+	pingTimeout = 5 * time.Second
+	// startingRequeueInterval is how often a server which does not accept
+	// players yet is checked
+	startingRequeueInterval = 10 * time.Second
+	// statusRefreshInterval is how often the player counts of an available
+	// server are refreshed
+	statusRefreshInterval = time.Minute
+
+	// startupProbePeriod is the period of the startup probe, the startup
+	// timeout is divided by it into a failure threshold
+	startupProbePeriod = 10
+)
+
+// PingFunc queries the status of the Java Edition server listening at address
+type PingFunc func(ctx context.Context, address string) (*slp.Status, error)
+
+// ping returns the function querying the status of the servers
+func (r *MinecraftReconciler) ping() PingFunc {
+	if r.Ping != nil {
+		return r.Ping
+	}
+	return slp.Ping
+}
+
+// observeServers updates the status of the custom resource with what the ready
+// server pods report and returns whether any of them accepts players, together with
+// a message describing the observation. Java servers are asked with a Server
+// List Ping, Bedrock servers only speak UDP and the readiness of their pods,
+// which is checked by the image, is relied upon. The latency of the ping and
+// the tick statistics of the server types reporting them are exported as metrics.
+func (r *MinecraftReconciler) observeServers(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft, sts *appsv1.StatefulSet, pods []corev1.Pod) (bool, string) {
+	log := log.FromContext(ctx)
+
+	minecraft.Status.ServerVersion = ""
+	minecraft.Status.MOTD = ""
+	minecraft.Status.OnlinePlayers = 0
+	minecraft.Status.MaxPlayers = 0
+
+	var stats serverStats
+	defer stats.record(minecraft)
+
+	if sts.Status.ReadyReplicas == 0 {
+		return false, fmt.Sprintf("Waiting for the server of custom resource (%s) to accept players", minecraft.Name)
+	}
+	if isBedrock(minecraft) {
+		return true, fmt.Sprintf("%d of %d servers of custom resource (%s) accept players",
+			sts.Status.ReadyReplicas, minecraft.Spec.Size, minecraft.Name)
+	}
+
+	commands := tickCommands[minecraft.Spec.Type]
+	var password string
+	if len(commands) > 0 {
+		var err error
+		if password, err = rconPassword(ctx, r.Client, minecraft); err != nil {
+			log.Error(err, "Failed to get the RCON password of Minecraft")
+			commands = nil
+		}
+	}
+
+	var answered int32
+	var lastErr error
+	for i := range pods {
+		pod := &pods[i]
+		if !podReady(pod) || pod.Status.PodIP == "" {
+			continue
+		}
+
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		start := time.Now()
+		status, err := r.ping()(pingCtx, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(cachev1alpha1.JavaGamePort)))
+		latency := time.Since(start)
+		cancel()
+		if err != nil {
+			log.Info("Server does not answer the status request", "Pod.Name", pod.Name, "error", err.Error())
+			lastErr = err
+			continue
+		}
+
+		answered++
+		if minecraft.Status.ServerVersion == "" {
+			minecraft.Status.ServerVersion = status.Version.Name
+			minecraft.Status.MOTD = status.Description.Text
+		}
+		minecraft.Status.OnlinePlayers += status.Players.Online
+		minecraft.Status.MaxPlayers += status.Players.Max
+
+		var ticks tickStats
+		if len(commands) > 0 {
+			rconCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+			outputs, err := rconOrDefault(r.RCON)(rconCtx,
+				net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(cachev1alpha1.RCONPort)), password, commands...)
+			cancel()
+			if err != nil {
+				log.Info("Server does not report its tick statistics", "Pod.Name", pod.Name, "error", err.Error())
+			}
+			ticks = parseTickStats(outputs)
+		}
+		stats.add(latency, ticks)
+	}
+
+	if answered == 0 {
+		if lastErr != nil {
+			return false, fmt.Sprintf("Server of custom resource (%s) does not answer the status request: (%s)",
+				minecraft.Name, lastErr)
+		}
+		return false, fmt.Sprintf("Waiting for the server of custom resource (%s) to accept players", minecraft.Name)
+	}
+	return true, fmt.Sprintf("%d of %d servers of custom resource (%s) accept players, %d/%d players online",
+		answered, minecraft.Spec.Size, minecraft.Name, minecraft.Status.OnlinePlayers, minecraft.Status.MaxPlayers)
+}
+
+// podReady returns whether the Ready condition of the pod is true
+func podReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// startupProbeForMinecraft returns the probe holding back the readiness and
+// liveness checks while the server starts, e.g. while it generates the world
+func startupProbeForMinecraft(minecraft *cachev1alpha1.Minecraft) *corev1.Probe {
+	timeout := minecraft.Spec.Probes.StartupTimeoutSeconds
+	if timeout == 0 {
+		timeout = 600
+	}
+	return &corev1.Probe{
+		ProbeHandler:     probeHandlerForMinecraft(minecraft),
+		PeriodSeconds:    startupProbePeriod,
+		FailureThreshold: (timeout + startupProbePeriod - 1) / startupProbePeriod,
+	}
+}
+
+// readinessProbeForMinecraft returns the probe telling whether the server
+// accepts players
+func readinessProbeForMinecraft(minecraft *cachev1alpha1.Minecraft) *corev1.Probe {
+	return periodicProbeForMinecraft(minecraft)
+}
+
+// livenessProbeForMinecraft returns the probe restarting an unresponsive
+// server, nil when it is disabled
+func livenessProbeForMinecraft(minecraft *cachev1alpha1.Minecraft) *corev1.Probe {
+	if minecraft.Spec.Probes.DisableLiveness {
+		return nil
+	}
+	return periodicProbeForMinecraft(minecraft)
+}
+
+// periodicProbeForMinecraft returns the probe checking the started server
+func periodicProbeForMinecraft(minecraft *cachev1alpha1.Minecraft) *corev1.Probe {
+	probes := minecraft.Spec.Probes
+	probe := &corev1.Probe{
+		ProbeHandler:     probeHandlerForMinecraft(minecraft),
+		PeriodSeconds:    probes.PeriodSeconds,
+		FailureThreshold: probes.FailureThreshold,
+	}
+	if probe.PeriodSeconds == 0 {
+		probe.PeriodSeconds = 10
+	}
+	if probe.FailureThreshold == 0 {
+		probe.FailureThreshold = 3
+	}
+	return probe
+}
+
+// probeHandlerForMinecraft returns how the server is checked. The exec probes
+// use the health checks shipped with the operand images, mc-health sends a
+// Server List Ping like a game client and mc-monitor status-bedrock the
+// unconnected ping of Bedrock.
+func probeHandlerForMinecraft(minecraft *cachev1alpha1.Minecraft) corev1.ProbeHandler {
+	if isBedrock(minecraft) {
+		return corev1.ProbeHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"mc-monitor", "status-bedrock", "--host", "127.0.0.1",
+					"--port", strconv.Itoa(cachev1alpha1.BedrockGamePort)},
+			},
+		}
+	}
+	if minecraft.Spec.Probes.Type == cachev1alpha1.ProbeTypeTCP {
+		return corev1.ProbeHandler{
+			TCPSocket: &corev1.TCPSocketAction{
+				Port: intstr.FromString(gamePortName),
+			},
+		}
+	}
+	return corev1.ProbeHandler{
+		Exec: &corev1.ExecAction{
+			Command: []string{"mc-health"},
+		},
+	}
+}
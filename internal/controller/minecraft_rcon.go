@@ -0,0 +1,206 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/rcon"
+)
+
+const (
+	// rconPasswordKey is the key of the password in the RCON Secret
+	rconPasswordKey = "password"
+	// rconTimeout bounds the commands sent to a server
+	rconTimeout = 30 * time.Second
+)
+
+// RCONFunc runs commands one after the other on the server listening at
+// address and returns their outputs
+type RCONFunc func(ctx context.Context, address, password string, commands ...string) ([]string, error)
+
+// runRCON is the RCONFunc talking to the servers with the rcon package
+func runRCON(ctx context.Context, address, password string, commands ...string) ([]string, error) {
+	client, err := rcon.Dial(ctx, address, password)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close() //nolint:errcheck
+
+	outputs := make([]string, 0, len(commands))
+	for _, command := range commands {
+		output, err := client.Command(ctx, command)
+		if err != nil {
+			return outputs, err
+		}
+		outputs = append(outputs, output)
+	}
+	return outputs, nil
+}
+
+// rconOrDefault returns run, or the RCONFunc of the rcon package when it is nil
+func rconOrDefault(run RCONFunc) RCONFunc {
+	if run != nil {
+		return run
+	}
+	return runRCON
+}
+
+// rconSecretNameForMinecraft returns the name of the Secret holding the RCON
+// password of the custom resource
+func rconSecretNameForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	return fmt.Sprintf("%s-rcon", minecraft.Name)
+}
+
+// supportsRCON returns whether the servers of the custom resource offer RCON,
+// which the Bedrock edition does not
+func supportsRCON(minecraft *cachev1alpha1.Minecraft) bool {
+	return !isBedrock(minecraft)
+}
+
+// reconcileRCONSecret creates the Secret with a generated RCON password unless
+// it exists. The password is never rotated by the operator, deleting the Secret
+// generates a new one which the servers pick up when they restart.
+func (r *MinecraftReconciler) reconcileRCONSecret(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: rconSecretNameForMinecraft(minecraft),
+		Namespace: minecraft.Namespace}, secret)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	password, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rconSecretNameForMinecraft(minecraft),
+			Namespace: minecraft.Namespace,
+			Labels:    labelsForMinecraft(minecraft),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{rconPasswordKey: []byte(password)},
+	}
+	if err := ctrl.SetControllerReference(minecraft, secret, r.Scheme); err != nil {
+		return err
+	}
+
+	log.Info("Creating the RCON Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	return r.Create(ctx, secret)
+}
+
+// rconPassword returns the RCON password of the custom resource
+func rconPassword(ctx context.Context, c client.Reader, minecraft *cachev1alpha1.Minecraft) (string, error) {
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, types.NamespacedName{Name: rconSecretNameForMinecraft(minecraft),
+		Namespace: minecraft.Namespace}, secret); err != nil {
+		return "", err
+	}
+	password := string(secret.Data[rconPasswordKey])
+	if password == "" {
+		return "", fmt.Errorf("secret %s has no %s", secret.Name, rconPasswordKey)
+	}
+	return password, nil
+}
+
+// runOnServers runs commands on every ready server of the custom resource. It
+// stops at the first server failing and returns its error.
+func runOnServers(ctx context.Context, c client.Reader, run RCONFunc, minecraft *cachev1alpha1.Minecraft,
+	commands ...string) error {
+	if !supportsRCON(minecraft) {
+		return nil
+	}
+
+	pods, err := serverPods(ctx, c, minecraft)
+	if err != nil {
+		return err
+	}
+	var password string
+	for i := range pods {
+		pod := &pods[i]
+		if !podReady(pod) || pod.Status.PodIP == "" {
+			continue
+		}
+		if password == "" {
+			if password, err = rconPassword(ctx, c, minecraft); err != nil {
+				return err
+			}
+		}
+
+		rconCtx, cancel := context.WithTimeout(ctx, rconTimeout)
+		address := net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(cachev1alpha1.RCONPort))
+		_, err := rconOrDefault(run)(rconCtx, address, password, commands...)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("running %v on %s: %w", commands, pod.Name, err)
+		}
+	}
+	return nil
+}
+
+// saveWorlds flushes the worlds of the running servers to their volumes, so a
+// disruption loses as little progress as possible
+func (r *MinecraftReconciler) saveWorlds(ctx context.Context, minecraft *cachev1alpha1.Minecraft) {
+	log := log.FromContext(ctx)
+	if err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-all flush"); err != nil {
+		log.Error(err, "Failed to save the worlds of Minecraft")
+	}
+}
+
+// rconEnvForMinecraft returns the environment of the server container reading
+// the RCON password from the Secret
+func rconEnvForMinecraft(minecraft *cachev1alpha1.Minecraft) []corev1.EnvVar {
+	if !supportsRCON(minecraft) {
+		return nil
+	}
+	return []corev1.EnvVar{{
+		Name: "RCON_PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: rconSecretNameForMinecraft(minecraft)},
+				Key:                  rconPasswordKey,
+			},
+		},
+	}}
+}
+
+// generatePassword returns a random password
+func generatePassword() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
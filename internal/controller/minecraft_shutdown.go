@@ -0,0 +1,129 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// defaultShutdownGracePeriodSeconds is the termination grace period of the
+	// server pods when the spec does not set one
+	defaultShutdownGracePeriodSeconds = 60
+	// defaultShutdownCountdownSeconds is how long the players are warned
+	// before the server stops when the spec does not set it
+	defaultShutdownCountdownSeconds = 10
+	// defaultShutdownMessage is broadcast during the countdown when the spec
+	// does not set a message
+	defaultShutdownMessage = "Server shutting down"
+)
+
+// shutdownAnnouncements are the remaining seconds the countdown is broadcast
+// at, besides its start
+var shutdownAnnouncements = []int32{60, 30, 10, 5, 4, 3, 2, 1}
+
+// shutdownForMinecraft returns the shutdown settings of the spec with the
+// defaults filled in
+func shutdownForMinecraft(minecraft *cachev1alpha1.Minecraft) cachev1alpha1.ShutdownSpec {
+	shutdown := cachev1alpha1.ShutdownSpec{}
+	if minecraft.Spec.Shutdown != nil {
+		shutdown = *minecraft.Spec.Shutdown
+	}
+	if shutdown.GracePeriodSeconds == 0 {
+		shutdown.GracePeriodSeconds = defaultShutdownGracePeriodSeconds
+	}
+	if shutdown.CountdownSeconds == nil {
+		countdown := int32(defaultShutdownCountdownSeconds)
+		shutdown.CountdownSeconds = &countdown
+	}
+	if shutdown.Message == "" {
+		shutdown.Message = defaultShutdownMessage
+	}
+	return shutdown
+}
+
+// terminationGracePeriodForMinecraft returns how long the server pods are
+// given to stop before they are killed
+func terminationGracePeriodForMinecraft(minecraft *cachev1alpha1.Minecraft) *int64 {
+	gracePeriod := shutdownForMinecraft(minecraft).GracePeriodSeconds
+	return &gracePeriod
+}
+
+// shutdownScriptForMinecraft renders the preStop hook of the server container.
+// The countdown is broadcast to the players, skipped when none is online, then
+// the worlds are flushed to the volume and the server stopped. A broadcast or a
+// save failing, e.g. while the server is still starting, does not prevent the
+// server from stopping.
+func shutdownScriptForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	shutdown := shutdownForMinecraft(minecraft)
+
+	var b strings.Builder
+	remaining := *shutdown.CountdownSeconds
+	if remaining > 0 {
+		// Vanilla answers "There are 0 of a max of 20 players online", Paper
+		// "There are 0 out of maximum 20 players online"
+		b.WriteString("if ! rcon-cli list 2>/dev/null | grep -q 'There are 0 '; then\n")
+	}
+	for remaining > 0 {
+		message := fmt.Sprintf("%s in %d second", shutdown.Message, remaining)
+		if remaining > 1 {
+			message += "s"
+		}
+		fmt.Fprintf(&b, "  rcon-cli say %s || true\n", shellQuote(message))
+
+		next := int32(0)
+		for _, at := range shutdownAnnouncements {
+			if at < remaining {
+				next = at
+				break
+			}
+		}
+		fmt.Fprintf(&b, "  sleep %d\n", remaining-next)
+		remaining = next
+	}
+	if *shutdown.CountdownSeconds > 0 {
+		b.WriteString("fi\n")
+	}
+	b.WriteString("rcon-cli save-all flush || true\n")
+	b.WriteString("rcon-cli stop\n")
+	return b.String()
+}
+
+// shellQuote quotes s as a single word of a shell command
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// lifecycleForMinecraft returns the hooks of the server container. The server
+// is stopped through RCON before the pod is terminated, so it saves the world
+// and disconnects the players cleanly whatever terminates the pod. Bedrock
+// servers have no RCON and are left to stop on the termination signal.
+func lifecycleForMinecraft(minecraft *cachev1alpha1.Minecraft) *corev1.Lifecycle {
+	if !supportsRCON(minecraft) {
+		return nil
+	}
+	return &corev1.Lifecycle{
+		PreStop: &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{Command: []string{"/bin/sh", "-c", shutdownScriptForMinecraft(minecraft)}},
+		},
+	}
+}
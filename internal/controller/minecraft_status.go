@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// failedContainerReasons are the reasons a container waits for which do not go
+// away without intervention
+var failedContainerReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ErrImagePull":               true,
+	"ImagePullBackOff":           true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+}
+
+// serverPods returns the server pods of the custom resource
+func serverPods(ctx context.Context, c client.Reader,
+	minecraft *cachev1alpha1.Minecraft) ([]corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	if err := c.List(ctx, podList, client.InNamespace(minecraft.Namespace),
+		client.MatchingLabels(selectorLabelsForMinecraft(minecraft.Name))); err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// phaseForMinecraft summarizes the state of the StatefulSet and its pods
+func phaseForMinecraft(sts *appsv1.StatefulSet, pods []corev1.Pod, available bool) cachev1alpha1.MinecraftPhase {
+	switch {
+	case available:
+		return cachev1alpha1.MinecraftPhaseRunning
+	case anyPodFailed(pods):
+		return cachev1alpha1.MinecraftPhaseFailed
+	case sts.Spec.Replicas != nil && *sts.Spec.Replicas == 0:
+		if len(pods) > 0 {
+			return cachev1alpha1.MinecraftPhaseStopping
+		}
+		return cachev1alpha1.MinecraftPhaseStopped
+	case anyPodRunning(pods):
+		return cachev1alpha1.MinecraftPhaseStarting
+	default:
+		return cachev1alpha1.MinecraftPhasePending
+	}
+}
+
+// anyPodFailed returns whether a container of the pods can not run without
+// intervention
+func anyPodFailed(pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Waiting != nil && failedContainerReasons[status.State.Waiting.Reason] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// anyPodRunning returns whether a pod runs
+func anyPodRunning(pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		if pod.Status.Phase == corev1.PodRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// addressForMinecraft returns the host and port players connect to, or an
+// empty string while it is not known, e.g. until the load balancer is provisioned
+func addressForMinecraft(minecraft *cachev1alpha1.Minecraft, svc *corev1.Service, pods []corev1.Pod) string {
+	port := servicePortForMinecraft(minecraft)
+
+	switch svc.Spec.Type {
+	case corev1.ServiceTypeLoadBalancer:
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			host := ingress.IP
+			if host == "" {
+				host = ingress.Hostname
+			}
+			if host != "" {
+				return net.JoinHostPort(host, strconv.Itoa(int(port)))
+			}
+		}
+		return ""
+	case corev1.ServiceTypeNodePort:
+		// The node port is opened on every node, the node of a server is as good
+		// as any other one
+		var nodePort int32
+		for _, p := range svc.Spec.Ports {
+			if p.Name == gamePortName {
+				nodePort = p.NodePort
+			}
+		}
+		for _, pod := range pods {
+			if nodePort != 0 && pod.Status.HostIP != "" {
+				return net.JoinHostPort(pod.Status.HostIP, strconv.Itoa(int(nodePort)))
+			}
+		}
+		return ""
+	default:
+		return net.JoinHostPort(fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace), strconv.Itoa(int(port)))
+	}
+}
+
+// recordBackupTime sets the last backup time of the custom resource to
+// completed unless a later backup was recorded already
+func recordBackupTime(minecraft *cachev1alpha1.Minecraft, completed *metav1.Time) {
+	if completed == nil {
+		return
+	}
+	last := minecraft.Status.LastBackupTime
+	if last == nil || last.Before(completed) {
+		minecraft.Status.LastBackupTime = completed.DeepCopy()
+	}
+}
@@ -0,0 +1,402 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// upgradeJobLabel marks the Jobs backing up a world before an upgrade
+	upgradeJobLabel = "cache.example.com/upgrade"
+	// backupDir is where archives of the world are kept on the world volume
+	backupDir = worldMountPath + "/backups"
+	// backupJobTTL is how long finished backup Jobs are kept around
+	backupJobTTL = int32(24 * 60 * 60)
+)
+
+// desiredVersionForMinecraft returns the Minecraft version requested by the custom resource
+func desiredVersionForMinecraft(minecraft *cachev1alpha1.Minecraft) string {
+	if minecraft.Spec.Version != "" {
+		return minecraft.Spec.Version
+	}
+	return "LATEST"
+}
+
+// reconcileVersion decides which Minecraft version the instances run. A change of
+// spec.version is held back until the world of every instance was backed up, and
+// downgrades are refused unless spec.allowDowngrade is set. It returns the version
+// to roll out and whether the reconciliation has to wait for a backup to finish.
+func (r *MinecraftReconciler) reconcileVersion(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft) (string, bool, error) {
+	desired := desiredVersionForMinecraft(minecraft)
+	current := minecraft.Status.CurrentVersion
+	if current == "" || current == desired {
+		return desired, false, nil
+	}
+
+	if isDowngrade(current, desired) && !minecraft.Spec.AllowDowngrade {
+		if c := meta.FindStatusCondition(minecraft.Status.Conditions, typeProgressingMinecraft); c == nil ||
+			c.Reason != "DowngradeRefused" {
+			r.Recorder.Event(minecraft, "Warning", "DowngradeRefused",
+				fmt.Sprintf("Refusing to downgrade from %s to %s", current, desired))
+		}
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+			Status: metav1.ConditionFalse, Reason: "DowngradeRefused",
+			Message: fmt.Sprintf("Refusing to downgrade from %s to %s, set spec.allowDowngrade to proceed", current, desired)})
+		return current, false, nil
+	}
+
+	done, err := r.backupBeforeUpgrade(ctx, minecraft, current, desired)
+	if err != nil || !done {
+		return current, !done, err
+	}
+
+	meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+		Status: metav1.ConditionTrue, Reason: "Upgrading",
+		Message: fmt.Sprintf("World backed up, rolling out version %s", desired)})
+	return desired, false, nil
+}
+
+// backupBeforeUpgrade runs a Job archiving the world of every instance into the
+// backups directory of its volume. The Jobs are created suspended and resumed
+// once the saving of the servers is turned off, which is turned back on when
+// they finished. It returns true once all of them succeeded.
+func (r *MinecraftReconciler) backupBeforeUpgrade(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft, from, to string) (bool, error) {
+	log := log.FromContext(ctx)
+
+	// The Jobs can not mount a volume the server pod holds exclusively
+	singlePod, err := singlePodClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		return false, err
+	}
+	if len(singlePod) > 0 {
+		if c := meta.FindStatusCondition(minecraft.Status.Conditions, typeProgressingMinecraft); c == nil ||
+			c.Reason != "BackupImpossible" {
+			r.Recorder.Event(minecraft, "Warning", "BackupImpossible",
+				fmt.Sprintf("Can not back up the world before upgrading to %s", to))
+		}
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+			Status: metav1.ConditionFalse, Reason: "BackupImpossible",
+			Message: fmt.Sprintf("The world volumes %s are ReadWriteOncePod, the backup before upgrading to %s "+
+				"can not mount them while the servers run", strings.Join(singlePod, ", "), to)})
+		return false, nil
+	}
+
+	claims, err := worldVolumeClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		return false, err
+	}
+
+	var jobs, suspended []*batchv1.Job
+	for _, claim := range claims {
+		desired, err := r.upgradeBackupJobForMinecraft(ctx, minecraft, claim, from, to)
+		if err != nil {
+			return false, err
+		}
+
+		job := &batchv1.Job{}
+		err = r.Get(ctx, types.NamespacedName{Name: desired.Name, Namespace: desired.Namespace}, job)
+		if err != nil && apierrors.IsNotFound(err) {
+			log.Info("Backing up the world before upgrading",
+				"Job.Namespace", desired.Namespace, "Job.Name", desired.Name, "From", from, "To", to)
+			if err := r.Create(ctx, desired); err != nil {
+				return false, err
+			}
+			job = desired
+		} else if err != nil {
+			return false, err
+		}
+		jobs = append(jobs, job)
+		if job.Spec.Suspend != nil && *job.Spec.Suspend {
+			suspended = append(suspended, job)
+		}
+	}
+
+	// The world is flushed to the volume first, after which the server keeps it
+	// in memory until the saving is turned back on
+	if len(suspended) > 0 {
+		if err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-off", "save-all flush"); err != nil {
+			return false, err
+		}
+		resume := false
+		for _, job := range suspended {
+			patch := client.MergeFrom(job.DeepCopy())
+			job.Spec.Suspend = &resume
+			if err := r.Patch(ctx, job, patch); err != nil {
+				return false, err
+			}
+		}
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+			Status: metav1.ConditionTrue, Reason: "BackingUp",
+			Message: fmt.Sprintf("Backing up the world before upgrading from %s to %s", from, to)})
+		return false, nil
+	}
+
+	var failed []string
+	for _, job := range jobs {
+		switch {
+		case jobHasCondition(job, batchv1.JobComplete):
+		case jobHasCondition(job, batchv1.JobFailed):
+			failed = append(failed, job.Name)
+		default:
+			return false, nil
+		}
+	}
+
+	// Every Job finished, the servers save their worlds again
+	if c := meta.FindStatusCondition(minecraft.Status.Conditions, typeProgressingMinecraft); c != nil &&
+		c.Reason == "BackingUp" {
+		if err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-on"); err != nil {
+			return false, err
+		}
+	}
+
+	if len(failed) > 0 {
+		for _, name := range failed {
+			r.Recorder.Event(minecraft, "Warning", "BackupFailed",
+				fmt.Sprintf("Backup Job %s failed, delete it to retry the upgrade to %s", name, to))
+		}
+		meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeProgressingMinecraft,
+			Status: metav1.ConditionFalse, Reason: "BackupFailed",
+			Message: fmt.Sprintf("Backup Jobs %s failed, the upgrade to %s is on hold",
+				strings.Join(failed, ", "), to)})
+		return false, nil
+	}
+	for _, job := range jobs {
+		recordBackupTime(minecraft, job.Status.CompletionTime)
+	}
+	return true, nil
+}
+
+// worldVolumeClaims returns the names of the world volumes created for the
+// instances of the custom resource
+func worldVolumeClaims(ctx context.Context, c client.Reader,
+	minecraft *cachev1alpha1.Minecraft) ([]string, error) {
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, claims, client.InNamespace(minecraft.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, claim := range claims.Items {
+		if isStatefulSetPodName(worldVolumeName+"-"+minecraft.Name, claim.Name) {
+			names = append(names, claim.Name)
+		}
+	}
+	return names, nil
+}
+
+// singlePodClaims returns the names of the world volumes with the
+// ReadWriteOncePod access mode. Only the server pod may mount them, Jobs
+// archiving them can not run while it holds them.
+func singlePodClaims(ctx context.Context, c client.Reader,
+	minecraft *cachev1alpha1.Minecraft) ([]string, error) {
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := c.List(ctx, claims, client.InNamespace(minecraft.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, claim := range claims.Items {
+		if isStatefulSetPodName(worldVolumeName+"-"+minecraft.Name, claim.Name) &&
+			slices.Contains(claim.Spec.AccessModes, corev1.ReadWriteOncePod) {
+			names = append(names, claim.Name)
+		}
+	}
+	return names, nil
+}
+
+// upgradeBackupJobForMinecraft returns the Job archiving the world on the given
+// claim before upgrading from one version to another. The Job runs the operand
+// image on the node of the server pod, as the volume can only be attached to a
+// single node. The archive is kept on the world volume, the Job fails without
+// touching the world when the volume has no room for it.
+func (r *MinecraftReconciler) upgradeBackupJobForMinecraft(ctx context.Context, minecraft *cachev1alpha1.Minecraft,
+	claim, from, to string) (*batchv1.Job, error) {
+	image, err := imageForMinecraft(minecraft)
+	if err != nil {
+		return nil, err
+	}
+
+	podName := strings.TrimPrefix(claim, worldVolumeName+"-")
+	sum := sha256.Sum256([]byte(from + "\n" + to))
+	name := fmt.Sprintf("%s-upgrade-%s", podName, hex.EncodeToString(sum[:])[:8])
+
+	affinity, err := affinityForServerNode(ctx, r.Client, minecraft.Namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	ls := jobLabelsForMinecraft(minecraft, "backup")
+	ls[upgradeJobLabel] = "true"
+
+	suspend := true
+	backoffLimit := int32(2)
+	deadline := int64(backupDeadline.Seconds())
+	ttl := backupJobTTL
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: minecraft.Namespace,
+			Labels:    ls,
+		},
+		Spec: batchv1.JobSpec{
+			Suspend:                 &suspend,
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Affinity:      affinity,
+					Containers: []corev1.Container{{
+						Name:            "backup",
+						Image:           image,
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Command:         []string{"/bin/sh", "-c"},
+						Args: []string{`set -e
+WORLD_KB=$(du -sk "` + worldMountPath + `" | cut -f 1)
+if [ -d "` + backupDir + `" ]; then
+  WORLD_KB=$((WORLD_KB - $(du -sk "` + backupDir + `" | cut -f 1)))
+fi
+FREE_KB=$(df -Pk "` + worldMountPath + `" | awk 'NR == 2 { print $4 }')
+if [ "$WORLD_KB" -gt "$FREE_KB" ]; then
+  echo "The world volume has ${FREE_KB}KiB free, the archive may need ${WORLD_KB}KiB" | tee /dev/termination-log >&2
+  exit 1
+fi
+mkdir -p "` + backupDir + `"
+tar czf "` + backupDir + `/upgrade-${FROM_VERSION}-to-${TO_VERSION}.tar.gz" --exclude=./backups -C "` + worldMountPath + `" .`},
+						Env: []corev1.EnvVar{
+							{Name: "FROM_VERSION", Value: from},
+							{Name: "TO_VERSION", Value: to},
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      worldVolumeName,
+							MountPath: worldMountPath,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: worldVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	// Set the ownerRef for the Job
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(minecraft, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// jobLabelsForMinecraft returns the labels of a Job working on the world of the
+// custom resource. They never match the selector of the server pods, so the
+// Service does not route players to the Job.
+func jobLabelsForMinecraft(minecraft *cachev1alpha1.Minecraft, component string) map[string]string {
+	ls := labelsForMinecraft(minecraft)
+	ls["app.kubernetes.io/name"] = "minecraft-" + component
+	ls["app.kubernetes.io/component"] = component
+	return ls
+}
+
+// affinityForServerNode returns an affinity requiring the node the given
+// server pod runs on, so a pod sharing its ReadWriteOnce volume can mount it.
+// A server pod which is not scheduled holds no volume, any node will do then.
+func affinityForServerNode(ctx context.Context, c client.Reader, namespace, podName string) (*corev1.Affinity, error) {
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, types.NamespacedName{Name: podName, Namespace: namespace}, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if pod.Spec.NodeName == "" {
+		return nil, nil
+	}
+	return &corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+					MatchFields: []corev1.NodeSelectorRequirement{{
+						Key:      "metadata.name",
+						Operator: corev1.NodeSelectorOpIn,
+						Values:   []string{pod.Spec.NodeName},
+					}},
+				}},
+			},
+		},
+	}, nil
+}
+
+// jobHasCondition returns whether the Job has the given condition set to true
+func jobHasCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, c := range job.Status.Conditions {
+		if c.Type == conditionType && c.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// statefulSetRolledOut returns whether every replica of the StatefulSet runs the
+// latest revision of its pod template and is ready
+func statefulSetRolledOut(sts *appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	return sts.Status.ObservedGeneration >= sts.Generation &&
+		sts.Status.UpdateRevision == sts.Status.CurrentRevision &&
+		sts.Status.UpdatedReplicas == replicas &&
+		sts.Status.ReadyReplicas == replicas
+}
+
+// isDowngrade returns whether moving from version from to version to is a
+// downgrade. Versions which can not be compared, like LATEST or snapshots,
+// are never considered a downgrade.
+func isDowngrade(from, to string) bool {
+	cmp, ok := cachev1alpha1.CompareVersions(from, to)
+	return ok && cmp > 0
+}
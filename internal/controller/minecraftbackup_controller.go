@@ -0,0 +1,649 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// backupFinalizer makes sure the saving of the servers is turned back on
+	// when a running backup is deleted
+	backupFinalizer = "cache.example.com/backup-finalizer"
+	// backupJobLabel is set on the Jobs of a backup with the name of the backup
+	backupJobLabel = "cache.example.com/backup"
+	// backupTargetVolumeName is the name of the volume the archives are written to
+	backupTargetVolumeName = "target"
+	// backupTargetMountPath is where the target volume is mounted in the Job
+	backupTargetMountPath = "/target"
+	// backupResultFile is the file of the target volume the archive is described
+	// in until the container uploading it reports it
+	backupResultFile = "result.json"
+	// backupDeadline is how long the Jobs of a backup may run before they are
+	// stopped and fail
+	backupDeadline = time.Hour
+	// backupTimeout is how long the saving of the servers stays turned off at
+	// most. It leaves the Jobs time to report they exceeded their deadline.
+	backupTimeout = backupDeadline + 5*time.Minute
+)
+
+// MinecraftBackupReconciler reconciles a MinecraftBackup object
+type MinecraftBackupReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	// RCON runs commands on the servers, through the rcon package when not set
+	RCON RCONFunc
+}
+
+// backupResult is the termination message written by the backup Job
+type backupResult struct {
+	Location       string `json:"location"`
+	SizeBytes      int64  `json:"sizeBytes"`
+	WorldSizeBytes int64  `json:"worldSizeBytes"`
+	Checksum       string `json:"checksum"`
+}
+
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftbackups,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftbackups/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftbackups/finalizers,verbs=update
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+
+// Reconcile archives the worlds of a Minecraft instance. The saving of the
+// servers is turned off while a Job per world volume archives it onto the
+// target, so the archives are consistent, and turned back on once the Jobs
+// are done or the backup timed out.
+func (r *MinecraftBackupReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	backup := &cachev1alpha1.MinecraftBackup{}
+	if err := r.Get(ctx, req.NamespacedName, backup); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("minecraftbackup resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get minecraftbackup")
+		return ctrl.Result{}, err
+	}
+
+	minecraft := &cachev1alpha1.Minecraft{}
+	err := r.Get(ctx, types.NamespacedName{Name: backup.Spec.MinecraftRef.Name, Namespace: backup.Namespace}, minecraft)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get minecraft")
+		return ctrl.Result{}, err
+	}
+	minecraftFound := err == nil
+
+	if backup.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+			if minecraftFound && backup.Status.Phase == cachev1alpha1.BackupPhaseRunning {
+				if err := r.turnSavingOn(ctx, backup, minecraft); err != nil {
+					log.Error(err, "Failed to turn the saving of Minecraft back on")
+					return ctrl.Result{}, err
+				}
+			}
+			controllerutil.RemoveFinalizer(backup, backupFinalizer)
+			if err := r.Update(ctx, backup); err != nil {
+				log.Error(err, "Failed to remove finalizer for MinecraftBackup")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	switch backup.Status.Phase {
+	case cachev1alpha1.BackupPhaseCompleted, cachev1alpha1.BackupPhaseFailed:
+		return ctrl.Result{}, nil
+	case "":
+		if !controllerutil.ContainsFinalizer(backup, backupFinalizer) {
+			controllerutil.AddFinalizer(backup, backupFinalizer)
+			if err := r.Update(ctx, backup); err != nil {
+				log.Error(err, "Failed to add finalizer to MinecraftBackup")
+				return ctrl.Result{}, err
+			}
+		}
+		backup.Status.Phase = cachev1alpha1.BackupPhasePending
+		backup.Status.Message = "Waiting for the backup to start"
+		if err := r.Status().Update(ctx, backup); err != nil {
+			log.Error(err, "Failed to update MinecraftBackup status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if !minecraftFound {
+		return r.fail(ctx, backup, nil, fmt.Sprintf("Minecraft %s not found", backup.Spec.MinecraftRef.Name))
+	}
+	if backupTimedOut(backup, time.Now()) {
+		return r.fail(ctx, backup, minecraft, fmt.Sprintf("The backup did not complete within %s", backupTimeout))
+	}
+
+	claims, err := worldVolumeClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list world volumes of Minecraft")
+		return ctrl.Result{}, err
+	}
+	if len(claims) == 0 {
+		return r.fail(ctx, backup, minecraft, fmt.Sprintf("Minecraft %s has no world volume", minecraft.Name))
+	}
+
+	// The Jobs can not mount a volume the server pod holds exclusively
+	singlePod, err := singlePodClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list world volumes of Minecraft")
+		return ctrl.Result{}, err
+	}
+	if len(singlePod) > 0 {
+		return r.fail(ctx, backup, minecraft, fmt.Sprintf(
+			"The world volumes %s are ReadWriteOncePod, backup Jobs can not mount them while the servers run",
+			strings.Join(singlePod, ", ")))
+	}
+
+	if backup.Status.Phase == cachev1alpha1.BackupPhasePending {
+		return r.start(ctx, backup, minecraft, claims)
+	}
+	return r.collect(ctx, backup, minecraft, claims)
+}
+
+// start creates the Jobs archiving the worlds. They are created suspended, so
+// they exist before the saving of the servers is turned off, and resumed once
+// it is.
+func (r *MinecraftBackupReconciler) start(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft, claims []string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	jobs := make([]*batchv1.Job, 0, len(claims))
+	for _, claim := range claims {
+		job, err := r.backupJobForMinecraft(ctx, backup, minecraft, claim)
+		if err != nil {
+			return r.fail(ctx, backup, minecraft, fmt.Sprintf("Failed to define the backup Job: (%s)", err))
+		}
+		jobs = append(jobs, job)
+	}
+
+	for _, job := range jobs {
+		if err := r.Create(ctx, job); err != nil && !apierrors.IsAlreadyExists(err) {
+			log.Error(err, "Failed to create backup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			return ctrl.Result{}, err
+		}
+	}
+
+	// From now on the backup times out, and turns the saving back on when it fails
+	now := metav1.Now()
+	backup.Status.Phase = cachev1alpha1.BackupPhaseRunning
+	backup.Status.StartTime = &now
+	backup.Status.Message = fmt.Sprintf("Archiving %d world volumes", len(claims))
+	if err := r.Status().Update(ctx, backup); err != nil {
+		log.Error(err, "Failed to update MinecraftBackup status")
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(backup, "Normal", "Started",
+		fmt.Sprintf("Archiving the worlds of Minecraft %s", minecraft.Name))
+	return r.resume(ctx, backup, minecraft, jobs)
+}
+
+// resume turns the saving of the servers off and resumes the suspended Jobs
+// archiving the worlds
+func (r *MinecraftBackupReconciler) resume(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft, jobs []*batchv1.Job) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// The world is flushed to the volume first, after which the server keeps it
+	// in memory until the saving is turned back on
+	if err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-off", "save-all flush"); err != nil {
+		r.Recorder.Event(backup, "Warning", "QuiesceFailed", err.Error())
+		log.Error(err, "Failed to turn the saving of Minecraft off")
+		return ctrl.Result{}, err
+	}
+
+	suspend := false
+	for _, job := range jobs {
+		if job.Spec.Suspend == nil || !*job.Spec.Suspend {
+			continue
+		}
+		patch := client.MergeFrom(job.DeepCopy())
+		job.Spec.Suspend = &suspend
+		if err := r.Patch(ctx, job, patch); err != nil {
+			log.Error(err, "Failed to resume backup Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			return ctrl.Result{}, err
+		}
+	}
+	return ctrl.Result{RequeueAfter: timeUntilBackupTimeout(backup, time.Now())}, nil
+}
+
+// backupTimedOut returns whether the backup is running for longer than the
+// saving of the servers may stay turned off
+func backupTimedOut(backup *cachev1alpha1.MinecraftBackup, now time.Time) bool {
+	return backup.Status.Phase == cachev1alpha1.BackupPhaseRunning && timeUntilBackupTimeout(backup, now) <= 0
+}
+
+// timeUntilBackupTimeout returns how long the running backup may still take
+func timeUntilBackupTimeout(backup *cachev1alpha1.MinecraftBackup, now time.Time) time.Duration {
+	if backup.Status.StartTime == nil {
+		return backupTimeout
+	}
+	return backup.Status.StartTime.Add(backupTimeout).Sub(now)
+}
+
+// turnSavingOn turns the saving of the servers back on. Servers which can not
+// be reached are retried until the backup timed out, a server restarted in
+// the meantime saves its world anyway.
+func (r *MinecraftBackupReconciler) turnSavingOn(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft) error {
+	err := runOnServers(ctx, r.Client, r.RCON, minecraft, "save-on")
+	if err != nil && backupTimedOut(backup, time.Now()) {
+		r.Recorder.Event(backup, "Warning", "ResumeSavingFailed",
+			fmt.Sprintf("Failed to turn the saving of Minecraft %s back on: %s", minecraft.Name, err))
+		return nil
+	}
+	return err
+}
+
+// collect waits for the Jobs archiving the worlds, turns the saving of the
+// servers back on and records the archives
+func (r *MinecraftBackupReconciler) collect(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft, claims []string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	var archives []cachev1alpha1.BackupArchive
+	var failed, suspended []*batchv1.Job
+	var lost []string
+	for _, claim := range claims {
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: backupJobName(backup, claim), Namespace: backup.Namespace}, job)
+		if apierrors.IsNotFound(err) {
+			// A world volume created after the backup started is not part of it
+			continue
+		} else if err != nil {
+			log.Error(err, "Failed to get backup Job")
+			return ctrl.Result{}, err
+		}
+
+		switch {
+		case jobHasCondition(job, batchv1.JobComplete):
+			result, err := r.jobResult(ctx, job)
+			if err != nil {
+				log.Error(err, "Failed to read the result of backup Job", "Job.Name", job.Name)
+				return ctrl.Result{}, err
+			}
+			if result == nil {
+				// The pods of the Job were garbage collected. The archive exists,
+				// only its size and checksum are lost.
+				lost = append(lost, job.Name)
+				result = &backupResult{Location: jobEnv(job, "LOCATION")}
+			}
+			archives = append(archives, cachev1alpha1.BackupArchive{
+				ClaimName:      claim,
+				Location:       result.Location,
+				SizeBytes:      result.SizeBytes,
+				WorldSizeBytes: result.WorldSizeBytes,
+				Checksum:       result.Checksum,
+			})
+		case jobHasCondition(job, batchv1.JobFailed):
+			failed = append(failed, job)
+		case job.Spec.Suspend != nil && *job.Spec.Suspend:
+			suspended = append(suspended, job)
+		default:
+			// The Job is watched, its completion triggers the next reconciliation.
+			// The backup fails once it timed out otherwise.
+			return ctrl.Result{RequeueAfter: timeUntilBackupTimeout(backup, time.Now())}, nil
+		}
+	}
+
+	if len(failed) > 0 {
+		names := make([]string, 0, len(failed))
+		for _, job := range failed {
+			names = append(names, job.Name)
+		}
+		return r.fail(ctx, backup, minecraft, fmt.Sprintf("Backup Jobs failed: %s", strings.Join(names, ", ")))
+	}
+	if len(suspended) > 0 {
+		return r.resume(ctx, backup, minecraft, suspended)
+	}
+
+	if err := r.turnSavingOn(ctx, backup, minecraft); err != nil {
+		log.Error(err, "Failed to turn the saving of Minecraft back on")
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	backup.Status.Phase = cachev1alpha1.BackupPhaseCompleted
+	backup.Status.CompletionTime = &now
+	backup.Status.Archives = archives
+	backup.Status.SizeBytes = 0
+	for _, a := range archives {
+		backup.Status.SizeBytes += a.SizeBytes
+	}
+	if backup.Status.StartTime != nil {
+		backup.Status.Duration = &metav1.Duration{Duration: now.Sub(backup.Status.StartTime.Time).Round(time.Second)}
+	}
+	backup.Status.Message = fmt.Sprintf("Archived %d world volumes", len(archives))
+	if len(lost) > 0 {
+		backup.Status.Message += fmt.Sprintf(", the size and checksum of the archives of %s are unknown "+
+			"since the pods of their Jobs were deleted", strings.Join(lost, ", "))
+		r.Recorder.Event(backup, "Warning", "ResultLost", backup.Status.Message)
+	}
+	if err := r.finish(ctx, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(backup, "Normal", "Completed",
+		fmt.Sprintf("Archived the worlds of Minecraft %s", minecraft.Name))
+	return ctrl.Result{}, nil
+}
+
+// fail marks the backup as failed. The saving of the servers is turned back
+// on when the backup turned it off.
+func (r *MinecraftBackupReconciler) fail(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft, message string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if minecraft != nil && backup.Status.Phase == cachev1alpha1.BackupPhaseRunning {
+		if err := r.turnSavingOn(ctx, backup, minecraft); err != nil {
+			log.Error(err, "Failed to turn the saving of Minecraft back on")
+			return ctrl.Result{}, err
+		}
+	}
+
+	now := metav1.Now()
+	backup.Status.Phase = cachev1alpha1.BackupPhaseFailed
+	backup.Status.CompletionTime = &now
+	backup.Status.Message = message
+	if err := r.finish(ctx, backup); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(backup, "Warning", "Failed", message)
+	return ctrl.Result{}, nil
+}
+
+// finish updates the status of a backup which is done and removes its finalizer
+func (r *MinecraftBackupReconciler) finish(ctx context.Context, backup *cachev1alpha1.MinecraftBackup) error {
+	log := log.FromContext(ctx)
+
+	if err := r.Status().Update(ctx, backup); err != nil {
+		log.Error(err, "Failed to update MinecraftBackup status")
+		return err
+	}
+	if controllerutil.RemoveFinalizer(backup, backupFinalizer) {
+		if err := r.Update(ctx, backup); err != nil {
+			log.Error(err, "Failed to remove finalizer for MinecraftBackup")
+			return err
+		}
+	}
+	return nil
+}
+
+// jobResult reads the result the backup Job wrote as termination message. It
+// returns nil when the pods of the Job are gone.
+func (r *MinecraftBackupReconciler) jobResult(ctx context.Context, job *batchv1.Job) (*backupResult, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(job.Namespace),
+		client.MatchingLabels{batchv1.JobNameLabel: job.Name}); err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Terminated == nil || status.State.Terminated.Message == "" {
+				continue
+			}
+			result := &backupResult{}
+			if err := json.Unmarshal([]byte(status.State.Terminated.Message), result); err != nil {
+				return nil, fmt.Errorf("decoding the result of pod %s: %w", pod.Name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, nil
+}
+
+// jobEnv returns the value of an environment variable of the container of a Job
+func jobEnv(job *batchv1.Job, name string) string {
+	for _, c := range job.Spec.Template.Spec.Containers {
+		for _, env := range c.Env {
+			if env.Name == name {
+				return env.Value
+			}
+		}
+	}
+	return ""
+}
+
+// backupJobName returns the name of the Job archiving the given claim
+func backupJobName(backup *cachev1alpha1.MinecraftBackup, claim string) string {
+	return claimJobName(backup.Name, claim)
+}
+
+// claimJobName returns the name of the Job of the named resource working on
+// the given claim. It is derived from a hash of the claim to fit the length
+// of a label value.
+func claimJobName(name, claim string) string {
+	sum := sha256.Sum256([]byte(claim))
+	if len(name) > 52 {
+		name = name[:52]
+	}
+	return fmt.Sprintf("%s-%s", strings.TrimSuffix(name, "-"), hex.EncodeToString(sum[:])[:10])
+}
+
+// backupJobForMinecraft returns the Job archiving the world on the given claim
+// onto the target of the backup. The archive is written to the target volume,
+// or to a scratch volume it is uploaded from to S3, and its location, size and
+// checksum are reported as termination message with the size of the world.
+// The archive is made with the operand image, an upload to S3 runs in the
+// backup image once the init container making it completed.
+func (r *MinecraftBackupReconciler) backupJobForMinecraft(ctx context.Context, backup *cachev1alpha1.MinecraftBackup,
+	minecraft *cachev1alpha1.Minecraft, claim string) (*batchv1.Job, error) {
+	target := backup.Spec.Target
+	podName := strings.TrimPrefix(claim, worldVolumeName+"-")
+	archive := fmt.Sprintf("%s-%s.tar.gz", backup.Name, podName)
+
+	image, err := imageForMinecraft(minecraft)
+	if err != nil {
+		return nil, err
+	}
+	affinity, err := affinityForServerNode(ctx, r.Client, minecraft.Namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	env := []corev1.EnvVar{{Name: "ARCHIVE_NAME", Value: archive}}
+	mounts := []corev1.VolumeMount{{Name: worldVolumeName, MountPath: worldMountPath, ReadOnly: true}}
+	volumes := []corev1.Volume{{
+		Name: worldVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+		},
+	}}
+	// The result is written as termination message, or next to the archive for
+	// the container uploading it
+	result := "/dev/termination-log"
+	var upload *corev1.Container
+
+	switch {
+	case target.PersistentVolumeClaim != nil:
+		dir := path.Join(backupTargetMountPath, target.PersistentVolumeClaim.Path)
+		env = append(env,
+			corev1.EnvVar{Name: "ARCHIVE_DIR", Value: dir},
+			corev1.EnvVar{Name: "LOCATION", Value: target.PersistentVolumeClaim.ClaimName + ":" +
+				path.Join("/", target.PersistentVolumeClaim.Path, archive)})
+		mounts = append(mounts, corev1.VolumeMount{Name: backupTargetVolumeName, MountPath: backupTargetMountPath})
+		volumes = append(volumes, corev1.Volume{
+			Name: backupTargetVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: target.PersistentVolumeClaim.ClaimName,
+				},
+			},
+		})
+	case target.S3 != nil:
+		uploadImage, err := backupImage()
+		if err != nil {
+			return nil, err
+		}
+		key := target.S3.Prefix + archive
+		result = path.Join(backupTargetMountPath, backupResultFile)
+		env = append(env,
+			corev1.EnvVar{Name: "ARCHIVE_DIR", Value: backupTargetMountPath},
+			corev1.EnvVar{Name: "LOCATION", Value: fmt.Sprintf("s3://%s/%s", target.S3.Bucket, key)},
+			corev1.EnvVar{Name: "S3_ENDPOINT", Value: target.S3.Endpoint},
+			corev1.EnvVar{Name: "AWS_DEFAULT_REGION", Value: target.S3.Region})
+		mounts = append(mounts, corev1.VolumeMount{Name: backupTargetVolumeName, MountPath: backupTargetMountPath})
+		volumes = append(volumes, corev1.Volume{
+			Name:         backupTargetVolumeName,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+		upload = &corev1.Container{
+			Name:            "upload",
+			Image:           uploadImage,
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			Command:         []string{"/bin/sh", "-c"},
+			Args: []string{`set -eu
+aws s3 cp "$ARCHIVE_DIR/$ARCHIVE_NAME" "$LOCATION" ${S3_ENDPOINT:+--endpoint-url "$S3_ENDPOINT"}
+rm "$ARCHIVE_DIR/$ARCHIVE_NAME"
+cat "$RESULT" > /dev/termination-log
+`},
+			// Only the upload is given the credentials of the bucket
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: target.S3.CredentialsSecret},
+			}},
+			VolumeMounts: []corev1.VolumeMount{{Name: backupTargetVolumeName, MountPath: backupTargetMountPath}},
+		}
+	default:
+		// The world volume is mounted writable to keep the archive next to the world
+		mounts[0].ReadOnly = false
+		env = append(env,
+			corev1.EnvVar{Name: "ARCHIVE_DIR", Value: backupDir},
+			corev1.EnvVar{Name: "LOCATION", Value: claim + ":" + path.Join("/backups", archive)})
+	}
+
+	script := `set -eu
+WORLD_KB=$(du -sk "` + worldMountPath + `" | cut -f 1)
+if [ -d "` + backupDir + `" ]; then
+  WORLD_KB=$((WORLD_KB - $(du -sk "` + backupDir + `" | cut -f 1)))
+fi
+mkdir -p "$ARCHIVE_DIR"
+tar czf "$ARCHIVE_DIR/$ARCHIVE_NAME" --exclude=./backups -C "` + worldMountPath + `" .
+SIZE=$(wc -c < "$ARCHIVE_DIR/$ARCHIVE_NAME" | tr -d ' ')
+CHECKSUM=$(sha256sum "$ARCHIVE_DIR/$ARCHIVE_NAME" | cut -d ' ' -f 1)
+printf '{"location":"%s","sizeBytes":%s,"worldSizeBytes":%s,"checksum":"sha256:%s"}' \
+  "$LOCATION" "$SIZE" "$((WORLD_KB * 1024))" "$CHECKSUM" > "$RESULT"
+`
+
+	// The archive is made by the only container of the Job, or by an init
+	// container when another one uploads it
+	env = append(env, corev1.EnvVar{Name: "RESULT", Value: result})
+	archiver := corev1.Container{
+		Name:            "backup",
+		Image:           image,
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		Command:         []string{"/bin/sh", "-c"},
+		Args:            []string{script},
+		Env:             env,
+		VolumeMounts:    mounts,
+	}
+	var initContainers []corev1.Container
+	containers := []corev1.Container{archiver}
+	if upload != nil {
+		archiver.Name = "archive"
+		upload.Env = env
+		initContainers, containers = []corev1.Container{archiver}, []corev1.Container{*upload}
+	}
+
+	ls := jobLabelsForMinecraft(minecraft, "backup")
+	ls[backupJobLabel] = backup.Name
+
+	suspend := true
+	backoffLimit := int32(2)
+	deadline := int64(backupDeadline.Seconds())
+	ttl := backupJobTTL
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backupJobName(backup, claim),
+			Namespace: backup.Namespace,
+			Labels:    ls,
+		},
+		Spec: batchv1.JobSpec{
+			Suspend:                 &suspend,
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy:  corev1.RestartPolicyNever,
+					Affinity:       affinity,
+					InitContainers: initContainers,
+					Containers:     containers,
+					Volumes:        volumes,
+				},
+			},
+		},
+	}
+
+	// Set the ownerRef for the Job
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(backup, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// backupImage gets the image uploading archives to S3. It is read from the
+// BACKUP_IMAGE environment variable defined in the config/manager/manager.yaml,
+// and must provide sh, cat and the aws CLI. The archives are made with the
+// operand image beforehand.
+func backupImage() (string, error) {
+	var imageEnvVar = "BACKUP_IMAGE"
+	image, found := os.LookupEnv(imageEnvVar)
+	if !found {
+		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
+	}
+	return image, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MinecraftBackupReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cachev1alpha1.MinecraftBackup{}).
+		Owns(&batchv1.Job{}).
+		Complete(r)
+}
@@ -0,0 +1,434 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+	"time"
+
+	//nolint:golint
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+var _ = Describe("MinecraftBackup controller", func() {
+	eula := true
+
+	Context("MinecraftBackup controller test", func() {
+
+		const MinecraftName = "test-minecraft-backup"
+		const BackupName = "test-minecraft-backup-manual"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		backupNamespaceName := types.NamespacedName{
+			Name:      BackupName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should archive the world while the saving is turned off", func() {
+			By("Creating a running Minecraft instance with its world volume")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			claim := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "data-" + MinecraftName + "-0",
+					Namespace: namespace.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, claim)).To(Succeed())
+
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-rcon",
+					Namespace: namespace.Name,
+				},
+				Data: map[string][]byte{"password": []byte("secret")},
+			})).To(Succeed())
+
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.12"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			By("Creating the custom resource for the Kind MinecraftBackup")
+			backup := &cachev1alpha1.MinecraftBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      BackupName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftBackupSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: MinecraftName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			var commands []string
+			backupReconciler := &MinecraftBackupReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				RCON: func(_ context.Context, _, _ string, cmds ...string) ([]string, error) {
+					commands = append(commands, cmds...)
+					return make([]string, len(cmds)), nil
+				},
+			}
+
+			_, err := backupReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: backupNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the saving was turned off and a Job archives the world")
+			Expect(commands).To(Equal([]string{"save-off", "save-all flush"}))
+			Expect(k8sClient.Get(ctx, backupNamespaceName, backup)).To(Succeed())
+			Expect(backup.Status.Phase).To(Equal(cachev1alpha1.BackupPhaseRunning))
+
+			jobs := &batchv1.JobList{}
+			Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace.Name),
+				client.MatchingLabels{backupJobLabel: BackupName})).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(1))
+			job := &jobs.Items[0]
+			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(claim.Name))
+			Expect(job.Spec.Suspend).To(HaveValue(BeFalse()))
+			Expect(job.Spec.ActiveDeadlineSeconds).To(HaveValue(Equal(int64(backupDeadline.Seconds()))))
+
+			By("Completing the Job with its result")
+			jobPod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      job.Name + "-abcde",
+					Namespace: namespace.Name,
+					Labels:    map[string]string{batchv1.JobNameLabel: job.Name},
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{{Name: "backup", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, jobPod)).To(Succeed())
+			jobPod.Status.Phase = corev1.PodSucceeded
+			jobPod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+				Name: "backup",
+				State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+					Message: `{"location":"data-test-minecraft-backup-0:/backups/archive.tar.gz",` +
+						`"sizeBytes":1024,"worldSizeBytes":4096,"checksum":"sha256:abc"}`,
+				}},
+			}}
+			Expect(k8sClient.Status().Update(ctx, jobPod)).To(Succeed())
+
+			completed := metav1.NewTime(time.Now())
+			job.Status.StartTime = &completed
+			job.Status.CompletionTime = &completed
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+			})
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+			_, err = backupReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: backupNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the saving was turned back on and the archive recorded")
+			Expect(commands).To(Equal([]string{"save-off", "save-all flush", "save-on"}))
+			Expect(k8sClient.Get(ctx, backupNamespaceName, backup)).To(Succeed())
+			Expect(backup.Status.Phase).To(Equal(cachev1alpha1.BackupPhaseCompleted))
+			Expect(backup.Status.SizeBytes).To(Equal(int64(1024)))
+			Expect(backup.Status.Duration).NotTo(BeNil())
+			Expect(backup.Status.Archives).To(HaveLen(1))
+			Expect(backup.Status.Archives[0].Checksum).To(Equal("sha256:abc"))
+			Expect(backup.Status.Archives[0].WorldSizeBytes).To(Equal(int64(4096)))
+			Expect(backup.Finalizers).To(BeEmpty())
+		})
+
+		It("should turn the saving back on once the backup timed out", func() {
+			By("Creating a running Minecraft instance with its world volume")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "data-" + MinecraftName + "-0",
+					Namespace: namespace.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			})).To(Succeed())
+			Expect(k8sClient.Create(ctx, &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-rcon",
+					Namespace: namespace.Name,
+				},
+				Data: map[string][]byte{"password": []byte("secret")},
+			})).To(Succeed())
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName + "-0",
+					Namespace: namespace.Name,
+					Labels:    selectorLabelsForMinecraft(MinecraftName),
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "minecraft", Image: "example.com/image:test"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			pod.Status.PodIP = "10.0.0.12"
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			backup := &cachev1alpha1.MinecraftBackup{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      BackupName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftBackupSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: MinecraftName},
+				},
+			}
+			Expect(k8sClient.Create(ctx, backup)).To(Succeed())
+
+			var commands []string
+			backupReconciler := &MinecraftBackupReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+				RCON: func(_ context.Context, _, _ string, cmds ...string) ([]string, error) {
+					commands = append(commands, cmds...)
+					return make([]string, len(cmds)), nil
+				},
+			}
+			result, err := backupReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: backupNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(BeNumerically("~", backupTimeout, time.Minute))
+
+			By("Pretending the Job is still running after the timeout")
+			Expect(k8sClient.Get(ctx, backupNamespaceName, backup)).To(Succeed())
+			backup.Status.StartTime = &metav1.Time{Time: time.Now().Add(-2 * backupTimeout)}
+			Expect(k8sClient.Status().Update(ctx, backup)).To(Succeed())
+
+			_, err = backupReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: backupNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the saving was turned back on and the backup failed")
+			Expect(commands).To(Equal([]string{"save-off", "save-all flush", "save-on"}))
+			Expect(k8sClient.Get(ctx, backupNamespaceName, backup)).To(Succeed())
+			Expect(backup.Status.Phase).To(Equal(cachev1alpha1.BackupPhaseFailed))
+			Expect(backup.Status.Message).To(ContainSubstring("did not complete"))
+			Expect(backup.Finalizers).To(BeEmpty())
+		})
+	})
+
+	Context("MinecraftBackup to S3", func() {
+		It("should make the archive with the operand image and only upload it with the backup image", func() {
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+			Expect(os.Setenv("BACKUP_IMAGE", "example.com/aws-cli:test")).To(Succeed())
+			defer func() {
+				_ = os.Unsetenv("MINECRAFT_IMAGE")
+				_ = os.Unsetenv("BACKUP_IMAGE")
+			}()
+
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{Name: "survival", Namespace: "default"},
+			}
+			backup := &cachev1alpha1.MinecraftBackup{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default"},
+				Spec: cachev1alpha1.MinecraftBackupSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: "survival"},
+					Target: cachev1alpha1.BackupTarget{S3: &cachev1alpha1.S3BackupTarget{
+						Bucket:            "worlds",
+						CredentialsSecret: corev1.LocalObjectReference{Name: "s3"},
+					}},
+				},
+			}
+			reconciler := &MinecraftBackupReconciler{Client: k8sClient, Scheme: k8sClient.Scheme()}
+			job, err := reconciler.backupJobForMinecraft(context.Background(), backup, minecraft, "data-survival-0")
+			Expect(err).NotTo(HaveOccurred())
+
+			spec := job.Spec.Template.Spec
+			Expect(spec.InitContainers).To(HaveLen(1))
+			Expect(spec.InitContainers[0].Image).To(Equal("example.com/image:test"))
+			Expect(spec.InitContainers[0].Args[0]).To(ContainSubstring("tar czf"))
+			Expect(spec.InitContainers[0].EnvFrom).To(BeEmpty())
+			Expect(spec.Containers).To(HaveLen(1))
+			Expect(spec.Containers[0].Image).To(Equal("example.com/aws-cli:test"))
+			Expect(spec.Containers[0].Args[0]).To(ContainSubstring("aws s3 cp"))
+			Expect(spec.Containers[0].Args[0]).NotTo(ContainSubstring("tar "))
+			Expect(spec.Containers[0].EnvFrom).To(HaveLen(1))
+			Expect(jobEnv(job, "LOCATION")).To(Equal("s3://worlds/nightly-survival-0.tar.gz"))
+		})
+	})
+
+	Context("MinecraftBackup schedule", func() {
+
+		const MinecraftName = "test-minecraft-schedule"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should create the backups due by the schedule", func() {
+			By("Creating a custom resource with a backup schedule")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+					Backup: &cachev1alpha1.BackupSchedule{Schedule: "*/5 * * * *"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Pretending the last scheduled backup is older than the schedule")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			minecraft.Status.LastScheduleTime = &metav1.Time{Time: time.Now().Add(-time.Hour)}
+			Expect(k8sClient.Status().Update(ctx, minecraft)).To(Succeed())
+
+			result, err := minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			Expect(result.RequeueAfter).To(BeNumerically("<=", 5*time.Minute))
+
+			By("Checking if a single backup was created for the missed runs")
+			backups := &cachev1alpha1.MinecraftBackupList{}
+			Expect(k8sClient.List(ctx, backups, client.InNamespace(namespace.Name),
+				client.MatchingLabels{scheduledBackupLabel: MinecraftName})).To(Succeed())
+			Expect(backups.Items).To(HaveLen(1))
+			Expect(backups.Items[0].Spec.MinecraftRef.Name).To(Equal(MinecraftName))
+
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Status.LastScheduleTime.Time).To(BeTemporally(">", time.Now().Add(-5*time.Minute)))
+		})
+	})
+})
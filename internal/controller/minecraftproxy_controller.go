@@ -0,0 +1,703 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// proxyPort is the port the proxy listens on
+	proxyPort = 25577
+	// proxyConfigVolumeName is the name of the volume holding the rendered configuration
+	proxyConfigVolumeName = "config"
+	// proxyConfigMountPath is where the configuration is mounted, the image
+	// copies it into the proxy directory on startup
+	proxyConfigMountPath = "/config"
+	// velocityConfigKey is the name of the configuration file of Velocity
+	velocityConfigKey = "velocity.toml"
+	// bungeeCordConfigKey is the name of the configuration file of BungeeCord
+	bungeeCordConfigKey = "config.yml"
+	// forwardingSecretKey is the key of the forwarding secret in its Secret,
+	// and the name of the file Velocity reads it from
+	forwardingSecretKey = "forwarding.secret"
+)
+
+// MinecraftProxyReconciler reconciles a MinecraftProxy object
+type MinecraftProxyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftproxies,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftproxies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftproxies/finalizers,verbs=update
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecrafts,verbs=get;list;watch
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile runs a Velocity or BungeeCord proxy in front of the Minecraft
+// instances its selector matches. The server list of the proxy is rendered
+// from the backends and rolled out whenever they are added or removed.
+func (r *MinecraftProxyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	proxy := &cachev1alpha1.MinecraftProxy{}
+	if err := r.Get(ctx, req.NamespacedName, proxy); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("minecraftproxy resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get minecraftproxy")
+		return ctrl.Result{}, err
+	}
+
+	// Let's just set the status as Unknown when no status is available
+	if len(proxy.Status.Conditions) == 0 {
+		meta.SetStatusCondition(&proxy.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionUnknown, Reason: "Reconciling", Message: "Starting reconciliation"})
+		if err := r.Status().Update(ctx, proxy); err != nil {
+			log.Error(err, "Failed to update MinecraftProxy status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	backends, rejected, err := r.backendsForProxy(ctx, proxy)
+	if err != nil {
+		log.Error(err, "Failed to list the backends of MinecraftProxy")
+		return ctrl.Result{}, err
+	}
+	if len(rejected) > 0 {
+		r.Recorder.Event(proxy, "Warning", "BackendsRejected",
+			fmt.Sprintf("Not forwarding players to %s: the %s proxy supports %s backends not behind another proxy",
+				strings.Join(rejected, ", "), proxy.Spec.Type, forwardingTypesForProxy(proxy.Spec.Type)))
+	}
+
+	if err := r.reconcileForwardingSecret(ctx, proxy); err != nil {
+		log.Error(err, "Failed to reconcile the forwarding Secret of MinecraftProxy")
+		return ctrl.Result{}, err
+	}
+
+	cm, err := r.configMapForProxy(proxy, backends)
+	if err != nil {
+		log.Error(err, "Failed to define ConfigMap resource for MinecraftProxy")
+		return r.failProxy(ctx, proxy, fmt.Sprintf("Failed to render the configuration: (%s)", err))
+	}
+	if err := r.apply(ctx, cm); err != nil {
+		log.Error(err, "Failed to apply ConfigMap", "ConfigMap.Namespace", cm.Namespace, "ConfigMap.Name", cm.Name)
+		return ctrl.Result{}, err
+	}
+
+	dep, err := r.deploymentForProxy(proxy, cm)
+	if err != nil {
+		log.Error(err, "Failed to define Deployment resource for MinecraftProxy")
+		return r.failProxy(ctx, proxy, fmt.Sprintf("Failed to create Deployment for the custom resource (%s): (%s)",
+			proxy.Name, err))
+	}
+	if err := r.apply(ctx, dep); err != nil {
+		log.Error(err, "Failed to apply Deployment", "Deployment.Namespace", dep.Namespace, "Deployment.Name", dep.Name)
+		return ctrl.Result{}, err
+	}
+
+	svc, err := r.serviceForProxy(proxy)
+	if err != nil {
+		log.Error(err, "Failed to define Service resource for MinecraftProxy")
+		return ctrl.Result{}, err
+	}
+	if err := r.apply(ctx, svc); err != nil {
+		log.Error(err, "Failed to apply Service", "Service.Namespace", svc.Namespace, "Service.Name", svc.Name)
+		return ctrl.Result{}, err
+	}
+
+	names := make([]string, 0, len(backends))
+	for _, b := range backends {
+		names = append(names, b.Name)
+	}
+	if !slices.Equal(names, proxy.Status.Backends) {
+		r.Recorder.Event(proxy, "Normal", "BackendsChanged",
+			fmt.Sprintf("Forwarding players to %d backends: %s", len(names), strings.Join(names, ", ")))
+	}
+
+	proxy.Status.Backends = names
+	proxy.Status.ForwardingSecret = forwardingSecretNameForProxy(proxy)
+	proxy.Status.ObservedGeneration = proxy.Generation
+	proxy.Status.ReadyReplicas = dep.Status.ReadyReplicas
+	proxy.Status.Address = addressForProxy(svc)
+	if dep.Status.ReadyReplicas > 0 {
+		meta.SetStatusCondition(&proxy.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionTrue, Reason: "Reconciling",
+			Message: fmt.Sprintf("%d proxies of custom resource (%s) forward players to %d backends",
+				dep.Status.ReadyReplicas, proxy.Name, len(names))})
+	} else {
+		meta.SetStatusCondition(&proxy.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+			Status: metav1.ConditionFalse, Reason: "Starting",
+			Message: fmt.Sprintf("Waiting for the proxy of custom resource (%s) to be ready", proxy.Name)})
+	}
+	if err := r.Status().Update(ctx, proxy); err != nil {
+		log.Error(err, "Failed to update MinecraftProxy status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// failProxy reports a reconciliation error which retrying does not resolve
+func (r *MinecraftProxyReconciler) failProxy(ctx context.Context, proxy *cachev1alpha1.MinecraftProxy,
+	message string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	meta.SetStatusCondition(&proxy.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+		Status: metav1.ConditionFalse, Reason: "Reconciling", Message: message})
+	if err := r.Status().Update(ctx, proxy); err != nil {
+		log.Error(err, "Failed to update MinecraftProxy status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// apply converges obj to the given desired state with server-side apply
+func (r *MinecraftProxyReconciler) apply(ctx context.Context, obj client.Object) error {
+	return r.Patch(ctx, obj, client.Apply, fieldOwner, client.ForceOwnership)
+}
+
+// selectorForProxy returns the selector of the backends of the proxy. An empty
+// selector selects no backend rather than every instance of the namespace.
+func selectorForProxy(proxy *cachev1alpha1.MinecraftProxy) (labels.Selector, error) {
+	if len(proxy.Spec.Selector.MatchLabels) == 0 && len(proxy.Spec.Selector.MatchExpressions) == 0 {
+		return labels.Nothing(), nil
+	}
+	return metav1.LabelSelectorAsSelector(&proxy.Spec.Selector)
+}
+
+// backendsForProxy returns the Java Edition instances selected by the proxy,
+// sorted by name, and the names of the selected instances it can not forward
+// players to. An instance selected by several proxies is the backend of the
+// first one by name.
+func (r *MinecraftProxyReconciler) backendsForProxy(ctx context.Context,
+	proxy *cachev1alpha1.MinecraftProxy) ([]cachev1alpha1.Minecraft, []string, error) {
+	selector, err := selectorForProxy(proxy)
+	if err != nil {
+		return nil, nil, err
+	}
+	list := &cachev1alpha1.MinecraftList{}
+	if err := r.List(ctx, list, client.InNamespace(proxy.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, nil, err
+	}
+
+	var backends []cachev1alpha1.Minecraft
+	var rejected []string
+	for _, m := range list.Items {
+		if m.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if !supportsForwarding(proxy.Spec.Type, &m) {
+			rejected = append(rejected, fmt.Sprintf("%s (%s)", m.Name, typeForBackend(&m)))
+			continue
+		}
+		proxies, err := proxiesForMinecraft(ctx, r.Client, &m)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(proxies) > 0 && proxies[0].Name != proxy.Name {
+			rejected = append(rejected, fmt.Sprintf("%s (behind %s)", m.Name, proxies[0].Name))
+			continue
+		}
+		backends = append(backends, m)
+	}
+	sort.Slice(backends, func(i, j int) bool { return backends[i].Name < backends[j].Name })
+	sort.Strings(rejected)
+	return backends, rejected, nil
+}
+
+// typeForBackend returns the edition or server type shown for a backend
+func typeForBackend(minecraft *cachev1alpha1.Minecraft) string {
+	if isBedrock(minecraft) {
+		return string(cachev1alpha1.EditionBedrock)
+	}
+	if minecraft.Spec.Type == "" {
+		return string(cachev1alpha1.ServerTypeVanilla)
+	}
+	return string(minecraft.Spec.Type)
+}
+
+// proxiesForMinecraft returns the proxies selecting the custom resource which
+// can forward players to it, sorted by name. The first one is the proxy
+// players join the server through.
+func proxiesForMinecraft(ctx context.Context, c client.Reader,
+	minecraft *cachev1alpha1.Minecraft) ([]cachev1alpha1.MinecraftProxy, error) {
+	if isBedrock(minecraft) {
+		return nil, nil
+	}
+	list := &cachev1alpha1.MinecraftProxyList{}
+	if err := c.List(ctx, list, client.InNamespace(minecraft.Namespace)); err != nil {
+		return nil, err
+	}
+	var proxies []cachev1alpha1.MinecraftProxy
+	for _, p := range list.Items {
+		selector, err := selectorForProxy(&p)
+		if err != nil {
+			continue
+		}
+		if p.GetDeletionTimestamp() == nil && selector.Matches(labels.Set(minecraft.Labels)) &&
+			supportsForwarding(p.Spec.Type, minecraft) {
+			proxies = append(proxies, p)
+		}
+	}
+	sort.Slice(proxies, func(i, j int) bool { return proxies[i].Name < proxies[j].Name })
+	return proxies, nil
+}
+
+// forwardingSecretNameForProxy returns the name of the Secret holding the
+// forwarding secret of the proxy
+func forwardingSecretNameForProxy(proxy *cachev1alpha1.MinecraftProxy) string {
+	return fmt.Sprintf("%s-forwarding", proxy.Name)
+}
+
+// reconcileForwardingSecret creates the Secret with a generated forwarding
+// secret unless it exists. It is never rotated by the operator.
+func (r *MinecraftProxyReconciler) reconcileForwardingSecret(ctx context.Context,
+	proxy *cachev1alpha1.MinecraftProxy) error {
+	log := log.FromContext(ctx)
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: forwardingSecretNameForProxy(proxy), Namespace: proxy.Namespace}, secret)
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	value, err := generatePassword()
+	if err != nil {
+		return err
+	}
+	secret = &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      forwardingSecretNameForProxy(proxy),
+			Namespace: proxy.Namespace,
+			Labels:    labelsForProxy(proxy),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{forwardingSecretKey: []byte(value)},
+	}
+	if err := ctrl.SetControllerReference(proxy, secret, r.Scheme); err != nil {
+		return err
+	}
+
+	log.Info("Creating the forwarding Secret", "Secret.Namespace", secret.Namespace, "Secret.Name", secret.Name)
+	return r.Create(ctx, secret)
+}
+
+// configMapForProxy returns the ConfigMap holding the configuration file of
+// the proxy rendered for the backends
+func (r *MinecraftProxyReconciler) configMapForProxy(proxy *cachev1alpha1.MinecraftProxy,
+	backends []cachev1alpha1.Minecraft) (*corev1.ConfigMap, error) {
+	data := map[string]string{}
+	if proxy.Spec.Type == cachev1alpha1.ProxyTypeBungeeCord {
+		config, err := bungeeCordConfigForProxy(proxy, backends)
+		if err != nil {
+			return nil, err
+		}
+		data[bungeeCordConfigKey] = config
+	} else {
+		data[velocityConfigKey] = velocityConfigForProxy(proxy, backends)
+	}
+
+	cm := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-config", proxy.Name),
+			Namespace: proxy.Namespace,
+			Labels:    labelsForProxy(proxy),
+		},
+		Data: data,
+	}
+
+	// Set the ownerRef for the ConfigMap
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(proxy, cm, r.Scheme); err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
+
+// backendAddress returns the address of the Service of a backend inside the cluster
+func backendAddress(minecraft *cachev1alpha1.Minecraft) string {
+	host := fmt.Sprintf("%s.%s.svc", minecraft.Name, minecraft.Namespace)
+	return net.JoinHostPort(host, strconv.Itoa(int(servicePortForMinecraft(minecraft))))
+}
+
+// tryForProxy returns the names of the backends players join first
+func tryForProxy(proxy *cachev1alpha1.MinecraftProxy, backends []cachev1alpha1.Minecraft) []string {
+	var try []string
+	if len(proxy.Spec.Try) > 0 {
+		for _, name := range proxy.Spec.Try {
+			if slices.ContainsFunc(backends, func(m cachev1alpha1.Minecraft) bool { return m.Name == name }) {
+				try = append(try, name)
+			}
+		}
+		return try
+	}
+	for _, b := range backends {
+		try = append(try, b.Name)
+	}
+	return try
+}
+
+// maxPlayersForProxy returns the maximum number of players shown in the server list
+func maxPlayersForProxy(proxy *cachev1alpha1.MinecraftProxy) int32 {
+	if proxy.Spec.MaxPlayers != nil {
+		return *proxy.Spec.MaxPlayers
+	}
+	return 500
+}
+
+// velocityConfigForProxy renders the velocity.toml of the proxy. Players are
+// forwarded with the modern forwarding, signed with the forwarding secret.
+func velocityConfigForProxy(proxy *cachev1alpha1.MinecraftProxy, backends []cachev1alpha1.Minecraft) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "config-version = \"2.7\"\n")
+	fmt.Fprintf(&b, "bind = \"0.0.0.0:%d\"\n", proxyPort)
+	fmt.Fprintf(&b, "motd = %s\n", strconv.Quote(proxy.Spec.MOTD))
+	fmt.Fprintf(&b, "show-max-players = %d\n", maxPlayersForProxy(proxy))
+	fmt.Fprintf(&b, "online-mode = true\n")
+	fmt.Fprintf(&b, "player-info-forwarding-mode = \"modern\"\n")
+	fmt.Fprintf(&b, "forwarding-secret-file = %q\n", forwardingSecretKey)
+	fmt.Fprintf(&b, "\n[servers]\n")
+	for _, m := range backends {
+		fmt.Fprintf(&b, "%s = %q\n", strconv.Quote(m.Name), backendAddress(&m))
+	}
+	try := tryForProxy(proxy, backends)
+	quoted := make([]string, 0, len(try))
+	for _, name := range try {
+		quoted = append(quoted, strconv.Quote(name))
+	}
+	fmt.Fprintf(&b, "try = [%s]\n", strings.Join(quoted, ", "))
+	fmt.Fprintf(&b, "\n[forced-hosts]\n")
+	return b.String()
+}
+
+// bungeeCordListener is a listener of the BungeeCord configuration
+type bungeeCordListener struct {
+	Host               string   `json:"host"`
+	MOTD               string   `json:"motd"`
+	MaxPlayers         int32    `json:"max_players"`
+	Priorities         []string `json:"priorities"`
+	ForceDefaultServer bool     `json:"force_default_server"`
+}
+
+// bungeeCordServer is a backend of the BungeeCord configuration
+type bungeeCordServer struct {
+	Address    string `json:"address"`
+	MOTD       string `json:"motd"`
+	Restricted bool   `json:"restricted"`
+}
+
+// bungeeCordConfig is the subset of the BungeeCord configuration rendered by
+// the operator, the proxy fills in the defaults of the rest
+type bungeeCordConfig struct {
+	Listeners  []bungeeCordListener        `json:"listeners"`
+	Servers    map[string]bungeeCordServer `json:"servers"`
+	IPForward  bool                        `json:"ip_forward"`
+	OnlineMode bool                        `json:"online_mode"`
+}
+
+// bungeeCordConfigForProxy renders the config.yml of the proxy. Players are
+// forwarded with the legacy IP forwarding.
+func bungeeCordConfigForProxy(proxy *cachev1alpha1.MinecraftProxy,
+	backends []cachev1alpha1.Minecraft) (string, error) {
+	config := bungeeCordConfig{
+		Listeners: []bungeeCordListener{{
+			Host:       fmt.Sprintf("0.0.0.0:%d", proxyPort),
+			MOTD:       proxy.Spec.MOTD,
+			MaxPlayers: maxPlayersForProxy(proxy),
+			Priorities: tryForProxy(proxy, backends),
+		}},
+		Servers:    map[string]bungeeCordServer{},
+		IPForward:  true,
+		OnlineMode: true,
+	}
+	for _, m := range backends {
+		config.Servers[m.Name] = bungeeCordServer{Address: backendAddress(&m), MOTD: m.Spec.Config.MOTD}
+	}
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// deploymentForProxy returns the Deployment running the proxy. The pods are
+// rolled whenever the configuration changes.
+func (r *MinecraftProxyReconciler) deploymentForProxy(proxy *cachev1alpha1.MinecraftProxy,
+	cm *corev1.ConfigMap) (*appsv1.Deployment, error) {
+	image, err := imageForProxy(proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := int32(1)
+	if proxy.Spec.Replicas != nil {
+		replicas = *proxy.Spec.Replicas
+	}
+	proxyType := "VELOCITY"
+	if proxy.Spec.Type == cachev1alpha1.ProxyTypeBungeeCord {
+		proxyType = "BUNGEECORD"
+	}
+	ls := labelsForProxy(proxy)
+
+	dep := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      proxy.Name,
+			Namespace: proxy.Namespace,
+			Labels:    ls,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorLabelsForProxy(proxy.Name),
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+					Annotations: map[string]string{
+						configHashAnnotation: hashForData(cm.Data),
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Image:           image,
+						Name:            "proxy",
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Ports: []corev1.ContainerPort{{
+							Name:          gamePortName,
+							ContainerPort: proxyPort,
+							Protocol:      corev1.ProtocolTCP,
+						}},
+						Env: []corev1.EnvVar{{Name: "TYPE", Value: proxyType}},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString(gamePortName)},
+							},
+							PeriodSeconds: 10,
+						},
+						VolumeMounts: []corev1.VolumeMount{{
+							Name:      proxyConfigVolumeName,
+							MountPath: proxyConfigMountPath,
+							ReadOnly:  true,
+						}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: proxyConfigVolumeName,
+						VolumeSource: corev1.VolumeSource{
+							Projected: &corev1.ProjectedVolumeSource{
+								Sources: []corev1.VolumeProjection{
+									{ConfigMap: &corev1.ConfigMapProjection{
+										LocalObjectReference: corev1.LocalObjectReference{Name: cm.Name},
+									}},
+									{Secret: &corev1.SecretProjection{
+										LocalObjectReference: corev1.LocalObjectReference{
+											Name: forwardingSecretNameForProxy(proxy),
+										},
+									}},
+								},
+							},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	// Set the ownerRef for the Deployment
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(proxy, dep, r.Scheme); err != nil {
+		return nil, err
+	}
+	return dep, nil
+}
+
+// serviceForProxy returns the Service players connect to
+func (r *MinecraftProxyReconciler) serviceForProxy(proxy *cachev1alpha1.MinecraftProxy) (*corev1.Service, error) {
+	spec := proxy.Spec.Service
+
+	serviceType := spec.Type
+	if serviceType == "" {
+		serviceType = corev1.ServiceTypeClusterIP
+	}
+	port := spec.Port
+	if port == 0 {
+		port = cachev1alpha1.JavaGamePort
+	}
+
+	ports := []corev1.ServicePort{{
+		Name:       gamePortName,
+		Protocol:   corev1.ProtocolTCP,
+		Port:       port,
+		TargetPort: intstr.FromString(gamePortName),
+		NodePort:   spec.NodePort,
+	}}
+	for _, p := range spec.ExtraPorts {
+		ports = append(ports, corev1.ServicePort{
+			Name:       p.Name,
+			Protocol:   protocolForPort(p),
+			Port:       p.Port,
+			TargetPort: intstr.FromInt32(targetPortForPort(p)),
+			NodePort:   p.NodePort,
+		})
+	}
+
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        proxy.Name,
+			Namespace:   proxy.Namespace,
+			Labels:      labelsForProxy(proxy),
+			Annotations: spec.Annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:                  serviceType,
+			Selector:              selectorLabelsForProxy(proxy.Name),
+			Ports:                 ports,
+			LoadBalancerIP:        spec.LoadBalancerIP,
+			ExternalTrafficPolicy: spec.ExternalTrafficPolicy,
+		},
+	}
+
+	// Set the ownerRef for the Service
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(proxy, service, r.Scheme); err != nil {
+		return nil, err
+	}
+	return service, nil
+}
+
+// addressForProxy returns where players connect to the proxy: the address of
+// the load balancer once it is provisioned, the cluster address otherwise
+func addressForProxy(svc *corev1.Service) string {
+	port := strconv.Itoa(int(svc.Spec.Ports[0].Port))
+	if svc.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		for _, ingress := range svc.Status.LoadBalancer.Ingress {
+			if ingress.IP != "" {
+				return net.JoinHostPort(ingress.IP, port)
+			}
+			if ingress.Hostname != "" {
+				return net.JoinHostPort(ingress.Hostname, port)
+			}
+		}
+	}
+	return net.JoinHostPort(fmt.Sprintf("%s.%s.svc", svc.Name, svc.Namespace), port)
+}
+
+// labelsForProxy returns the labels of the resources of the proxy
+func labelsForProxy(proxy *cachev1alpha1.MinecraftProxy) map[string]string {
+	ls := selectorLabelsForProxy(proxy.Name)
+	ls["app.kubernetes.io/component"] = "proxy"
+	ls["app.kubernetes.io/part-of"] = "minecraft-operator"
+	ls["app.kubernetes.io/managed-by"] = "MinecraftProxyController"
+	return ls
+}
+
+// selectorLabelsForProxy returns the labels selecting the pods of the proxy
+func selectorLabelsForProxy(name string) map[string]string {
+	return map[string]string{
+		"app.kubernetes.io/name":     "minecraft-proxy",
+		"app.kubernetes.io/instance": name,
+	}
+}
+
+// imageForProxy gets the image of the proxy. Unless the spec overrides it, it
+// is read from the MINECRAFT_PROXY_IMAGE environment variable defined in the
+// config/manager/manager.yaml
+func imageForProxy(proxy *cachev1alpha1.MinecraftProxy) (string, error) {
+	if proxy.Spec.Image != "" {
+		return proxy.Spec.Image, nil
+	}
+	var imageEnvVar = "MINECRAFT_PROXY_IMAGE"
+	image, found := os.LookupEnv(imageEnvVar)
+	if !found {
+		return "", fmt.Errorf("Unable to find %s environment variable with the image", imageEnvVar)
+	}
+	return image, nil
+}
+
+// proxiesSelecting maps a Minecraft custom resource to the proxies selecting
+// it, so their server list follows the backends being added and removed
+func (r *MinecraftProxyReconciler) proxiesSelecting(ctx context.Context, obj client.Object) []reconcile.Request {
+	list := &cachev1alpha1.MinecraftProxyList{}
+	if err := r.List(ctx, list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, p := range list.Items {
+		// A backend whose labels no longer match must be removed as well, every
+		// proxy of the namespace renders its server list again
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Name: p.Name, Namespace: p.Namespace},
+		})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MinecraftProxyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cachev1alpha1.MinecraftProxy{}).
+		Owns(&appsv1.Deployment{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.ConfigMap{}).
+		Owns(&corev1.Secret{}).
+		Watches(&cachev1alpha1.Minecraft{}, handler.EnqueueRequestsFromMapFunc(r.proxiesSelecting)).
+		Complete(r)
+}
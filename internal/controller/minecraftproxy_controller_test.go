@@ -0,0 +1,305 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"os"
+
+	//nolint:golint
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+var _ = Describe("MinecraftProxy controller", func() {
+	eula := true
+
+	Context("MinecraftProxy controller test", func() {
+
+		const ProxyName = "test-minecraft-proxy"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ProxyName,
+				Namespace: ProxyName,
+			},
+		}
+
+		proxyNamespaceName := types.NamespacedName{
+			Name:      ProxyName,
+			Namespace: ProxyName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VARs which store the Operand images")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+			Expect(os.Setenv("MINECRAFT_PROXY_IMAGE", "example.com/proxy:test")).To(Succeed())
+			Expect(os.Setenv("ARTIFACT_IMAGE", "example.com/artifacts:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VARs which store the Operand images")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+			_ = os.Unsetenv("MINECRAFT_PROXY_IMAGE")
+			_ = os.Unsetenv("ARTIFACT_IMAGE")
+		})
+
+		It("should forward players to the backends selected by label", func() {
+			By("Creating a backend selected by the proxy, one which is not and one it can not forward to")
+			lobby := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "lobby",
+					Namespace: namespace.Name,
+					Labels:    map[string]string{"network": ProxyName},
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:    1,
+					Config:  cachev1alpha1.ServerConfig{EULA: &eula},
+					Type:    cachev1alpha1.ServerTypePaper,
+					Service: cachev1alpha1.ServiceSpec{Type: corev1.ServiceTypeNodePort, NodePort: 30565},
+				},
+			}
+			Expect(k8sClient.Create(ctx, lobby)).To(Succeed())
+			survival := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "survival",
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{Size: 1, Type: cachev1alpha1.ServerTypePurpur, Config: cachev1alpha1.ServerConfig{EULA: &eula}},
+			}
+			Expect(k8sClient.Create(ctx, survival)).To(Succeed())
+			vanilla := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "vanilla",
+					Namespace: namespace.Name,
+					Labels:    map[string]string{"network": ProxyName},
+				},
+				Spec: cachev1alpha1.MinecraftSpec{Size: 1, Type: cachev1alpha1.ServerTypeVanilla, Config: cachev1alpha1.ServerConfig{EULA: &eula}},
+			}
+			Expect(k8sClient.Create(ctx, vanilla)).To(Succeed())
+
+			By("Creating the custom resource for the Kind MinecraftProxy")
+			proxy := &cachev1alpha1.MinecraftProxy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      ProxyName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftProxySpec{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"network": ProxyName}},
+					Try:      []string{"lobby"},
+				},
+			}
+			Expect(k8sClient.Create(ctx, proxy)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			proxyReconciler := &MinecraftProxyReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := proxyReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: proxyNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the server list of Velocity holds the selected backend")
+			cm := &corev1.ConfigMap{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ProxyName + "-config",
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data[velocityConfigKey]).To(ContainSubstring(
+				`"lobby" = "lobby.` + ProxyName + `.svc:25565"`))
+			Expect(cm.Data[velocityConfigKey]).NotTo(ContainSubstring("survival"))
+			Expect(cm.Data[velocityConfigKey]).NotTo(ContainSubstring("vanilla"))
+			Expect(cm.Data[velocityConfigKey]).To(ContainSubstring(`try = ["lobby"]`))
+
+			By("Checking if the forwarding secret and the Deployment were created")
+			secret := &corev1.Secret{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ProxyName + "-forwarding",
+				Namespace: namespace.Name}, secret)).To(Succeed())
+			Expect(secret.Data[forwardingSecretKey]).NotTo(BeEmpty())
+
+			dep := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, proxyNamespaceName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Spec.Containers[0].Image).To(Equal("example.com/proxy:test"))
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, hashForData(cm.Data)))
+
+			Expect(k8sClient.Get(ctx, proxyNamespaceName, proxy)).To(Succeed())
+			Expect(proxy.Status.Backends).To(Equal([]string{"lobby"}))
+			Expect(proxy.Status.ForwardingSecret).To(Equal(secret.Name))
+
+			By("Checking if the selected backend runs in offline mode behind the proxy")
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			for _, m := range []*cachev1alpha1.Minecraft{lobby, survival, vanilla} {
+				_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+					NamespacedName: types.NamespacedName{Name: m.Name, Namespace: m.Namespace},
+				})
+				Expect(err).To(Not(HaveOccurred()))
+			}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(lobby),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).To(HaveKeyWithValue("ONLINE_MODE", "FALSE"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(survival),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).NotTo(HaveKey("ONLINE_MODE"))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: configMapNameForMinecraft(vanilla),
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data).NotTo(HaveKey("ONLINE_MODE"))
+
+			By("Checking if the selected backend verifies the forwarding secret")
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: lobby.Name, Namespace: namespace.Name},
+				sts)).To(Succeed())
+			Expect(forwardingConfigured(sts)).To(BeTrue())
+			Expect(sts.Spec.Template.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{
+				Name: forwardingSecretEnv,
+				ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+					Key:                  forwardingSecretKey,
+				}},
+			}))
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: vanilla.Name, Namespace: namespace.Name},
+				sts)).To(Succeed())
+			Expect(forwardingConfigured(sts)).To(BeFalse())
+
+			By("Checking if the Service of the selected backend is only reachable inside the cluster")
+			svc := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: lobby.Name, Namespace: namespace.Name},
+				svc)).To(Succeed())
+			Expect(svc.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(svc.Spec.Ports[0].NodePort).To(BeZero())
+
+			By("Adding a backend to the network")
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: survival.Name, Namespace: namespace.Name},
+				survival)).To(Succeed())
+			survival.Labels = map[string]string{"network": ProxyName}
+			Expect(k8sClient.Update(ctx, survival)).To(Succeed())
+
+			_, err = proxyReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: proxyNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: ProxyName + "-config",
+				Namespace: namespace.Name}, cm)).To(Succeed())
+			Expect(cm.Data[velocityConfigKey]).To(ContainSubstring(
+				`"survival" = "survival.` + ProxyName + `.svc:25565"`))
+			Expect(k8sClient.Get(ctx, proxyNamespaceName, dep)).To(Succeed())
+			Expect(dep.Spec.Template.Annotations).To(HaveKeyWithValue(configHashAnnotation, hashForData(cm.Data)))
+			Expect(k8sClient.Get(ctx, proxyNamespaceName, proxy)).To(Succeed())
+			Expect(proxy.Status.Backends).To(Equal([]string{"lobby", "survival"}))
+		})
+	})
+
+	Context("Forwarding to the backends", func() {
+		paper := func(version string) *cachev1alpha1.Minecraft {
+			return &cachev1alpha1.Minecraft{Spec: cachev1alpha1.MinecraftSpec{
+				Type:    cachev1alpha1.ServerTypePaper,
+				Version: version,
+			}}
+		}
+		velocity := &cachev1alpha1.MinecraftProxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "network"},
+			Spec:       cachev1alpha1.MinecraftProxySpec{Type: cachev1alpha1.ProxyTypeVelocity},
+		}
+
+		It("should only forward players to the server types supporting the proxy", func() {
+			spigot := &cachev1alpha1.Minecraft{Spec: cachev1alpha1.MinecraftSpec{Type: cachev1alpha1.ServerTypeSpigot}}
+			vanilla := &cachev1alpha1.Minecraft{Spec: cachev1alpha1.MinecraftSpec{Type: cachev1alpha1.ServerTypeVanilla}}
+			Expect(supportsForwarding(cachev1alpha1.ProxyTypeVelocity, paper("1.21"))).To(BeTrue())
+			Expect(supportsForwarding(cachev1alpha1.ProxyTypeVelocity, spigot)).To(BeFalse())
+			Expect(supportsForwarding(cachev1alpha1.ProxyTypeBungeeCord, spigot)).To(BeTrue())
+			Expect(supportsForwarding(cachev1alpha1.ProxyTypeBungeeCord, vanilla)).To(BeFalse())
+		})
+
+		It("should select no backend with an empty selector", func() {
+			selector, err := selectorForProxy(&cachev1alpha1.MinecraftProxy{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(selector.Empty()).To(BeFalse())
+			Expect(selector.Matches(labels.Set{"network": "lobby"})).To(BeFalse())
+		})
+
+		It("should enable the Velocity forwarding in the configuration of Paper", func() {
+			patches := forwardingPatchesForMinecraft(paper("1.21"), velocity)
+			Expect(patches).To(HaveLen(2))
+			Expect(patches[0].File).To(Equal("/data/spigot.yml"))
+			Expect(patches[0].Ops).To(ConsistOf(patchOperation{Set: patchSet{Path: "$.settings.bungeecord", Value: false}}))
+			Expect(patches[1].File).To(Equal("/data/config/paper-global.yml"))
+			Expect(patches[1].Ops).To(ConsistOf(
+				patchOperation{Set: patchSet{Path: "$.proxies.velocity.enabled", Value: true}},
+				patchOperation{Set: patchSet{Path: "$.proxies.velocity.online-mode", Value: true}},
+				patchOperation{Set: patchSet{Path: "$.proxies.velocity.secret", Value: "${CFG_FORWARDING_SECRET}"}},
+			))
+
+			seed, err := seedForPatch(patches[1])
+			Expect(err).NotTo(HaveOccurred())
+			Expect(seed).To(Equal("proxies:\n  velocity:\n    enabled: true\n    online-mode: true\n    secret: \"\"\n"))
+
+			By("Patching paper.yml before 1.19")
+			patches = forwardingPatchesForMinecraft(paper("1.18.2"), velocity)
+			Expect(patches[1].File).To(Equal("/data/paper.yml"))
+			Expect(patches[1].Ops[0].Set.Path).To(Equal("$.settings.velocity-support.enabled"))
+		})
+
+		It("should turn the forwarding off without a proxy", func() {
+			patches := forwardingPatchesForMinecraft(paper("1.21"), nil)
+			Expect(patches).To(HaveLen(2))
+			Expect(patches[0].Ops).To(ConsistOf(patchOperation{Set: patchSet{Path: "$.settings.bungeecord", Value: false}}))
+			Expect(patches[1].Ops).To(ConsistOf(patchOperation{Set: patchSet{Path: "$.proxies.velocity.enabled", Value: false}}))
+
+			script, err := forwardingScriptForMinecraft(patches)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(script).To(ContainSubstring("[ -f '/data/spigot.yml' ] || printf '%s' 'settings:\n  bungeecord: false\n' > '/data/spigot.yml'"))
+			Expect(script).To(ContainSubstring("> '/data/.forwarding.json'"))
+		})
+
+		It("should keep the Service of a backend inside the cluster", func() {
+			spec := serviceBehindProxy(cachev1alpha1.ServiceSpec{
+				Type:                  corev1.ServiceTypeLoadBalancer,
+				NodePort:              30565,
+				LoadBalancerIP:        "192.0.2.1",
+				ExternalTrafficPolicy: corev1.ServiceExternalTrafficPolicyLocal,
+				ExtraPorts:            []cachev1alpha1.ServicePort{{Name: "map", Port: 8123, NodePort: 30123}},
+			})
+			Expect(spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(spec.NodePort).To(BeZero())
+			Expect(spec.LoadBalancerIP).To(BeEmpty())
+			Expect(spec.ExternalTrafficPolicy).To(BeEmpty())
+			Expect(spec.ExtraPorts[0].NodePort).To(BeZero())
+		})
+	})
+})
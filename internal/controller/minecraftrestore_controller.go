@@ -0,0 +1,591 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+const (
+	// restoreAnnotation is set on a Minecraft custom resource with the name of
+	// the MinecraftRestore which keeps its servers stopped
+	restoreAnnotation = "cache.example.com/restore"
+	// restoreFinalizer makes sure the servers are started again when a restore
+	// is deleted before it completed
+	restoreFinalizer = "cache.example.com/restore-finalizer"
+	// restoreJobLabel is set on the Jobs of a restore with the name of the restore
+	restoreJobLabel = "cache.example.com/restore"
+	// restoreSourceVolumeName is the name of the volume the world is restored from
+	restoreSourceVolumeName = "source"
+	// restoreSourceMountPath is where the source volume is mounted in the Job
+	restoreSourceMountPath = "/source"
+	// volumeSnapshotGroup is the API group of the VolumeSnapshots
+	volumeSnapshotGroup = "snapshot.storage.k8s.io"
+)
+
+// Definitions to manage status conditions
+const (
+	// typeServerStoppedRestore represents whether the servers were stopped for the restore
+	typeServerStoppedRestore = "ServerStopped"
+	// typeWorldRestoredRestore represents the status of the Jobs restoring the worlds
+	typeWorldRestoredRestore = "WorldRestored"
+	// typeServerStartedRestore represents whether the servers are available again
+	typeServerStartedRestore = "ServerStarted"
+	// typeCompleteRestore is true once the restore succeeded and false when it failed
+	typeCompleteRestore = "Complete"
+)
+
+// MinecraftRestoreReconciler reconciles a MinecraftRestore object
+type MinecraftRestoreReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftrestores,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftrestores/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecraftrestores/finalizers,verbs=update
+// +kubebuilder:rbac:groups=cache.example.com,resources=minecrafts,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
+// +kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;create;delete
+
+// Reconcile replaces the worlds of a Minecraft instance. The servers are
+// stopped through an annotation on the custom resource, a Job per world volume
+// wipes it and unpacks the archive into it, and the servers are started again
+// once every Job completed. The progress is reported through conditions.
+func (r *MinecraftRestoreReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	restore := &cachev1alpha1.MinecraftRestore{}
+	if err := r.Get(ctx, req.NamespacedName, restore); err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Info("minecraftrestore resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get minecraftrestore")
+		return ctrl.Result{}, err
+	}
+
+	minecraft := &cachev1alpha1.Minecraft{}
+	err := r.Get(ctx, types.NamespacedName{Name: restore.Spec.MinecraftRef.Name, Namespace: restore.Namespace}, minecraft)
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Error(err, "Failed to get minecraft")
+		return ctrl.Result{}, err
+	}
+	if apierrors.IsNotFound(err) {
+		minecraft = nil
+	}
+
+	if restore.GetDeletionTimestamp() != nil {
+		if controllerutil.ContainsFinalizer(restore, restoreFinalizer) {
+			if err := r.release(ctx, restore, minecraft); err != nil {
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(restore, restoreFinalizer)
+			if err := r.Update(ctx, restore); err != nil {
+				log.Error(err, "Failed to remove finalizer for MinecraftRestore")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if complete := meta.FindStatusCondition(restore.Status.Conditions, typeCompleteRestore); complete != nil &&
+		complete.Status != metav1.ConditionUnknown {
+		return ctrl.Result{}, nil
+	}
+
+	if restore.Status.Conditions == nil {
+		if !controllerutil.ContainsFinalizer(restore, restoreFinalizer) {
+			controllerutil.AddFinalizer(restore, restoreFinalizer)
+			if err := r.Update(ctx, restore); err != nil {
+				log.Error(err, "Failed to add finalizer to MinecraftRestore")
+				return ctrl.Result{}, err
+			}
+		}
+		now := metav1.Now()
+		restore.Status.StartTime = &now
+		meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeCompleteRestore,
+			Status: metav1.ConditionUnknown, Reason: "Restoring", Message: "Starting the restore"})
+		if err := r.Status().Update(ctx, restore); err != nil {
+			log.Error(err, "Failed to update MinecraftRestore status")
+			return ctrl.Result{}, err
+		}
+	}
+
+	if minecraft == nil {
+		return r.fail(ctx, restore, nil, fmt.Sprintf("Minecraft %s not found", restore.Spec.MinecraftRef.Name))
+	}
+
+	if meta.IsStatusConditionTrue(restore.Status.Conditions, typeWorldRestoredRestore) {
+		return r.awaitServers(ctx, restore, minecraft)
+	}
+
+	// Only one restore at a time may hold the servers of an instance stopped
+	if holder := minecraft.Annotations[restoreAnnotation]; holder != restore.Name {
+		if holder != "" {
+			return r.setCondition(ctx, restore, typeServerStoppedRestore, metav1.ConditionFalse, "Waiting",
+				fmt.Sprintf("Waiting for MinecraftRestore %s to complete", holder))
+		}
+		patch := client.MergeFrom(minecraft.DeepCopy())
+		if minecraft.Annotations == nil {
+			minecraft.Annotations = map[string]string{}
+		}
+		minecraft.Annotations[restoreAnnotation] = restore.Name
+		if err := r.Patch(ctx, minecraft, patch); err != nil {
+			log.Error(err, "Failed to stop the servers of Minecraft")
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(restore, "Normal", "Stopping",
+			fmt.Sprintf("Stopping the servers of Minecraft %s", minecraft.Name))
+	}
+
+	pods, err := serverPods(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list server pods for Minecraft")
+		return ctrl.Result{}, err
+	}
+	if len(pods) > 0 {
+		return r.setCondition(ctx, restore, typeServerStoppedRestore, metav1.ConditionFalse, "Stopping",
+			fmt.Sprintf("Waiting for %d servers to stop", len(pods)))
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeServerStoppedRestore,
+		Status: metav1.ConditionTrue, Reason: "Stopped", Message: "The servers are stopped"})
+
+	claims, err := worldVolumeClaims(ctx, r.Client, minecraft)
+	if err != nil {
+		log.Error(err, "Failed to list world volumes of Minecraft")
+		return ctrl.Result{}, err
+	}
+	if len(claims) == 0 {
+		return r.fail(ctx, restore, minecraft, fmt.Sprintf("Minecraft %s has no world volume", minecraft.Name))
+	}
+
+	return r.restoreWorlds(ctx, restore, minecraft, claims)
+}
+
+// restoreWorlds creates the Jobs restoring the world volumes and waits for
+// them, after which the servers are started again. Every Job restoring from a
+// VolumeSnapshot gets a volume of its own, as the Jobs may run on different
+// nodes. The Jobs sharing a claim holding the archive run one after the other.
+func (r *MinecraftRestoreReconciler) restoreWorlds(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	minecraft *cachev1alpha1.Minecraft, claims []string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	sequential := restore.Spec.Source.PersistentVolumeClaim != nil
+	var failed []string
+	running := 0
+	for _, claim := range claims {
+		job := &batchv1.Job{}
+		err := r.Get(ctx, types.NamespacedName{Name: claimJobName(restore.Name, claim), Namespace: restore.Namespace}, job)
+		if apierrors.IsNotFound(err) {
+			if sequential && running > 0 {
+				running++
+				continue
+			}
+			if restore.Spec.Source.VolumeSnapshot != nil {
+				if err := r.reconcileSnapshotClaim(ctx, restore, claim); err != nil {
+					log.Error(err, "Failed to create the volume of the VolumeSnapshot")
+					return ctrl.Result{}, err
+				}
+			}
+			if job, err = r.restoreJobForMinecraft(ctx, restore, minecraft, claim); err != nil {
+				return r.fail(ctx, restore, minecraft, fmt.Sprintf("Failed to define the restore Job: (%s)", err))
+			}
+			log.Info("Creating restore Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+			if err := r.Create(ctx, job); err != nil {
+				log.Error(err, "Failed to create restore Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+				return ctrl.Result{}, err
+			}
+			running++
+			continue
+		} else if err != nil {
+			log.Error(err, "Failed to get restore Job")
+			return ctrl.Result{}, err
+		}
+
+		switch {
+		case jobHasCondition(job, batchv1.JobComplete):
+		case jobHasCondition(job, batchv1.JobFailed):
+			failed = append(failed, job.Name)
+		default:
+			running++
+		}
+	}
+
+	if len(failed) > 0 {
+		meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeWorldRestoredRestore,
+			Status: metav1.ConditionFalse, Reason: "Failed",
+			Message: fmt.Sprintf("Restore Jobs failed: %s", strings.Join(failed, ", "))})
+		return r.fail(ctx, restore, minecraft, fmt.Sprintf("Restore Jobs failed: %s", strings.Join(failed, ", ")))
+	}
+	if running > 0 {
+		// The Jobs are watched, their completion triggers the next reconciliation
+		return r.setCondition(ctx, restore, typeWorldRestoredRestore, metav1.ConditionFalse, "Restoring",
+			fmt.Sprintf("Restoring %d of %d world volumes", running, len(claims)))
+	}
+
+	if err := r.deleteSnapshotClaims(ctx, restore); err != nil {
+		log.Error(err, "Failed to delete the volumes of the VolumeSnapshot")
+		return ctrl.Result{}, err
+	}
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeWorldRestoredRestore,
+		Status: metav1.ConditionTrue, Reason: "Restored",
+		Message: fmt.Sprintf("Restored %d world volumes", len(claims))})
+	if err := r.release(ctx, restore, minecraft); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(restore, "Normal", "Restored",
+		fmt.Sprintf("Restored the worlds of Minecraft %s, starting its servers", minecraft.Name))
+	return r.setCondition(ctx, restore, typeServerStartedRestore, metav1.ConditionFalse, "Starting",
+		"Waiting for the servers to accept players")
+}
+
+// awaitServers completes the restore once the servers accept players again
+func (r *MinecraftRestoreReconciler) awaitServers(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	minecraft *cachev1alpha1.Minecraft) (ctrl.Result, error) {
+	if minecraft.Spec.Size > 0 && !meta.IsStatusConditionTrue(minecraft.Status.Conditions, typeAvailableMinecraft) {
+		// The custom resource is watched, its status triggers the next reconciliation
+		return ctrl.Result{RequeueAfter: startingRequeueInterval}, nil
+	}
+
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeServerStartedRestore,
+		Status: metav1.ConditionTrue, Reason: "Started", Message: "The servers accept players"})
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeCompleteRestore,
+		Status: metav1.ConditionTrue, Reason: "Restored",
+		Message: fmt.Sprintf("Restored the worlds of Minecraft %s", minecraft.Name)})
+	if err := r.finish(ctx, restore); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(restore, "Normal", "Completed",
+		fmt.Sprintf("Restored the worlds of Minecraft %s", minecraft.Name))
+	return ctrl.Result{}, nil
+}
+
+// setCondition updates a condition of the restore which keeps progressing
+func (r *MinecraftRestoreReconciler) setCondition(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	conditionType string, status metav1.ConditionStatus, reason, message string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: conditionType,
+		Status: status, Reason: reason, Message: message})
+	if err := r.Status().Update(ctx, restore); err != nil {
+		log.Error(err, "Failed to update MinecraftRestore status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: startingRequeueInterval}, nil
+}
+
+// fail marks the restore as failed and starts the servers again. A Job which
+// failed before wiping the world, e.g. because the checksum of the archive did
+// not match, leaves the world untouched.
+func (r *MinecraftRestoreReconciler) fail(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	minecraft *cachev1alpha1.Minecraft, message string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	if err := r.release(ctx, restore, minecraft); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.deleteSnapshotClaims(ctx, restore); err != nil {
+		log.Error(err, "Failed to delete the volumes of the VolumeSnapshot")
+		return ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	restore.Status.CompletionTime = &now
+	meta.SetStatusCondition(&restore.Status.Conditions, metav1.Condition{Type: typeCompleteRestore,
+		Status: metav1.ConditionFalse, Reason: "Failed", Message: message})
+	if err := r.finish(ctx, restore); err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Event(restore, "Warning", "Failed", message)
+	return ctrl.Result{}, nil
+}
+
+// finish updates the status of a restore which is done and removes its finalizer
+func (r *MinecraftRestoreReconciler) finish(ctx context.Context, restore *cachev1alpha1.MinecraftRestore) error {
+	log := log.FromContext(ctx)
+
+	if err := r.Status().Update(ctx, restore); err != nil {
+		log.Error(err, "Failed to update MinecraftRestore status")
+		return err
+	}
+	if controllerutil.RemoveFinalizer(restore, restoreFinalizer) {
+		if err := r.Update(ctx, restore); err != nil {
+			log.Error(err, "Failed to remove finalizer for MinecraftRestore")
+			return err
+		}
+	}
+	return nil
+}
+
+// release removes the annotation keeping the servers stopped when the restore
+// holds it, so the Minecraft controller starts them again
+func (r *MinecraftRestoreReconciler) release(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	minecraft *cachev1alpha1.Minecraft) error {
+	log := log.FromContext(ctx)
+
+	if minecraft == nil || minecraft.Annotations[restoreAnnotation] != restore.Name {
+		return nil
+	}
+	patch := client.MergeFrom(minecraft.DeepCopy())
+	delete(minecraft.Annotations, restoreAnnotation)
+	if err := r.Patch(ctx, minecraft, patch); err != nil {
+		log.Error(err, "Failed to start the servers of Minecraft")
+		return err
+	}
+	return nil
+}
+
+// snapshotClaimName returns the name of the claim provisioned from the
+// VolumeSnapshot the given world volume is restored from
+func snapshotClaimName(restore *cachev1alpha1.MinecraftRestore, worldClaim string) string {
+	return claimJobName(restore.Name, restore.Spec.Source.VolumeSnapshot.Name+"/"+worldClaim)
+}
+
+// reconcileSnapshotClaim provisions a claim from the VolumeSnapshot for the
+// Job restoring the given world volume, with its storage class and size. The
+// claim is only mounted by that Job, next to the world volume.
+func (r *MinecraftRestoreReconciler) reconcileSnapshotClaim(ctx context.Context,
+	restore *cachev1alpha1.MinecraftRestore, worldClaim string) error {
+	log := log.FromContext(ctx)
+
+	name := snapshotClaimName(restore, worldClaim)
+	err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: restore.Namespace}, &corev1.PersistentVolumeClaim{})
+	if err == nil || !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	world := &corev1.PersistentVolumeClaim{}
+	if err := r.Get(ctx, types.NamespacedName{Name: worldClaim, Namespace: restore.Namespace}, world); err != nil {
+		return err
+	}
+	apiGroup := volumeSnapshotGroup
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: restore.Namespace,
+			Labels:    map[string]string{restoreJobLabel: restore.Name},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+			StorageClassName: world.Spec.StorageClassName,
+			Resources:        world.Spec.Resources,
+			DataSource: &corev1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     restore.Spec.Source.VolumeSnapshot.Name,
+			},
+		},
+	}
+	if err := ctrl.SetControllerReference(restore, claim, r.Scheme); err != nil {
+		return err
+	}
+	log.Info("Creating the volume of the VolumeSnapshot", "PersistentVolumeClaim.Name", claim.Name)
+	return r.Create(ctx, claim)
+}
+
+// deleteSnapshotClaims deletes the claims provisioned from the VolumeSnapshot
+// once the restore no longer needs them
+func (r *MinecraftRestoreReconciler) deleteSnapshotClaims(ctx context.Context,
+	restore *cachev1alpha1.MinecraftRestore) error {
+	if restore.Spec.Source.VolumeSnapshot == nil {
+		return nil
+	}
+	claims := &corev1.PersistentVolumeClaimList{}
+	if err := r.List(ctx, claims, client.InNamespace(restore.Namespace),
+		client.MatchingLabels{restoreJobLabel: restore.Name}); err != nil {
+		return err
+	}
+	for i := range claims.Items {
+		if err := client.IgnoreNotFound(r.Delete(ctx, &claims.Items[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreJobForMinecraft returns the Job restoring the world on the given
+// claim. The archive is fetched and verified before the world is wiped, so a
+// missing or corrupted archive leaves the world untouched. The backups kept
+// on the world volume survive the restore.
+func (r *MinecraftRestoreReconciler) restoreJobForMinecraft(ctx context.Context, restore *cachev1alpha1.MinecraftRestore,
+	minecraft *cachev1alpha1.Minecraft, claim string) (*batchv1.Job, error) {
+	source := restore.Spec.Source
+	podName := strings.TrimPrefix(claim, worldVolumeName+"-")
+
+	image, err := imageForMinecraft(minecraft)
+	if err != nil {
+		return nil, err
+	}
+	affinity, err := affinityForServerNode(ctx, r.Client, minecraft.Namespace, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	env := []corev1.EnvVar{{Name: "CHECKSUM", Value: strings.TrimPrefix(restore.Spec.Checksum, "sha256:")}}
+	mounts := []corev1.VolumeMount{{Name: worldVolumeName, MountPath: worldMountPath}}
+	volumes := []corev1.Volume{{
+		Name: worldVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: claim},
+		},
+	}}
+	sourceClaim := ""
+	var fetch, unpack string
+
+	switch {
+	case source.PersistentVolumeClaim != nil:
+		sourceClaim = source.PersistentVolumeClaim.ClaimName
+		archive := path.Join(restoreSourceMountPath, source.PersistentVolumeClaim.Path)
+		if sourceClaim == claim {
+			// The archive is kept in the backups directory of the world volume
+			archive = path.Join(worldMountPath, source.PersistentVolumeClaim.Path)
+			sourceClaim = ""
+		}
+		env = append(env, corev1.EnvVar{Name: "ARCHIVE", Value: archive})
+		unpack = `tar xzf "$ARCHIVE" -C "` + worldMountPath + `"
+`
+	case source.URL != "":
+		env = append(env,
+			corev1.EnvVar{Name: "ARCHIVE", Value: path.Join(backupDir, ".restore-"+restore.Name+".tar.gz")},
+			corev1.EnvVar{Name: "URL", Value: source.URL})
+		fetch = `mkdir -p "$(dirname "$ARCHIVE")"
+trap 'rm -f "$ARCHIVE"' EXIT
+curl -fsSL -o "$ARCHIVE" "$URL"
+`
+		unpack = `tar xzf "$ARCHIVE" -C "` + worldMountPath + `"
+`
+	case source.VolumeSnapshot != nil:
+		sourceClaim = snapshotClaimName(restore, claim)
+		unpack = `cp -a "` + restoreSourceMountPath + `/." "` + worldMountPath + `/"
+`
+	default:
+		return nil, fmt.Errorf("restore %s has no source", restore.Name)
+	}
+
+	if sourceClaim != "" {
+		mounts = append(mounts, corev1.VolumeMount{Name: restoreSourceVolumeName,
+			MountPath: restoreSourceMountPath, ReadOnly: true})
+		volumes = append(volumes, corev1.Volume{
+			Name: restoreSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: sourceClaim, ReadOnly: true},
+			},
+		})
+	}
+
+	script := `set -eu
+` + fetch + `if [ -n "$CHECKSUM" ]; then
+  echo "$CHECKSUM  $ARCHIVE" | sha256sum -c -
+fi
+find "` + worldMountPath + `" -mindepth 1 -maxdepth 1 ! -name backups -exec rm -rf {} +
+` + unpack
+
+	ls := jobLabelsForMinecraft(minecraft, "restore")
+	ls[restoreJobLabel] = restore.Name
+
+	backoffLimit := int32(2)
+	ttl := backupJobTTL
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      claimJobName(restore.Name, claim),
+			Namespace: restore.Namespace,
+			Labels:    ls,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: ls,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Affinity:      affinity,
+					Containers: []corev1.Container{{
+						Name:            "restore",
+						Image:           image,
+						ImagePullPolicy: corev1.PullIfNotPresent,
+						Command:         []string{"/bin/sh", "-c"},
+						Args:            []string{script},
+						Env:             env,
+						VolumeMounts:    mounts,
+					}},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+
+	// Set the ownerRef for the Job
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/owners-dependents/
+	if err := ctrl.SetControllerReference(restore, job, r.Scheme); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// restoresForMinecraft maps a Minecraft custom resource to the restores
+// targeting it, so they follow its servers stopping and starting
+func (r *MinecraftRestoreReconciler) restoresForMinecraft(ctx context.Context, obj client.Object) []reconcile.Request {
+	restores := &cachev1alpha1.MinecraftRestoreList{}
+	if err := r.List(ctx, restores, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil
+	}
+	var requests []reconcile.Request
+	for _, restore := range restores.Items {
+		if restore.Spec.MinecraftRef.Name == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: restore.Name, Namespace: restore.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MinecraftRestoreReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&cachev1alpha1.MinecraftRestore{}).
+		Owns(&batchv1.Job{}).
+		Watches(&cachev1alpha1.Minecraft{}, handler.EnqueueRequestsFromMapFunc(r.restoresForMinecraft)).
+		Complete(r)
+}
@@ -0,0 +1,326 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	//nolint:golint
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+var _ = Describe("MinecraftRestore controller", func() {
+	eula := true
+
+	Context("MinecraftRestore controller test", func() {
+
+		const MinecraftName = "test-minecraft-restore"
+		const RestoreName = "test-minecraft-restore-url"
+		const Checksum = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		typeNamespaceName := types.NamespacedName{
+			Name:      MinecraftName,
+			Namespace: MinecraftName,
+		}
+
+		restoreNamespaceName := types.NamespacedName{
+			Name:      RestoreName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should stop the servers, restore the world and start them again", func() {
+			By("Creating a Minecraft instance with its world volume")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   1,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			claim := &corev1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "data-" + MinecraftName + "-0",
+					Namespace: namespace.Name,
+				},
+				Spec: corev1.PersistentVolumeClaimSpec{
+					AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+					Resources: corev1.VolumeResourceRequirements{
+						Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, claim)).To(Succeed())
+
+			By("Creating the custom resource for the Kind MinecraftRestore")
+			restore := &cachev1alpha1.MinecraftRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      RestoreName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftRestoreSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: MinecraftName},
+					Source:       cachev1alpha1.RestoreSource{URL: "https://example.com/world.tar.gz"},
+					Checksum:     Checksum,
+				},
+			}
+			Expect(k8sClient.Create(ctx, restore)).To(Succeed())
+
+			By("Reconciling the custom resource created")
+			restoreReconciler := &MinecraftRestoreReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			minecraftReconciler := &MinecraftReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			_, err := restoreReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: restoreNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the servers of the Minecraft instance are stopped")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Annotations).To(HaveKeyWithValue(restoreAnnotation, RestoreName))
+
+			_, err = minecraftReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+			sts := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, typeNamespaceName, sts)).To(Succeed())
+			Expect(*sts.Spec.Replicas).To(Equal(int32(0)))
+
+			By("Checking if a Job verifies and unpacks the archive into the world volume")
+			Expect(k8sClient.Get(ctx, restoreNamespaceName, restore)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, typeServerStoppedRestore)).To(BeTrue())
+			Expect(restore.Finalizers).To(ContainElement(restoreFinalizer))
+
+			jobs := &batchv1.JobList{}
+			Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace.Name),
+				client.MatchingLabels{restoreJobLabel: RestoreName})).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(1))
+			job := &jobs.Items[0]
+			container := job.Spec.Template.Spec.Containers[0]
+			Expect(job.Spec.Template.Spec.Volumes[0].PersistentVolumeClaim.ClaimName).To(Equal(claim.Name))
+			Expect(container.Env).To(ContainElements(
+				corev1.EnvVar{Name: "CHECKSUM", Value: Checksum[len("sha256:"):]},
+				corev1.EnvVar{Name: "URL", Value: "https://example.com/world.tar.gz"},
+			))
+
+			By("Completing the Job")
+			completed := metav1.NewTime(time.Now())
+			job.Status.StartTime = &completed
+			job.Status.CompletionTime = &completed
+			job.Status.Conditions = append(job.Status.Conditions, batchv1.JobCondition{
+				Type:   batchv1.JobComplete,
+				Status: corev1.ConditionTrue,
+			})
+			Expect(k8sClient.Status().Update(ctx, job)).To(Succeed())
+
+			_, err = restoreReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: restoreNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if the servers are started again")
+			Expect(k8sClient.Get(ctx, typeNamespaceName, minecraft)).To(Succeed())
+			Expect(minecraft.Annotations).NotTo(HaveKey(restoreAnnotation))
+			Expect(k8sClient.Get(ctx, restoreNamespaceName, restore)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, typeWorldRestoredRestore)).To(BeTrue())
+
+			By("Completing the restore once the server accepts players")
+			meta.SetStatusCondition(&minecraft.Status.Conditions, metav1.Condition{Type: typeAvailableMinecraft,
+				Status: metav1.ConditionTrue, Reason: "Reconciling", Message: "Ready"})
+			Expect(k8sClient.Status().Update(ctx, minecraft)).To(Succeed())
+
+			_, err = restoreReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: restoreNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			Expect(k8sClient.Get(ctx, restoreNamespaceName, restore)).To(Succeed())
+			Expect(meta.IsStatusConditionTrue(restore.Status.Conditions, typeCompleteRestore)).To(BeTrue())
+			Expect(restore.Status.CompletionTime).NotTo(BeNil())
+			Expect(restore.Finalizers).To(BeEmpty())
+		})
+	})
+
+	Context("MinecraftRestore controller snapshot test", func() {
+
+		const MinecraftName = "test-minecraft-restore-snapshot"
+		const RestoreName = "test-minecraft-restore-snapshot"
+
+		ctx := context.Background()
+
+		namespace := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      MinecraftName,
+				Namespace: MinecraftName,
+			},
+		}
+
+		restoreNamespaceName := types.NamespacedName{
+			Name:      RestoreName,
+			Namespace: MinecraftName,
+		}
+
+		BeforeEach(func() {
+			By("Creating the Namespace to perform the tests")
+			Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+			By("Setting the Image ENV VAR which stores the Operand image")
+			Expect(os.Setenv("MINECRAFT_IMAGE", "example.com/image:test")).To(Succeed())
+		})
+
+		AfterEach(func() {
+			By("Deleting the Namespace to perform the tests")
+			_ = k8sClient.Delete(ctx, namespace)
+
+			By("Removing the Image ENV VAR which stores the Operand image")
+			_ = os.Unsetenv("MINECRAFT_IMAGE")
+		})
+
+		It("should give every restore Job its own volume of the VolumeSnapshot", func() {
+			By("Creating a Minecraft instance with two world volumes")
+			minecraft := &cachev1alpha1.Minecraft{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      MinecraftName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftSpec{
+					Size:   2,
+					Config: cachev1alpha1.ServerConfig{EULA: &eula},
+				},
+			}
+			Expect(k8sClient.Create(ctx, minecraft)).To(Succeed())
+
+			for i := 0; i < 2; i++ {
+				Expect(k8sClient.Create(ctx, &corev1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      fmt.Sprintf("data-%s-%d", MinecraftName, i),
+						Namespace: namespace.Name,
+					},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						AccessModes: []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce},
+						Resources: corev1.VolumeResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("1Gi")},
+						},
+					},
+				})).To(Succeed())
+			}
+
+			By("Restoring the worlds from a VolumeSnapshot")
+			restore := &cachev1alpha1.MinecraftRestore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      RestoreName,
+					Namespace: namespace.Name,
+				},
+				Spec: cachev1alpha1.MinecraftRestoreSpec{
+					MinecraftRef: corev1.LocalObjectReference{Name: MinecraftName},
+					Source: cachev1alpha1.RestoreSource{
+						VolumeSnapshot: &corev1.LocalObjectReference{Name: "world-snapshot"},
+					},
+				},
+			}
+			Expect(k8sClient.Create(ctx, restore)).To(Succeed())
+
+			restoreReconciler := &MinecraftRestoreReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				Recorder: record.NewFakeRecorder(10),
+			}
+			_, err := restoreReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: restoreNamespaceName,
+			})
+			Expect(err).To(Not(HaveOccurred()))
+
+			By("Checking if every Job mounts a volume of its own")
+			jobs := &batchv1.JobList{}
+			Expect(k8sClient.List(ctx, jobs, client.InNamespace(namespace.Name),
+				client.MatchingLabels{restoreJobLabel: RestoreName})).To(Succeed())
+			Expect(jobs.Items).To(HaveLen(2))
+			sources := map[string]bool{}
+			for _, job := range jobs.Items {
+				for _, volume := range job.Spec.Template.Spec.Volumes {
+					if volume.Name == restoreSourceVolumeName {
+						sources[volume.PersistentVolumeClaim.ClaimName] = true
+					}
+				}
+			}
+			Expect(sources).To(HaveLen(2))
+
+			claims := &corev1.PersistentVolumeClaimList{}
+			Expect(k8sClient.List(ctx, claims, client.InNamespace(namespace.Name),
+				client.MatchingLabels{restoreJobLabel: RestoreName})).To(Succeed())
+			Expect(claims.Items).To(HaveLen(2))
+			for _, claim := range claims.Items {
+				Expect(sources).To(HaveKey(claim.Name))
+				Expect(claim.Spec.DataSource).To(HaveField("Name", "world-snapshot"))
+			}
+		})
+	})
+})
@@ -33,6 +33,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	cachev1beta1 "github.com/example/minecraft-operator/api/v1beta1"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -75,6 +76,9 @@ var _ = BeforeSuite(func() {
 	err = cachev1alpha1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = cachev1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	// +kubebuilder:scaffold:scheme
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
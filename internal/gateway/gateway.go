@@ -0,0 +1,374 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package gateway fronts the Minecraft instances with autoPause. It routes the
+// players by the hostname of their handshake, answers the status requests of
+// sleeping servers, wakes them up when a player joins and forwards the players
+// to the running servers.
+package gateway
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/slp"
+)
+
+const (
+	// HostnameIndex indexes the Minecraft custom resources by the hostnames of
+	// their autoPause spec
+	HostnameIndex = "spec.autoPause.hostnames"
+
+	// handshakeTimeout bounds the exchange with a player until it is forwarded
+	handshakeTimeout = 10 * time.Second
+	// dialTimeout bounds the connection to a running server
+	dialTimeout = 5 * time.Second
+)
+
+// DialFunc connects to the server listening at address
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Gateway accepts the connections of the players. It is added to the manager
+// and serves on every replica of the operator, not only the leader.
+type Gateway struct {
+	// Client reads the Minecraft custom resources and wakes them up
+	Client client.Client
+	// Addr is the TCP address the gateway listens on
+	Addr string
+	// Dial connects to the running servers, with a net.Dialer when not set
+	Dial DialFunc
+	// Log receives the errors of the connections
+	Log logr.Logger
+}
+
+// SetupWithManager indexes the hostnames of the Minecraft custom resources and
+// adds the gateway to the manager
+func (g *Gateway) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &cachev1alpha1.Minecraft{},
+		HostnameIndex, HostnamesForMinecraft); err != nil {
+		return err
+	}
+	return mgr.Add(g)
+}
+
+// HostnamesForMinecraft is the index function of HostnameIndex
+func HostnamesForMinecraft(obj client.Object) []string {
+	minecraft, ok := obj.(*cachev1alpha1.Minecraft)
+	if !ok || minecraft.Spec.AutoPause == nil {
+		return nil
+	}
+	hostnames := make([]string, 0, len(minecraft.Spec.AutoPause.Hostnames))
+	for _, hostname := range minecraft.Spec.AutoPause.Hostnames {
+		hostnames = append(hostnames, normalizeHostname(hostname))
+	}
+	return hostnames
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (g *Gateway) NeedLeaderElection() bool {
+	return false
+}
+
+// Start listens on the address of the gateway until ctx is done
+func (g *Gateway) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", g.Addr)
+	if err != nil {
+		return err
+	}
+	g.Log.Info("Starting gateway", "address", listener.Addr().String())
+	return g.Serve(ctx, listener)
+}
+
+// Serve accepts the connections of listener until ctx is done
+func (g *Gateway) Serve(ctx context.Context, listener net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go func() {
+			defer conn.Close() //nolint:errcheck
+			if err := g.handle(ctx, conn); err != nil {
+				g.Log.V(1).Info("Connection failed", "remote", conn.RemoteAddr().String(), "error", err.Error())
+			}
+		}()
+	}
+}
+
+// handle serves a connection according to the state of the instance its
+// handshake is routed to
+func (g *Gateway) handle(ctx context.Context, conn net.Conn) error {
+	if err := conn.SetDeadline(time.Now().Add(handshakeTimeout)); err != nil {
+		return err
+	}
+	r := bufio.NewReader(conn)
+
+	id, data, err := slp.ReadPacket(r)
+	if err != nil {
+		return fmt.Errorf("reading handshake: %w", err)
+	}
+	if id != slp.PacketHandshake {
+		return fmt.Errorf("unexpected packet 0x%02x in place of the handshake", id)
+	}
+	handshake, err := slp.ParseHandshake(data)
+	if err != nil {
+		return err
+	}
+
+	hostname := normalizeHostname(handshake.ServerAddress)
+	minecraft, err := g.lookup(ctx, hostname)
+	if err != nil {
+		return err
+	}
+	if minecraft == nil {
+		if handshake.NextState == slp.StateLogin {
+			return slp.WriteDisconnect(conn, fmt.Sprintf("No server is known as %s", hostname))
+		}
+		return fmt.Errorf("no Minecraft has the hostname %q", hostname)
+	}
+
+	if minecraft.Status.Phase == cachev1alpha1.MinecraftPhaseRunning {
+		if handshake.NextState == slp.StateLogin {
+			// The servers behind a proxy run in offline mode and trust the
+			// players it forwards, so nobody may join them around it
+			proxy, err := g.proxyFor(ctx, minecraft)
+			if err != nil {
+				_ = slp.WriteDisconnect(conn, "The server could not be reached, try again later")
+				return err
+			}
+			if proxy != nil {
+				return slp.WriteDisconnect(conn, joinThroughProxy(proxy))
+			}
+		}
+		return g.forward(ctx, conn, r, data, minecraft)
+	}
+
+	switch handshake.NextState {
+	case slp.StateStatus:
+		return g.status(conn, r, handshake, minecraft)
+	case slp.StateLogin:
+		if err := g.wake(ctx, minecraft); err != nil {
+			_ = slp.WriteDisconnect(conn, "The server could not be started, try again later")
+			return err
+		}
+		return slp.WriteDisconnect(conn, "The server is starting, join again in a minute")
+	}
+	return fmt.Errorf("unexpected next state %d", handshake.NextState)
+}
+
+// lookup returns the Minecraft custom resource with the hostname, or nil when
+// none has it. The oldest one wins when several claim the same hostname.
+func (g *Gateway) lookup(ctx context.Context, hostname string) (*cachev1alpha1.Minecraft, error) {
+	list := &cachev1alpha1.MinecraftList{}
+	if err := g.Client.List(ctx, list, client.MatchingFields{HostnameIndex: hostname}); err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, nil
+	}
+	sort.Slice(list.Items, func(i, j int) bool {
+		return list.Items[i].CreationTimestamp.Before(&list.Items[j].CreationTimestamp)
+	})
+	return &list.Items[0], nil
+}
+
+// proxyFor returns the MinecraftProxy which selects the custom resource, or nil
+// when none does. The first one by name is returned when several do.
+func (g *Gateway) proxyFor(ctx context.Context,
+	minecraft *cachev1alpha1.Minecraft) (*cachev1alpha1.MinecraftProxy, error) {
+	list := &cachev1alpha1.MinecraftProxyList{}
+	if err := g.Client.List(ctx, list, client.InNamespace(minecraft.Namespace)); err != nil {
+		return nil, err
+	}
+	sort.Slice(list.Items, func(i, j int) bool { return list.Items[i].Name < list.Items[j].Name })
+	for i := range list.Items {
+		proxy := &list.Items[i]
+		selector, err := metav1.LabelSelectorAsSelector(&proxy.Spec.Selector)
+		if err != nil || selector.Empty() || proxy.GetDeletionTimestamp() != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(minecraft.Labels)) {
+			return proxy, nil
+		}
+	}
+	return nil, nil
+}
+
+// joinThroughProxy returns the reason a player is refused by a server behind
+// the proxy
+func joinThroughProxy(proxy *cachev1alpha1.MinecraftProxy) string {
+	if proxy.Status.Address == "" {
+		return fmt.Sprintf("The server is behind the proxy %s, join through it", proxy.Name)
+	}
+	return fmt.Sprintf("The server is behind a proxy, join through %s", proxy.Status.Address)
+}
+
+// status answers the status request, and the ping which follows it, on behalf
+// of a server which is sleeping or starting
+func (g *Gateway) status(conn net.Conn, r *bufio.Reader, handshake *slp.Handshake,
+	minecraft *cachev1alpha1.Minecraft) error {
+	id, _, err := slp.ReadPacket(r)
+	if err != nil {
+		return fmt.Errorf("reading status request: %w", err)
+	}
+	if id != slp.PacketStatus {
+		return fmt.Errorf("unexpected packet 0x%02x in place of the status request", id)
+	}
+
+	name, motd := "Starting", "The server is starting, please wait"
+	if minecraft.Status.Phase == cachev1alpha1.MinecraftPhaseSleeping {
+		name, motd = "Sleeping", "The server is sleeping, join to wake it up"
+	}
+	maxPlayers := minecraft.Status.MaxPlayers
+	if maxPlayers == 0 && minecraft.Spec.Config.MaxPlayers != nil {
+		maxPlayers = *minecraft.Spec.Config.MaxPlayers
+	}
+	// The protocol of the client is answered, so it does not flag the server
+	// as incompatible before it runs
+	if err := slp.WriteStatus(conn, &slp.Status{
+		Version:     slp.Version{Name: name, Protocol: handshake.ProtocolVersion},
+		Players:     slp.Players{Max: maxPlayers},
+		Description: slp.Description{Text: motd},
+	}); err != nil {
+		return err
+	}
+
+	id, data, err := slp.ReadPacket(r)
+	if errors.Is(err, io.EOF) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading ping: %w", err)
+	}
+	if id != slp.PacketPing {
+		return fmt.Errorf("unexpected packet 0x%02x in place of the ping", id)
+	}
+	return slp.WritePacket(conn, slp.PacketPing, data)
+}
+
+// wake asks the operator to start the sleeping servers of the custom resource
+func (g *Gateway) wake(ctx context.Context, minecraft *cachev1alpha1.Minecraft) error {
+	if minecraft.Status.Phase != cachev1alpha1.MinecraftPhaseSleeping {
+		return nil
+	}
+	if _, found := minecraft.Annotations[cachev1alpha1.WakeAnnotation]; found {
+		return nil
+	}
+
+	g.Log.Info("Waking up Minecraft", "Minecraft.Namespace", minecraft.Namespace, "Minecraft.Name", minecraft.Name)
+	patch := client.MergeFrom(minecraft.DeepCopy())
+	if minecraft.Annotations == nil {
+		minecraft.Annotations = map[string]string{}
+	}
+	minecraft.Annotations[cachev1alpha1.WakeAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	return g.Client.Patch(ctx, minecraft, patch)
+}
+
+// forward connects the player to the Service of the running servers. The
+// handshake which was already read is sent first, then the bytes are copied
+// both ways until either side closes the connection.
+func (g *Gateway) forward(ctx context.Context, conn net.Conn, r *bufio.Reader, handshake []byte,
+	minecraft *cachev1alpha1.Minecraft) error {
+	dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+	defer cancel()
+	server, err := g.dial()(dialCtx, "tcp", serverAddress(minecraft))
+	if err != nil {
+		return fmt.Errorf("connecting to %s: %w", minecraft.Name, err)
+	}
+	defer server.Close() //nolint:errcheck
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return err
+	}
+	if err := slp.WritePacket(server, slp.PacketHandshake, handshake); err != nil {
+		return err
+	}
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(server, r)
+		closeWrite(server)
+		done <- err
+	}()
+	go func() {
+		_, err := io.Copy(conn, server)
+		closeWrite(conn)
+		done <- err
+	}()
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dial returns Dial, or the DialContext of a net.Dialer when it is not set
+func (g *Gateway) dial() DialFunc {
+	if g.Dial != nil {
+		return g.Dial
+	}
+	var d net.Dialer
+	return d.DialContext
+}
+
+// serverAddress returns the address of the Service of the custom resource
+// inside the cluster
+func serverAddress(minecraft *cachev1alpha1.Minecraft) string {
+	port := minecraft.Spec.Service.Port
+	if port == 0 {
+		port = cachev1alpha1.JavaGamePort
+	}
+	host := fmt.Sprintf("%s.%s.svc", minecraft.Name, minecraft.Namespace)
+	return net.JoinHostPort(host, strconv.Itoa(int(port)))
+}
+
+// normalizeHostname returns the hostname of the address of a handshake. Forge
+// clients append their marker after a NUL byte, and a fully qualified name may
+// end with a dot.
+func normalizeHostname(address string) string {
+	hostname, _, _ := strings.Cut(address, "\x00")
+	return strings.ToLower(strings.TrimSuffix(hostname, "."))
+}
+
+// closeWrite signals the end of the stream to the other side of conn
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = c.CloseWrite()
+	}
+}
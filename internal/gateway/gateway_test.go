@@ -0,0 +1,198 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+	"github.com/example/minecraft-operator/internal/slp"
+)
+
+var _ = Describe("Gateway", func() {
+	var (
+		ctx      context.Context
+		cancel   context.CancelFunc
+		c        client.Client
+		gateway  *Gateway
+		listener net.Listener
+	)
+
+	// minecraftWithPhase returns a custom resource reachable as mc.example.com
+	minecraftWithPhase := func(phase cachev1alpha1.MinecraftPhase) *cachev1alpha1.Minecraft {
+		return &cachev1alpha1.Minecraft{
+			ObjectMeta: metav1.ObjectMeta{Name: "survival", Namespace: "games"},
+			Spec: cachev1alpha1.MinecraftSpec{
+				AutoPause: &cachev1alpha1.AutoPauseSpec{Hostnames: []string{"MC.example.com"}},
+			},
+			Status: cachev1alpha1.MinecraftStatus{Phase: phase, MaxPlayers: 20},
+		}
+	}
+
+	// start serves the gateway in front of the given custom resource, next to
+	// the other objects
+	start := func(minecraft *cachev1alpha1.Minecraft, objs ...client.Object) {
+		scheme := runtime.NewScheme()
+		Expect(cachev1alpha1.AddToScheme(scheme)).To(Succeed())
+		c = fake.NewClientBuilder().
+			WithScheme(scheme).
+			WithObjects(minecraft).
+			WithObjects(objs...).
+			WithStatusSubresource(minecraft).
+			WithIndex(&cachev1alpha1.Minecraft{}, HostnameIndex, HostnamesForMinecraft).
+			Build()
+
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		gateway = &Gateway{Client: c, Log: GinkgoLogr}
+		go func() {
+			defer GinkgoRecover()
+			Expect(gateway.Serve(ctx, listener)).To(Succeed())
+		}()
+	}
+
+	// connect sends the handshake of a client connecting to mc.example.com
+	connect := func(nextState int32) (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(conn.SetDeadline(time.Now().Add(5 * time.Second))).To(Succeed())
+		handshake := &slp.Handshake{
+			ProtocolVersion: 767,
+			ServerAddress:   "mc.example.com.\x00FML\x00",
+			ServerPort:      25565,
+			NextState:       nextState,
+		}
+		Expect(slp.WritePacket(conn, slp.PacketHandshake, handshake.Marshal())).To(Succeed())
+		return conn, bufio.NewReader(conn)
+	}
+
+	BeforeEach(func() {
+		ctx, cancel = context.WithCancel(context.Background())
+	})
+
+	AfterEach(func() {
+		cancel()
+	})
+
+	It("should answer the status request of a sleeping server", func() {
+		start(minecraftWithPhase(cachev1alpha1.MinecraftPhaseSleeping))
+		conn, r := connect(slp.StateStatus)
+		defer conn.Close() //nolint:errcheck
+
+		Expect(slp.WritePacket(conn, slp.PacketStatus, nil)).To(Succeed())
+		id, data, err := slp.ReadPacket(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(slp.PacketStatus)))
+		Expect(string(data)).To(ContainSubstring("The server is sleeping"))
+		Expect(string(data)).To(ContainSubstring(`"protocol":767`))
+
+		By("Answering the ping which follows")
+		Expect(slp.WritePacket(conn, slp.PacketPing, []byte{1, 2, 3, 4, 5, 6, 7, 8})).To(Succeed())
+		id, data, err = slp.ReadPacket(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(slp.PacketPing)))
+		Expect(data).To(Equal([]byte{1, 2, 3, 4, 5, 6, 7, 8}))
+	})
+
+	It("should wake up a sleeping server when a player joins", func() {
+		start(minecraftWithPhase(cachev1alpha1.MinecraftPhaseSleeping))
+		conn, r := connect(slp.StateLogin)
+		defer conn.Close() //nolint:errcheck
+
+		id, data, err := slp.ReadPacket(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(slp.PacketDisconnect)))
+		Expect(string(data)).To(ContainSubstring("The server is starting"))
+
+		minecraft := &cachev1alpha1.Minecraft{}
+		Expect(c.Get(ctx, types.NamespacedName{Name: "survival", Namespace: "games"}, minecraft)).To(Succeed())
+		Expect(minecraft.Annotations).To(HaveKey(cachev1alpha1.WakeAnnotation))
+	})
+
+	It("should forward the players of a running server", func() {
+		start(minecraftWithPhase(cachev1alpha1.MinecraftPhaseRunning))
+
+		By("Starting a server which answers the status request")
+		server, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer server.Close() //nolint:errcheck
+		var dialed string
+		gateway.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			dialed = address
+			var d net.Dialer
+			return d.DialContext(ctx, network, server.Addr().String())
+		}
+		go func() {
+			defer GinkgoRecover()
+			conn, err := server.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close() //nolint:errcheck
+			r := bufio.NewReader(conn)
+			id, _, err := slp.ReadPacket(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(int32(slp.PacketHandshake)))
+			_, _, err = slp.ReadPacket(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(slp.WriteStatus(conn, &slp.Status{Description: slp.Description{Text: "Running"}})).To(Succeed())
+		}()
+
+		conn, r := connect(slp.StateStatus)
+		defer conn.Close() //nolint:errcheck
+		Expect(slp.WritePacket(conn, slp.PacketStatus, nil)).To(Succeed())
+		_, data, err := slp.ReadPacket(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(bytes.Contains(data, []byte("Running"))).To(BeTrue())
+		Expect(dialed).To(Equal("survival.games.svc:25565"))
+	})
+
+	It("should not let the players join a server behind a proxy around it", func() {
+		minecraft := minecraftWithPhase(cachev1alpha1.MinecraftPhaseRunning)
+		minecraft.Labels = map[string]string{"network": "lobby"}
+		start(minecraft, &cachev1alpha1.MinecraftProxy{
+			ObjectMeta: metav1.ObjectMeta{Name: "lobby", Namespace: "games"},
+			Spec: cachev1alpha1.MinecraftProxySpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"network": "lobby"}},
+			},
+			Status: cachev1alpha1.MinecraftProxyStatus{Address: "203.0.113.7:25565"},
+		})
+		gateway.Dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			defer GinkgoRecover()
+			Fail("the gateway connected to " + address)
+			return nil, nil
+		}
+
+		conn, r := connect(slp.StateLogin)
+		defer conn.Close() //nolint:errcheck
+		id, data, err := slp.ReadPacket(r)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(slp.PacketDisconnect)))
+		Expect(string(data)).To(ContainSubstring("join through 203.0.113.7:25565"))
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package mojang resolves the usernames of Minecraft: Java Edition players to
+// the UUIDs the servers identify them with.
+// More info: https://wiki.vg/Mojang_API
+package mojang
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" //nolint:gosec
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// DefaultEndpoint is the endpoint of the Mojang API resolving usernames in bulk
+	DefaultEndpoint = "https://api.mojang.com/profiles/minecraft"
+
+	// batchSize is the most usernames the endpoint resolves in one request
+	batchSize = 10
+)
+
+// Resolver resolves usernames with the Mojang API
+type Resolver struct {
+	// Endpoint is the URL usernames are posted to, DefaultEndpoint when not set
+	Endpoint string
+	// Client sends the requests, http.DefaultClient when not set
+	Client *http.Client
+}
+
+// profile is a player as returned by the endpoint
+type profile struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Resolve returns the UUIDs of the players with the usernames, keyed by the
+// lowercased username. Usernames no account has are left out.
+func (r *Resolver) Resolve(ctx context.Context, names []string) (map[string]string, error) {
+	uuids := make(map[string]string, len(names))
+	for start := 0; start < len(names); start += batchSize {
+		end := min(start+batchSize, len(names))
+		profiles, err := r.lookup(ctx, names[start:end])
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range profiles {
+			uuid, err := dashed(p.ID)
+			if err != nil {
+				return nil, err
+			}
+			uuids[strings.ToLower(p.Name)] = uuid
+		}
+	}
+	return uuids, nil
+}
+
+// lookup posts one batch of usernames to the endpoint
+func (r *Resolver) lookup(ctx context.Context, names []string) ([]profile, error) {
+	body, err := json.Marshal(names)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := r.Endpoint
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("mojang: resolving usernames: %s", resp.Status)
+	}
+
+	var profiles []profile
+	if err := json.NewDecoder(resp.Body).Decode(&profiles); err != nil {
+		return nil, fmt.Errorf("mojang: decoding profiles: %w", err)
+	}
+	return profiles, nil
+}
+
+// Resolve resolves usernames with the default Resolver
+func Resolve(ctx context.Context, names []string) (map[string]string, error) {
+	return (&Resolver{}).Resolve(ctx, names)
+}
+
+// OfflineUUID returns the UUID a server in offline mode identifies the player
+// with, derived from the username alone
+func OfflineUUID(name string) string {
+	sum := md5.Sum([]byte("OfflinePlayer:" + name)) //nolint:gosec
+	sum[6] = sum[6]&0x0f | 0x30
+	sum[8] = sum[8]&0x3f | 0x80
+	return format(sum[:])
+}
+
+// dashed returns the UUID of the endpoint, which has no dashes, in the form
+// the servers write it
+func dashed(id string) (string, error) {
+	if len(id) != 32 {
+		return "", fmt.Errorf("mojang: malformed UUID %q", id)
+	}
+	return strings.Join([]string{id[0:8], id[8:12], id[12:16], id[16:20], id[20:32]}, "-"), nil
+}
+
+// format returns the 16 bytes of a UUID in its dashed hexadecimal form
+func format(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
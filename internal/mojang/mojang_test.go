@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mojang
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mojang", func() {
+	It("should resolve the usernames in batches", func() {
+		var batches [][]string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			defer GinkgoRecover()
+			Expect(req.Method).To(Equal(http.MethodPost))
+			var names []string
+			Expect(json.NewDecoder(req.Body).Decode(&names)).To(Succeed())
+			batches = append(batches, names)
+
+			var profiles []profile
+			for i, name := range names {
+				// The endpoint leaves out the usernames no account has
+				if name == "Nobody" {
+					continue
+				}
+				profiles = append(profiles, profile{ID: fmt.Sprintf("%032x", len(batches)*100+i), Name: name})
+			}
+			Expect(json.NewEncoder(w).Encode(profiles)).To(Succeed())
+		}))
+		defer server.Close()
+
+		names := []string{"Nobody"}
+		for i := 0; i < 11; i++ {
+			names = append(names, fmt.Sprintf("Player%d", i))
+		}
+		resolver := &Resolver{Endpoint: server.URL}
+		uuids, err := resolver.Resolve(context.Background(), names)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(batches).To(HaveLen(2))
+		Expect(batches[0]).To(HaveLen(10))
+		Expect(uuids).To(HaveLen(11))
+		Expect(uuids).NotTo(HaveKey("nobody"))
+		Expect(uuids).To(HaveKeyWithValue("player0", "00000000-0000-0000-0000-000000000065"))
+	})
+
+	It("should fail when the endpoint does", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.WriteHeader(http.StatusTooManyRequests)
+		}))
+		defer server.Close()
+
+		resolver := &Resolver{Endpoint: server.URL}
+		_, err := resolver.Resolve(context.Background(), []string{"Notch"})
+		Expect(err).To(MatchError(ContainSubstring("429")))
+	})
+
+	It("should derive the UUIDs of offline mode servers", func() {
+		Expect(OfflineUUID("Notch")).To(Equal("b50ad385-829d-3141-a216-7e7d7539ba7f"))
+	})
+})
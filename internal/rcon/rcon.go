@@ -0,0 +1,201 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rcon implements a client of the Source RCON protocol, which Minecraft:
+// Java Edition servers offer to run console commands remotely.
+// More info: https://wiki.vg/RCON
+package rcon
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+const (
+	// TypeAuth is the type of the packet authenticating the client
+	TypeAuth int32 = 3
+	// TypeAuthResponse is the type of the packet answering the authentication
+	TypeAuthResponse int32 = 2
+	// TypeCommand is the type of the packet running a command
+	TypeCommand int32 = 2
+	// TypeResponse is the type of the packet carrying the output of a command
+	TypeResponse int32 = 0
+
+	// maxBodyLength is the largest body accepted, Minecraft servers split
+	// longer command outputs and send at most 4096 bytes per packet
+	maxBodyLength = 4096
+
+	// headerLength is the length of the id and type fields and of the two
+	// null bytes terminating the body
+	headerLength = 10
+)
+
+// ErrAuthFailed is returned when the server rejects the password
+var ErrAuthFailed = errors.New("rcon: authentication failed")
+
+// Client is a connection to the RCON port of a server. Commands of a client
+// run one after the other.
+type Client struct {
+	mu     sync.Mutex
+	conn   net.Conn
+	lastID int32
+}
+
+// Dial connects to the server at address, a host and port, and authenticates
+// with password. The deadline of ctx bounds the connection and authentication.
+func Dial(ctx context.Context, address, password string) (*Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Client{conn: conn}
+	if err := c.auth(ctx, password); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// Close closes the connection to the server
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Command runs command on the server and returns its output. The deadline of
+// ctx bounds the exchange.
+//
+// The server splits long outputs over several packets without marking the
+// last one. An empty packet of the response type follows the command, which
+// the server answers once it sent the whole output.
+func (c *Client) Command(ctx context.Context, command string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setDeadline(ctx); err != nil {
+		return "", err
+	}
+	id := c.nextID()
+	if err := WritePacket(c.conn, id, TypeCommand, command); err != nil {
+		return "", fmt.Errorf("rcon: sending command: %w", err)
+	}
+	endID := c.nextID()
+	if err := WritePacket(c.conn, endID, TypeResponse, ""); err != nil {
+		return "", fmt.Errorf("rcon: sending command: %w", err)
+	}
+
+	var output strings.Builder
+	for {
+		responseID, _, body, err := ReadPacket(c.conn)
+		if err != nil {
+			return "", fmt.Errorf("rcon: reading response: %w", err)
+		}
+		switch responseID {
+		case id:
+			output.WriteString(body)
+		case endID:
+			return output.String(), nil
+		default:
+			return "", fmt.Errorf("rcon: response to request %d in place of %d", responseID, id)
+		}
+	}
+}
+
+// auth sends the password and waits for the server to accept it
+func (c *Client) auth(ctx context.Context, password string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.setDeadline(ctx); err != nil {
+		return err
+	}
+	id := c.nextID()
+	if err := WritePacket(c.conn, id, TypeAuth, password); err != nil {
+		return fmt.Errorf("rcon: sending password: %w", err)
+	}
+
+	// Some servers send an empty response ahead of the authentication response
+	for {
+		responseID, packetType, _, err := ReadPacket(c.conn)
+		if err != nil {
+			return fmt.Errorf("rcon: reading authentication response: %w", err)
+		}
+		if packetType != TypeAuthResponse {
+			continue
+		}
+		if responseID == -1 {
+			return ErrAuthFailed
+		}
+		if responseID != id {
+			return fmt.Errorf("rcon: authentication response to request %d in place of %d", responseID, id)
+		}
+		return nil
+	}
+}
+
+// setDeadline applies the deadline of ctx to the connection
+func (c *Client) setDeadline(ctx context.Context) error {
+	deadline, _ := ctx.Deadline()
+	return c.conn.SetDeadline(deadline)
+}
+
+// nextID returns the id of the next request, never -1 which marks failures
+func (c *Client) nextID() int32 {
+	c.lastID++
+	if c.lastID < 0 {
+		c.lastID = 1
+	}
+	return c.lastID
+}
+
+// WritePacket writes a packet made of its length, id, type and body
+func WritePacket(w io.Writer, id, packetType int32, body string) error {
+	packet := make([]byte, 0, 4+headerLength+len(body))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(headerLength+len(body)))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(id))
+	packet = binary.LittleEndian.AppendUint32(packet, uint32(packetType))
+	packet = append(packet, body...)
+	packet = append(packet, 0, 0)
+	_, err := w.Write(packet)
+	return err
+}
+
+// ReadPacket reads a packet and returns its id, type and body
+func ReadPacket(r io.Reader) (int32, int32, string, error) {
+	var length int32
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return 0, 0, "", err
+	}
+	if length < headerLength || length > headerLength+maxBodyLength {
+		return 0, 0, "", fmt.Errorf("invalid packet length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return 0, 0, "", err
+	}
+	id := int32(binary.LittleEndian.Uint32(packet[0:4]))
+	packetType := int32(binary.LittleEndian.Uint32(packet[4:8]))
+	body := packet[8 : length-2]
+	return id, packetType, string(body), nil
+}
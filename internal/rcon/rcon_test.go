@@ -0,0 +1,143 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rcon
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// helpOutput is the output of the help command, longer than a packet
+var helpOutput = strings.Repeat("/command <argument>\n", 500)
+
+// serve answers the connections of listener like a server with the given
+// password, echoing the commands it receives. Like Minecraft servers, it
+// splits long outputs over several packets and answers the packets of an
+// unknown type.
+func serve(listener net.Listener, password string) {
+	defer GinkgoRecover()
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close() //nolint:errcheck
+
+	for {
+		id, packetType, body, err := ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		switch packetType {
+		case TypeAuth:
+			if body != password {
+				id = -1
+			}
+			Expect(WritePacket(conn, id, TypeAuthResponse, "")).To(Succeed())
+		case TypeCommand:
+			output := "ran " + body
+			if body == "help" {
+				output = helpOutput
+			}
+			for len(output) > maxBodyLength {
+				Expect(WritePacket(conn, id, TypeResponse, output[:maxBodyLength])).To(Succeed())
+				output = output[maxBodyLength:]
+			}
+			Expect(WritePacket(conn, id, TypeResponse, output)).To(Succeed())
+		default:
+			Expect(WritePacket(conn, id, TypeResponse, fmt.Sprintf("Unknown request %x", packetType))).To(Succeed())
+		}
+	}
+}
+
+var _ = Describe("RCON", func() {
+	var listener net.Listener
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = listener.Close()
+	})
+
+	It("should round-trip packets", func() {
+		var b bytes.Buffer
+		Expect(WritePacket(&b, 7, TypeCommand, "save-all")).To(Succeed())
+		Expect(b.Len()).To(Equal(4 + 10 + len("save-all")))
+
+		id, packetType, body, err := ReadPacket(&b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(7)))
+		Expect(packetType).To(Equal(TypeCommand))
+		Expect(body).To(Equal("save-all"))
+	})
+
+	It("should authenticate and run commands", func() {
+		go serve(listener, "secret")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		client, err := Dial(ctx, listener.Addr().String(), "secret")
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close() //nolint:errcheck
+
+		output, err := client.Command(ctx, "save-all")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(Equal("ran save-all"))
+
+		output, err = client.Command(ctx, "list")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(Equal("ran list"))
+	})
+
+	It("should join the output split over several packets", func() {
+		go serve(listener, "secret")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		client, err := Dial(ctx, listener.Addr().String(), "secret")
+		Expect(err).NotTo(HaveOccurred())
+		defer client.Close() //nolint:errcheck
+
+		output, err := client.Command(ctx, "help")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(len(output)).To(BeNumerically(">", 2*maxBodyLength))
+		Expect(output).To(Equal(helpOutput))
+
+		output, err = client.Command(ctx, "list")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(output).To(Equal("ran list"))
+	})
+
+	It("should fail with a wrong password", func() {
+		go serve(listener, "secret")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := Dial(ctx, listener.Addr().String(), "wrong")
+		Expect(err).To(MatchError(ErrAuthFailed))
+	})
+})
@@ -0,0 +1,335 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package slp implements the Server List Ping of Minecraft: Java Edition, the
+// handshake and status request a client sends to list a server in its
+// multiplayer menu.
+// More info: https://wiki.vg/Server_List_Ping
+package slp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	// StateStatus is the next state of a handshake asking for the server status
+	StateStatus = 1
+	// StateLogin is the next state of a handshake of a player joining the server
+	StateLogin = 2
+
+	// PacketHandshake is the id of the handshake packet
+	PacketHandshake = 0x00
+	// PacketStatus is the id of the status request and response packets
+	PacketStatus = 0x00
+	// PacketPing is the id of the ping request and pong response packets
+	PacketPing = 0x01
+	// PacketDisconnect is the id of the packet refusing a login
+	PacketDisconnect = 0x00
+
+	// maxPacketLength bounds the packets read from the network, the status
+	// response including a server icon is well below
+	maxPacketLength = 1 << 21
+)
+
+// Reader is read by the decoding functions, bufio.Reader and bytes.Reader
+// implement it
+type Reader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Handshake is the first packet a client sends after connecting
+type Handshake struct {
+	// ProtocolVersion is the protocol version of the client
+	ProtocolVersion int32
+	// ServerAddress is the hostname or address the client connected to
+	ServerAddress string
+	// ServerPort is the port the client connected to
+	ServerPort uint16
+	// NextState is StateStatus or StateLogin
+	NextState int32
+}
+
+// Marshal encodes the handshake into the data of a packet
+func (h *Handshake) Marshal() []byte {
+	b := AppendVarInt(nil, h.ProtocolVersion)
+	b = AppendString(b, h.ServerAddress)
+	b = binary.BigEndian.AppendUint16(b, h.ServerPort)
+	return AppendVarInt(b, h.NextState)
+}
+
+// ParseHandshake decodes the data of a handshake packet
+func ParseHandshake(data []byte) (*Handshake, error) {
+	r := bytes.NewReader(data)
+	h := &Handshake{}
+	var err error
+	if h.ProtocolVersion, err = ReadVarInt(r); err != nil {
+		return nil, fmt.Errorf("reading protocol version: %w", err)
+	}
+	if h.ServerAddress, err = ReadString(r); err != nil {
+		return nil, fmt.Errorf("reading server address: %w", err)
+	}
+	var port [2]byte
+	if _, err = io.ReadFull(r, port[:]); err != nil {
+		return nil, fmt.Errorf("reading server port: %w", err)
+	}
+	h.ServerPort = binary.BigEndian.Uint16(port[:])
+	if h.NextState, err = ReadVarInt(r); err != nil {
+		return nil, fmt.Errorf("reading next state: %w", err)
+	}
+	return h, nil
+}
+
+// Status is the status response of a server
+type Status struct {
+	Version     Version     `json:"version"`
+	Players     Players     `json:"players"`
+	Description Description `json:"description"`
+	Favicon     string      `json:"favicon,omitempty"`
+}
+
+// Version is the version of Minecraft the server runs
+type Version struct {
+	Name     string `json:"name"`
+	Protocol int32  `json:"protocol"`
+}
+
+// Players counts the players on the server
+type Players struct {
+	Max    int32    `json:"max"`
+	Online int32    `json:"online"`
+	Sample []Player `json:"sample,omitempty"`
+}
+
+// Player is a player shown in the sample of the status response
+type Player struct {
+	Name string `json:"name"`
+	ID   string `json:"id"`
+}
+
+// Description is the message of the day of the server. Servers send either a
+// plain string or a chat component, both are flattened into plain text.
+type Description struct {
+	Text string
+}
+
+// MarshalJSON encodes the description as a chat component
+func (d Description) MarshalJSON() ([]byte, error) {
+	return json.Marshal(chatComponent{Text: d.Text})
+}
+
+// UnmarshalJSON decodes a plain string or a chat component
+func (d *Description) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		d.Text = text
+		return nil
+	}
+	var component chatComponent
+	if err := json.Unmarshal(data, &component); err != nil {
+		return err
+	}
+	d.Text = component.String()
+	return nil
+}
+
+// chatComponent is the subset of a chat component holding its text
+type chatComponent struct {
+	Text  string          `json:"text"`
+	Extra []chatComponent `json:"extra,omitempty"`
+}
+
+// String concatenates the text of the component and its children
+func (c chatComponent) String() string {
+	var b strings.Builder
+	b.WriteString(c.Text)
+	for _, e := range c.Extra {
+		b.WriteString(e.String())
+	}
+	return b.String()
+}
+
+// Ping connects to the server at address, a host and port, and returns its
+// status. The deadline of ctx bounds the whole exchange.
+func Ping(ctx context.Context, address string) (*Status, error) {
+	host, portValue, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portValue, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q: %w", portValue, err)
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() //nolint:errcheck
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+	}
+
+	// Servers answer the status request of any protocol version, -1 is what
+	// clients send when they do not know the version of the server
+	handshake := &Handshake{
+		ProtocolVersion: -1,
+		ServerAddress:   host,
+		ServerPort:      uint16(port),
+		NextState:       StateStatus,
+	}
+	if err := WritePacket(conn, PacketHandshake, handshake.Marshal()); err != nil {
+		return nil, fmt.Errorf("sending handshake: %w", err)
+	}
+	if err := WritePacket(conn, PacketStatus, nil); err != nil {
+		return nil, fmt.Errorf("sending status request: %w", err)
+	}
+
+	id, data, err := ReadPacket(bufio.NewReader(conn))
+	if err != nil {
+		return nil, fmt.Errorf("reading status response: %w", err)
+	}
+	if id != PacketStatus {
+		return nil, fmt.Errorf("unexpected packet 0x%02x in place of the status response", id)
+	}
+	response, err := ReadString(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("reading status response: %w", err)
+	}
+
+	status := &Status{}
+	if err := json.Unmarshal([]byte(response), status); err != nil {
+		return nil, fmt.Errorf("decoding status response: %w", err)
+	}
+	return status, nil
+}
+
+// WriteStatus writes the status response packet for status
+func WriteStatus(w io.Writer, status *Status) error {
+	response, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	return WritePacket(w, PacketStatus, AppendString(nil, string(response)))
+}
+
+// WriteDisconnect writes the packet refusing the login of a player with the
+// given reason, which the client displays
+func WriteDisconnect(w io.Writer, reason string) error {
+	message, err := json.Marshal(Description{Text: reason})
+	if err != nil {
+		return err
+	}
+	return WritePacket(w, PacketDisconnect, AppendString(nil, string(message)))
+}
+
+// WritePacket writes a packet made of its length, id and data
+func WritePacket(w io.Writer, id int32, data []byte) error {
+	body := AppendVarInt(nil, id)
+	body = append(body, data...)
+	packet := AppendVarInt(nil, int32(len(body)))
+	packet = append(packet, body...)
+	_, err := w.Write(packet)
+	return err
+}
+
+// ReadPacket reads a packet and returns its id and data
+func ReadPacket(r Reader) (int32, []byte, error) {
+	length, err := ReadVarInt(r)
+	if err != nil {
+		return 0, nil, err
+	}
+	if length <= 0 || length > maxPacketLength {
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, nil, err
+	}
+
+	br := bytes.NewReader(body)
+	id, err := ReadVarInt(br)
+	if err != nil {
+		return 0, nil, err
+	}
+	return id, body[varIntSize(id):], nil
+}
+
+// AppendVarInt appends the variable-length encoding of v to b
+func AppendVarInt(b []byte, v int32) []byte {
+	u := uint32(v)
+	for u >= 0x80 {
+		b = append(b, byte(u)|0x80)
+		u >>= 7
+	}
+	return append(b, byte(u))
+}
+
+// ReadVarInt reads a variable-length encoded integer
+func ReadVarInt(r io.ByteReader) (int32, error) {
+	var u uint32
+	for i := 0; i < 5; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		u |= uint32(b&0x7f) << (7 * i)
+		if b&0x80 == 0 {
+			return int32(u), nil
+		}
+	}
+	return 0, errors.New("varint is too long")
+}
+
+// AppendString appends s prefixed by its length to b
+func AppendString(b []byte, s string) []byte {
+	b = AppendVarInt(b, int32(len(s)))
+	return append(b, s...)
+}
+
+// ReadString reads a string prefixed by its length
+func ReadString(r Reader) (string, error) {
+	length, err := ReadVarInt(r)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 || length > maxPacketLength {
+		return "", fmt.Errorf("invalid string length %d", length)
+	}
+	s := make([]byte, length)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
+
+// varIntSize returns the number of bytes of the encoding of v
+func varIntSize(v int32) int {
+	return len(AppendVarInt(nil, v))
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Server List Ping", func() {
+	It("should round-trip variable-length integers", func() {
+		for _, v := range []int32{0, 1, 127, 128, 25565, 2147483647, -1} {
+			value, err := ReadVarInt(bytes.NewReader(AppendVarInt(nil, v)))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(v))
+		}
+		Expect(AppendVarInt(nil, -1)).To(HaveLen(5))
+	})
+
+	It("should round-trip the handshake", func() {
+		handshake := &Handshake{
+			ProtocolVersion: 767,
+			ServerAddress:   "mc.example.com",
+			ServerPort:      25565,
+			NextState:       StateLogin,
+		}
+		parsed, err := ParseHandshake(handshake.Marshal())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(parsed).To(Equal(handshake))
+	})
+
+	It("should flatten chat components of the description", func() {
+		status := &Status{}
+		Expect(json.Unmarshal([]byte(
+			`{"description":{"text":"A ","extra":[{"text":"Minecraft"},{"text":" Server"}]}}`), status)).To(Succeed())
+		Expect(status.Description.Text).To(Equal("A Minecraft Server"))
+
+		Expect(json.Unmarshal([]byte(`{"description":"Plain"}`), status)).To(Succeed())
+		Expect(status.Description.Text).To(Equal("Plain"))
+	})
+
+	It("should write the reason of a disconnect as a chat component", func() {
+		var b bytes.Buffer
+		Expect(WriteDisconnect(&b, "Come back later")).To(Succeed())
+		id, data, err := ReadPacket(&b)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(id).To(Equal(int32(PacketDisconnect)))
+		reason, err := ReadString(bytes.NewReader(data))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reason).To(MatchJSON(`{"text":"Come back later"}`))
+	})
+
+	It("should ping a server for its status", func() {
+		By("Starting a server which answers the status request")
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		defer listener.Close() //nolint:errcheck
+
+		handshakes := make(chan *Handshake, 1)
+		go func() {
+			defer GinkgoRecover()
+			conn, err := listener.Accept()
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close() //nolint:errcheck
+			r := bufio.NewReader(conn)
+
+			id, data, err := ReadPacket(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(int32(PacketHandshake)))
+			handshake, err := ParseHandshake(data)
+			Expect(err).NotTo(HaveOccurred())
+			handshakes <- handshake
+
+			id, _, err = ReadPacket(r)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(id).To(Equal(int32(PacketStatus)))
+
+			Expect(WriteStatus(conn, &Status{
+				Version:     Version{Name: "1.21", Protocol: 767},
+				Players:     Players{Max: 20, Online: 3},
+				Description: Description{Text: "A Minecraft Server"},
+			})).To(Succeed())
+		}()
+
+		By("Pinging the server")
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		status, err := Ping(ctx, listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(status.Version.Name).To(Equal("1.21"))
+		Expect(status.Players.Online).To(Equal(int32(3)))
+		Expect(status.Players.Max).To(Equal(int32(20)))
+		Expect(status.Description.Text).To(Equal("A Minecraft Server"))
+
+		By("Checking the handshake asked for the status")
+		var handshake *Handshake
+		Eventually(handshakes).Should(Receive(&handshake))
+		Expect(handshake.NextState).To(Equal(int32(StateStatus)))
+		Expect(handshake.ServerAddress).To(Equal("127.0.0.1"))
+		port := listener.Addr().(*net.TCPAddr).Port
+		Expect(int(handshake.ServerPort)).To(Equal(port))
+	})
+})
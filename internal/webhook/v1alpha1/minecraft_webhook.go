@@ -0,0 +1,471 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+// log is for logging in this package.
+var minecraftlog = logf.Log.WithName("minecraft-resource")
+
+const (
+	// defaultVersion is the version the servers run when the spec does not pin one
+	defaultVersion = "LATEST"
+	// defaultMaintenanceWindowDuration is how long a maintenance window stays
+	// open when the spec does not set it
+	defaultMaintenanceWindowDuration = time.Hour
+)
+
+// releaseVersion matches the release versions of Minecraft, e.g. 1.20.4
+var releaseVersion = regexp.MustCompile(`^\d+\.\d+(\.\d+)?$`)
+
+// minimumVersions are the oldest Minecraft versions the server types support
+var minimumVersions = map[cachev1alpha1.ServerType]string{
+	cachev1alpha1.ServerTypePaper:    "1.8.8",
+	cachev1alpha1.ServerTypePurpur:   "1.14.1",
+	cachev1alpha1.ServerTypeFabric:   "1.14",
+	cachev1alpha1.ServerTypeQuilt:    "1.14",
+	cachev1alpha1.ServerTypeNeoForge: "1.20.1",
+}
+
+// SetupMinecraftWebhookWithManager registers the webhook for Minecraft in the manager.
+func SetupMinecraftWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&cachev1alpha1.Minecraft{}).
+		WithValidator(&MinecraftCustomValidator{}).
+		WithDefaulter(&MinecraftCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-cache-example-com-v1alpha1-minecraft,mutating=true,failurePolicy=fail,sideEffects=None,groups=cache.example.com,resources=minecrafts,verbs=create;update,versions=v1alpha1,name=mminecraft-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// MinecraftCustomDefaulter sets default values on the Minecraft custom
+// resources when they are created or updated, so the spec shows what the
+// servers run.
+type MinecraftCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &MinecraftCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind Minecraft.
+func (d *MinecraftCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	minecraft, ok := obj.(*cachev1alpha1.Minecraft)
+	if !ok {
+		return fmt.Errorf("expected a Minecraft object but got %T", obj)
+	}
+	minecraftlog.Info("Defaulting for Minecraft", "name", minecraft.GetName())
+
+	spec := &minecraft.Spec
+	if spec.Edition == "" {
+		spec.Edition = cachev1alpha1.EditionJava
+	}
+	if spec.Type == "" {
+		spec.Type = cachev1alpha1.ServerTypeVanilla
+	}
+	if spec.Version == "" {
+		spec.Version = defaultVersion
+	}
+
+	if spec.Storage.Size.IsZero() {
+		spec.Storage.Size = resource.MustParse(cachev1alpha1.DefaultStorageSize)
+	}
+	if spec.Storage.AccessMode == "" {
+		spec.Storage.AccessMode = corev1.ReadWriteOnce
+	}
+	if spec.Storage.RetainPolicy == "" {
+		spec.Storage.RetainPolicy = cachev1alpha1.StorageRetainPolicyRetain
+	}
+
+	if jvm := spec.JVM; jvm != nil && spec.Edition == cachev1alpha1.EditionJava {
+		if jvm.HeapPercentage == 0 {
+			jvm.HeapPercentage = cachev1alpha1.DefaultHeapPercentage
+		}
+		// Size the container after the heap, rather than letting the JVM
+		// outgrow a container with no memory limit
+		if jvm.MaxHeap != nil && spec.Resources.Limits.Memory().IsZero() {
+			limit := memoryLimitForHeap(*jvm.MaxHeap, jvm.HeapPercentage)
+			if request := spec.Resources.Requests.Memory(); request.Cmp(limit) > 0 {
+				limit = *request
+			}
+			if spec.Resources.Limits == nil {
+				spec.Resources.Limits = corev1.ResourceList{}
+			}
+			spec.Resources.Limits[corev1.ResourceMemory] = limit
+		}
+	}
+
+	if spec.Service.Type == "" {
+		spec.Service.Type = corev1.ServiceTypeClusterIP
+	}
+	if spec.Service.Port == 0 {
+		spec.Service.Port = gamePortForMinecraft(minecraft)
+	}
+	for i := range spec.Service.ExtraPorts {
+		if spec.Service.ExtraPorts[i].Protocol == "" {
+			spec.Service.ExtraPorts[i].Protocol = corev1.ProtocolTCP
+		}
+	}
+
+	if maintenance := spec.Maintenance; maintenance != nil {
+		if maintenance.Policy == "" {
+			maintenance.Policy = cachev1alpha1.MaintenancePolicyImmediate
+		}
+		for i := range maintenance.Windows {
+			if maintenance.Windows[i].Duration.Duration == 0 {
+				maintenance.Windows[i].Duration.Duration = defaultMaintenanceWindowDuration
+			}
+		}
+	}
+	return nil
+}
+
+// +kubebuilder:webhook:path=/validate-cache-example-com-v1alpha1-minecraft,mutating=false,failurePolicy=fail,sideEffects=None,groups=cache.example.com,resources=minecrafts,verbs=create;update,versions=v1alpha1,name=vminecraft-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// MinecraftCustomValidator validates the Minecraft custom resources when they
+// are created or updated, beyond what the schema of the CRD can express.
+type MinecraftCustomValidator struct{}
+
+var _ webhook.CustomValidator = &MinecraftCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type Minecraft.
+func (v *MinecraftCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	minecraft, ok := obj.(*cachev1alpha1.Minecraft)
+	if !ok {
+		return nil, fmt.Errorf("expected a Minecraft object but got %T", obj)
+	}
+	minecraftlog.Info("Validation for Minecraft upon creation", "name", minecraft.GetName())
+
+	return nil, invalidMinecraft(minecraft, validateMinecraftSpec(minecraft))
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type Minecraft.
+func (v *MinecraftCustomValidator) ValidateUpdate(ctx context.Context,
+	oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	minecraft, ok := newObj.(*cachev1alpha1.Minecraft)
+	if !ok {
+		return nil, fmt.Errorf("expected a Minecraft object for the newObj but got %T", newObj)
+	}
+	old, ok := oldObj.(*cachev1alpha1.Minecraft)
+	if !ok {
+		return nil, fmt.Errorf("expected a Minecraft object for the oldObj but got %T", oldObj)
+	}
+	minecraftlog.Info("Validation for Minecraft upon update", "name", minecraft.GetName())
+
+	// Removing the finalizer of a deleted object, or updating its metadata, must
+	// not be refused because of a spec which the webhook would reject nowadays
+	if minecraft.GetDeletionTimestamp() != nil || equality.Semantic.DeepEqual(old.Spec, minecraft.Spec) {
+		return nil, nil
+	}
+
+	allErrs := validateMinecraftSpec(minecraft)
+	allErrs = append(allErrs, validateMinecraftUpdate(old, minecraft)...)
+	return nil, invalidMinecraft(minecraft, allErrs)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type Minecraft.
+func (v *MinecraftCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// invalidMinecraft returns the error rejecting the custom resource, or nil
+// when there is nothing to reject
+func invalidMinecraft(minecraft *cachev1alpha1.Minecraft, allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(
+		schema.GroupKind{Group: cachev1alpha1.GroupVersion.Group, Kind: "Minecraft"},
+		minecraft.Name, allErrs)
+}
+
+// validateMinecraftSpec validates the spec on its own
+func validateMinecraftSpec(minecraft *cachev1alpha1.Minecraft) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	allErrs = append(allErrs, validateVersion(minecraft, specPath.Child("version"))...)
+	allErrs = append(allErrs, validatePorts(minecraft, specPath.Child("service"))...)
+
+	allErrs = append(allErrs, validateResources(minecraft, specPath.Child("resources"))...)
+	allErrs = append(allErrs, validateJVM(minecraft, specPath.Child("jvm"))...)
+
+	if backup := minecraft.Spec.Backup; backup != nil {
+		if _, err := cron.ParseStandard(backup.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(specPath.Child("backup", "schedule"), backup.Schedule, err.Error()))
+		}
+		// The backup Jobs mount the world volumes next to the server pods
+		if minecraft.Spec.Storage.AccessMode == corev1.ReadWriteOncePod {
+			allErrs = append(allErrs, field.Forbidden(specPath.Child("backup"),
+				"backups can not mount a ReadWriteOncePod world volume while the servers run"))
+		}
+	}
+	if maintenance := minecraft.Spec.Maintenance; maintenance != nil {
+		windowsPath := specPath.Child("maintenance", "windows")
+		for i, w := range maintenance.Windows {
+			if _, err := cron.ParseStandard(w.Schedule); err != nil {
+				allErrs = append(allErrs, field.Invalid(windowsPath.Index(i).Child("schedule"), w.Schedule, err.Error()))
+			}
+			if w.Duration.Duration <= 0 {
+				allErrs = append(allErrs, field.Invalid(windowsPath.Index(i).Child("duration"), w.Duration.Duration.String(),
+					"must be positive"))
+			}
+		}
+	}
+	return allErrs
+}
+
+// validateVersion rejects the release versions the server type does not support
+func validateVersion(minecraft *cachev1alpha1.Minecraft, fldPath *field.Path) field.ErrorList {
+	version := minecraft.Spec.Version
+	minimum, found := minimumVersions[minecraft.Spec.Type]
+	if !found || !releaseVersion.MatchString(version) {
+		return nil
+	}
+	if cmp, ok := cachev1alpha1.CompareVersions(version, minimum); ok && cmp < 0 {
+		return field.ErrorList{field.Invalid(fldPath, version,
+			fmt.Sprintf("the %s type requires Minecraft %s or later", minecraft.Spec.Type, minimum))}
+	}
+	return nil
+}
+
+// validateResources rejects requests larger than the limits of the container,
+// which the pods would be refused for
+func validateResources(minecraft *cachev1alpha1.Minecraft, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	resources := minecraft.Spec.Resources
+
+	names := make([]string, 0, len(resources.Requests))
+	for name := range resources.Requests {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		request := resources.Requests[corev1.ResourceName(name)]
+		limit, found := resources.Limits[corev1.ResourceName(name)]
+		if found && request.Cmp(limit) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("requests").Key(name), request.String(),
+				fmt.Sprintf("must be less than or equal to the %s limit of %s", name, limit.String())))
+		}
+	}
+	return allErrs
+}
+
+// validateJVM rejects heap sizes the memory limit of the container can not
+// hold, which would get the servers killed once the heap fills up
+func validateJVM(minecraft *cachev1alpha1.Minecraft, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	jvm := minecraft.Spec.JVM
+	if jvm == nil {
+		return nil
+	}
+	limit := minecraft.Spec.Resources.Limits.Memory()
+
+	heaps := []struct {
+		name string
+		size *resource.Quantity
+	}{{"minHeap", jvm.MinHeap}, {"maxHeap", jvm.MaxHeap}}
+	for _, heap := range heaps {
+		switch {
+		case heap.size == nil:
+		case heap.size.Sign() <= 0:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(heap.name), heap.size.String(),
+				"must be greater than zero"))
+		case !limit.IsZero() && heap.size.Cmp(*limit) > 0:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(heap.name), heap.size.String(),
+				fmt.Sprintf("must not exceed the memory limit of the container of %s", limit.String())))
+		}
+	}
+	if len(allErrs) > 0 || jvm.MinHeap == nil {
+		return allErrs
+	}
+
+	switch {
+	case jvm.MaxHeap != nil:
+		if jvm.MinHeap.Cmp(*jvm.MaxHeap) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minHeap"), jvm.MinHeap.String(),
+				"must not exceed maxHeap"))
+		}
+	case !limit.IsZero():
+		percentage := int64(jvm.HeapPercentage)
+		if percentage == 0 {
+			percentage = cachev1alpha1.DefaultHeapPercentage
+		}
+		maxHeap := resource.NewQuantity(limit.Value()*percentage/100, resource.BinarySI)
+		if jvm.MinHeap.Cmp(*maxHeap) > 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minHeap"), jvm.MinHeap.String(),
+				fmt.Sprintf("must not exceed the maximum heap of %s derived from the memory limit", maxHeap.String())))
+		}
+	}
+	return allErrs
+}
+
+// servicePortKey identifies a port by its number and protocol
+type servicePortKey struct {
+	port     int32
+	protocol corev1.Protocol
+}
+
+// validatePorts rejects extra ports which conflict with the game port, the
+// RCON port or with each other
+func validatePorts(minecraft *cachev1alpha1.Minecraft, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	service := minecraft.Spec.Service
+
+	protocol := corev1.ProtocolTCP
+	gameTargets := []int32{cachev1alpha1.JavaGamePort}
+	if minecraft.Spec.Edition == cachev1alpha1.EditionBedrock {
+		protocol = corev1.ProtocolUDP
+		gameTargets = []int32{cachev1alpha1.BedrockGamePort, cachev1alpha1.BedrockGamePortV6}
+	}
+	port := service.Port
+	if port == 0 {
+		port = gamePortForMinecraft(minecraft)
+	}
+
+	ports := map[servicePortKey]string{{port, protocol}: "the game port"}
+	targets := map[servicePortKey]string{}
+	for _, target := range gameTargets {
+		targets[servicePortKey{target, protocol}] = "the game port"
+	}
+	if minecraft.Spec.Edition != cachev1alpha1.EditionBedrock {
+		targets[servicePortKey{cachev1alpha1.RCONPort, corev1.ProtocolTCP}] = "the RCON port"
+	}
+	nodePorts := map[int32]string{}
+	if service.NodePort != 0 {
+		nodePorts[service.NodePort] = "the game port"
+	}
+
+	for i, p := range service.ExtraPorts {
+		portPath := fldPath.Child("extraPorts").Index(i)
+		protocol := p.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		target := p.TargetPort
+		if target == 0 {
+			target = p.Port
+		}
+
+		if p.Name == cachev1alpha1.RCONPortName && minecraft.Spec.Edition != cachev1alpha1.EditionBedrock {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("name"), p.Name,
+				"the name is used by the RCON port of the server"))
+		}
+		if other, found := ports[servicePortKey{p.Port, protocol}]; found {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("port"),
+				fmt.Sprintf("%d/%s is already used by %s", p.Port, protocol, other)))
+		}
+		if other, found := targets[servicePortKey{target, protocol}]; found {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("targetPort"),
+				fmt.Sprintf("%d/%s is already used by %s", target, protocol, other)))
+		}
+		if other, found := nodePorts[p.NodePort]; found && p.NodePort != 0 {
+			allErrs = append(allErrs, field.Duplicate(portPath.Child("nodePort"),
+				fmt.Sprintf("%d is already used by %s", p.NodePort, other)))
+		}
+
+		name := fmt.Sprintf("the extra port %s", p.Name)
+		ports[servicePortKey{p.Port, protocol}] = name
+		targets[servicePortKey{target, protocol}] = name
+		if p.NodePort != 0 {
+			nodePorts[p.NodePort] = name
+		}
+	}
+	return allErrs
+}
+
+// validateMinecraftUpdate rejects the changes the servers can not follow. The
+// edition decides the image and the format of the worlds, the volume claim
+// templates of the StatefulSet are immutable, and the world is backed up next
+// to the servers before a new version rolls out.
+func validateMinecraftUpdate(old, minecraft *cachev1alpha1.Minecraft) field.ErrorList {
+	var allErrs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	if editionOrDefault(old) != editionOrDefault(minecraft) {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("edition"), "the edition is immutable"))
+	}
+	if old.Spec.Version != minecraft.Spec.Version && minecraft.Spec.Storage.AccessMode == corev1.ReadWriteOncePod {
+		allErrs = append(allErrs, field.Forbidden(specPath.Child("version"),
+			"the backup taken before an upgrade can not mount a ReadWriteOncePod world volume while the servers run"))
+	}
+
+	storagePath := specPath.Child("storage")
+	oldStorage, storage := old.Spec.Storage, minecraft.Spec.Storage
+	if !equalStrings(oldStorage.StorageClassName, storage.StorageClassName) {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("storageClassName"),
+			"the storage class is immutable"))
+	}
+	if oldStorage.AccessMode != "" && oldStorage.AccessMode != storage.AccessMode {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("accessMode"),
+			"the access mode is immutable"))
+	}
+	if !oldStorage.Size.IsZero() && !storage.Size.Equal(oldStorage.Size) {
+		allErrs = append(allErrs, field.Forbidden(storagePath.Child("size"),
+			fmt.Sprintf("the size is immutable, the world volumes keep %s", oldStorage.Size.String())))
+	}
+	return allErrs
+}
+
+// editionOrDefault returns the edition of the custom resource
+func editionOrDefault(minecraft *cachev1alpha1.Minecraft) cachev1alpha1.Edition {
+	if minecraft.Spec.Edition == "" {
+		return cachev1alpha1.EditionJava
+	}
+	return minecraft.Spec.Edition
+}
+
+// gamePortForMinecraft returns the default port of the edition
+func gamePortForMinecraft(minecraft *cachev1alpha1.Minecraft) int32 {
+	if minecraft.Spec.Edition == cachev1alpha1.EditionBedrock {
+		return cachev1alpha1.BedrockGamePort
+	}
+	return cachev1alpha1.JavaGamePort
+}
+
+// memoryLimitForHeap returns the smallest memory limit, in whole mebibytes,
+// which gives the heap the percentage of it
+func memoryLimitForHeap(heap resource.Quantity, percentage int32) resource.Quantity {
+	const mebibyte = 1 << 20
+	bytes := (heap.Value()*100 + int64(percentage) - 1) / int64(percentage)
+	return *resource.NewQuantity((bytes+mebibyte-1)/mebibyte*mebibyte, resource.BinarySI)
+}
+
+// equalStrings returns whether two optional strings are both unset or equal
+func equalStrings(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,246 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	cachev1alpha1 "github.com/example/minecraft-operator/api/v1alpha1"
+)
+
+var _ = Describe("Minecraft Webhook", func() {
+	var (
+		ctx       = context.Background()
+		obj       *cachev1alpha1.Minecraft
+		oldObj    *cachev1alpha1.Minecraft
+		validator MinecraftCustomValidator
+		defaulter MinecraftCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		obj = &cachev1alpha1.Minecraft{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-minecraft", Namespace: "default"},
+		}
+		oldObj = obj.DeepCopy()
+		validator = MinecraftCustomValidator{}
+		defaulter = MinecraftCustomDefaulter{}
+	})
+
+	Context("When creating Minecraft under Defaulting Webhook", func() {
+		It("Should fill in the defaults of a Java server", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Edition).To(Equal(cachev1alpha1.EditionJava))
+			Expect(obj.Spec.Type).To(Equal(cachev1alpha1.ServerTypeVanilla))
+			Expect(obj.Spec.Version).To(Equal("LATEST"))
+			Expect(obj.Spec.Storage.Size.String()).To(Equal("10Gi"))
+			Expect(obj.Spec.Storage.AccessMode).To(Equal(corev1.ReadWriteOnce))
+			Expect(obj.Spec.Service.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(obj.Spec.Service.Port).To(Equal(int32(25565)))
+		})
+
+		It("Should default the port of a Bedrock server", func() {
+			obj.Spec.Edition = cachev1alpha1.EditionBedrock
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Service.Port).To(Equal(int32(19132)))
+		})
+
+		It("Should keep the values set in the spec", func() {
+			obj.Spec.Type = cachev1alpha1.ServerTypePaper
+			obj.Spec.Version = "1.20.4"
+			obj.Spec.Service.Port = 30000
+			obj.Spec.Service.ExtraPorts = []cachev1alpha1.ServicePort{{Name: "map", Port: 8123}}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Type).To(Equal(cachev1alpha1.ServerTypePaper))
+			Expect(obj.Spec.Version).To(Equal("1.20.4"))
+			Expect(obj.Spec.Service.Port).To(Equal(int32(30000)))
+			Expect(obj.Spec.Service.ExtraPorts[0].Protocol).To(Equal(corev1.ProtocolTCP))
+		})
+
+		It("Should size the memory limit after the maximum heap", func() {
+			maxHeap := resource.MustParse("3Gi")
+			obj.Spec.JVM = &cachev1alpha1.JVMSpec{MaxHeap: &maxHeap}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.JVM.HeapPercentage).To(Equal(int32(75)))
+			Expect(obj.Spec.Resources.Limits.Memory().String()).To(Equal("4Gi"))
+		})
+
+		It("Should default the maintenance policy and windows", func() {
+			obj.Spec.Maintenance = &cachev1alpha1.MaintenanceSpec{
+				Windows: []cachev1alpha1.MaintenanceWindow{{Schedule: "0 4 * * 1"}},
+			}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Maintenance.Policy).To(Equal(cachev1alpha1.MaintenancePolicyImmediate))
+			Expect(obj.Spec.Maintenance.Windows[0].Duration.Duration).To(Equal(time.Hour))
+		})
+	})
+
+	Context("When creating or updating Minecraft under Validating Webhook", func() {
+		It("Should admit a defaulted server", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeNil())
+		})
+
+		It("Should deny versions older than the type supports", func() {
+			obj.Spec.Type = cachev1alpha1.ServerTypeNeoForge
+			obj.Spec.Version = "1.19.2"
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err).To(MatchError(ContainSubstring("spec.version")))
+
+			obj.Spec.Version = "1.20.4"
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeNil())
+			obj.Spec.Version = "LATEST"
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeNil())
+		})
+
+		It("Should deny an invalid backup schedule", func() {
+			obj.Spec.Backup = &cachev1alpha1.BackupSchedule{Schedule: "every night"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.backup.schedule")))
+		})
+
+		It("Should deny backups of a ReadWriteOncePod world volume", func() {
+			obj.Spec.Backup = &cachev1alpha1.BackupSchedule{Schedule: "@daily"}
+			obj.Spec.Storage.AccessMode = corev1.ReadWriteOncePod
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.backup: Forbidden")))
+		})
+
+		It("Should deny invalid maintenance windows", func() {
+			obj.Spec.Maintenance = &cachev1alpha1.MaintenanceSpec{
+				Policy: cachev1alpha1.MaintenancePolicyWindowOnly,
+				Windows: []cachev1alpha1.MaintenanceWindow{
+					{Schedule: "0 4 * * 1", Duration: metav1.Duration{Duration: time.Hour}},
+					{Schedule: "on mondays"},
+				},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.maintenance.windows[1].schedule")))
+			Expect(err).To(MatchError(ContainSubstring("spec.maintenance.windows[1].duration")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.maintenance.windows[0]")))
+		})
+
+		It("Should deny conflicting ports", func() {
+			obj.Spec.Service.ExtraPorts = []cachev1alpha1.ServicePort{
+				{Name: "rcon", Port: 25575},
+				{Name: "admin", Port: 9000, TargetPort: 25575},
+				{Name: "game", Port: 25565},
+				{Name: "map", Port: 8123, NodePort: 30100},
+				{Name: "web", Port: 8080, TargetPort: 8123, NodePort: 30100},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.service.extraPorts[0].name")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.extraPorts[1].targetPort")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.extraPorts[2].port")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.extraPorts[4].targetPort")))
+			Expect(err).To(MatchError(ContainSubstring("spec.service.extraPorts[4].nodePort")))
+		})
+
+		It("Should admit the same port over another protocol", func() {
+			obj.Spec.Service.ExtraPorts = []cachev1alpha1.ServicePort{
+				{Name: "voice", Port: 25565, Protocol: corev1.ProtocolUDP},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeNil())
+		})
+
+		It("Should deny requests larger than the limits", func() {
+			obj.Spec.Resources = corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("8Gi")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.resources.requests[memory]")))
+		})
+
+		It("Should deny heaps larger than the memory limit", func() {
+			minHeap, maxHeap := resource.MustParse("3Gi"), resource.MustParse("5Gi")
+			obj.Spec.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("4Gi")}
+			obj.Spec.JVM = &cachev1alpha1.JVMSpec{MinHeap: &minHeap, MaxHeap: &maxHeap}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.jvm.maxHeap")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.jvm.minHeap")))
+
+			By("Deriving the maximum heap from the memory limit")
+			obj.Spec.JVM = &cachev1alpha1.JVMSpec{MinHeap: &minHeap, HeapPercentage: 50}
+			_, err = validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.jvm.minHeap")))
+
+			obj.Spec.JVM.HeapPercentage = 80
+			Expect(validator.ValidateCreate(ctx, obj)).To(BeNil())
+		})
+
+		It("Should deny changing the immutable fields", func() {
+			storageClass := "standard"
+			oldObj.Spec.Storage.StorageClassName = &storageClass
+			oldObj.Spec.Storage.Size = resource.MustParse("10Gi")
+			Expect(defaulter.Default(ctx, oldObj)).To(Succeed())
+
+			obj = oldObj.DeepCopy()
+			obj.Spec.Service.Port = 30000
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
+
+			obj.Spec.Edition = cachev1alpha1.EditionBedrock
+			fast := "fast"
+			obj.Spec.Storage.StorageClassName = &fast
+			obj.Spec.Storage.Size = resource.MustParse("20Gi")
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.edition")))
+			Expect(err).To(MatchError(ContainSubstring("spec.storage.storageClassName")))
+			Expect(err).To(MatchError(ContainSubstring("spec.storage.size")))
+		})
+
+		It("Should deny upgrading a ReadWriteOncePod world volume", func() {
+			oldObj.Spec.Version = "1.20.4"
+			oldObj.Spec.Storage.AccessMode = corev1.ReadWriteOncePod
+			Expect(defaulter.Default(ctx, oldObj)).To(Succeed())
+
+			obj = oldObj.DeepCopy()
+			obj.Spec.Version = "1.21"
+			_, err := validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.version: Forbidden")))
+		})
+
+		It("Should admit a deleted object whose spec is invalid", func() {
+			oldObj.Spec.Backup = &cachev1alpha1.BackupSchedule{Schedule: "every day"}
+			oldObj.Finalizers = []string{"cache.example.com/finalizer"}
+			_, err := validator.ValidateUpdate(ctx, oldObj, oldObj.DeepCopy())
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Removing the finalizer of the deleted object")
+			now := metav1.Now()
+			oldObj.DeletionTimestamp = &now
+			obj = oldObj.DeepCopy()
+			obj.Finalizers = nil
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).To(BeNil())
+
+			By("Denying a change of the spec as long as the object is not deleted")
+			oldObj.DeletionTimestamp = nil
+			obj = oldObj.DeepCopy()
+			obj.Spec.Service.Port = 30000
+			_, err = validator.ValidateUpdate(ctx, oldObj, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.backup.schedule")))
+		})
+	})
+})
@@ -0,0 +1,32 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	cachev1beta1 "github.com/example/minecraft-operator/api/v1beta1"
+)
+
+// SetupMinecraftWebhookWithManager registers the conversion webhook for
+// Minecraft in the manager. The v1beta1 version is the hub the other versions
+// convert to and from, defaulting and validation are handled by the webhooks
+// of v1alpha1 for the objects of all versions.
+func SetupMinecraftWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&cachev1beta1.Minecraft{}).
+		Complete()
+}